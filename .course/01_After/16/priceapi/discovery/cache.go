@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// cache keeps the latest event of an instancer and pushes every change to
+// the registered channels. New channels are sent the current event at once,
+// as sd.Instancer requires.
+type cache struct {
+	mu       sync.Mutex
+	state    sd.Event
+	registry map[chan<- sd.Event]struct{}
+}
+
+func newCache() (c *cache) {
+	c = &cache{
+		registry: make(map[chan<- sd.Event]struct{}),
+	}
+
+	return c
+}
+
+// update replaces the current event and broadcasts it, unless nothing has
+// changed.
+func (c *cache) update(event sd.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.Strings(event.Instances)
+	if reflect.DeepEqual(c.state, event) {
+		return
+	}
+
+	c.state = event
+	for ch := range c.registry {
+		ch <- copyEvent(event)
+	}
+}
+
+func (c *cache) current() sd.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return copyEvent(c.state)
+}
+
+func (c *cache) Register(ch chan<- sd.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.registry[ch] = struct{}{}
+	ch <- copyEvent(c.state)
+}
+
+func (c *cache) Deregister(ch chan<- sd.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.registry, ch)
+}
+
+// copyEvent copies the instances of event, so that no two listeners share
+// a slice.
+func copyEvent(event sd.Event) sd.Event {
+	if event.Instances == nil {
+		return event
+	}
+
+	instances := make([]string, len(event.Instances))
+	copy(instances, event.Instances)
+
+	return sd.Event{Instances: instances, Err: event.Err}
+}
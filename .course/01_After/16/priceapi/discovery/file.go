@@ -0,0 +1,141 @@
+package discovery
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/sd"
+)
+
+// FileInstancer yields the instances listed in a file, one host:port per
+// line. Blank lines and lines starting with # are ignored. The file is
+// checked for changes every interval, so instances can be added and removed
+// by editing it.
+type FileInstancer struct {
+	*cache
+	path   string
+	logger log.Logger
+	quit   chan struct{}
+
+	checking sync.Mutex
+	modTime  time.Time
+	size     int64
+}
+
+// NewFileInstancer reads the instances in path and then polls it every
+// interval until Stop is called; an interval of 0 reads it once only. It
+// fails when the file cannot be read or lists an invalid instance. Later
+// failures are reported as events with an error, and the instances read
+// last stay in use.
+func NewFileInstancer(path string, interval time.Duration, logger log.Logger) (fi *FileInstancer, err error) {
+	fi = &FileInstancer{
+		cache:  newCache(),
+		path:   path,
+		logger: logger,
+		quit:   make(chan struct{}),
+	}
+
+	if _, err := fi.Check(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go fi.loop(interval)
+	}
+
+	return fi, nil
+}
+
+// Stop terminates the polling of the file.
+func (fi *FileInstancer) Stop() {
+	close(fi.quit)
+}
+
+func (fi *FileInstancer) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fi.quit:
+			return
+		case <-ticker.C:
+			fi.Check()
+		}
+	}
+}
+
+// Check rereads the file when its modification time or size has changed
+// since it was last read.
+func (fi *FileInstancer) Check() (changed bool, err error) {
+	fi.checking.Lock()
+	defer fi.checking.Unlock()
+
+	info, err := os.Stat(fi.path)
+	if err != nil {
+		fi.fail(err)
+		return false, err
+	}
+	if info.ModTime().Equal(fi.modTime) && info.Size() == fi.size {
+		return false, nil
+	}
+
+	f, err := os.Open(fi.path)
+	if err != nil {
+		fi.fail(err)
+		return false, err
+	}
+	defer f.Close()
+
+	instances, err := ParseInstances(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", fi.path, err)
+		fi.fail(err)
+		return false, err
+	}
+
+	fi.modTime, fi.size = info.ModTime(), info.Size()
+	fi.update(sd.Event{Instances: instances})
+	_ = fi.logger.Log("path", fi.path, "instances", len(instances))
+
+	return true, nil
+}
+
+// fail reports err while keeping the instances read last.
+func (fi *FileInstancer) fail(err error) {
+	_ = fi.logger.Log("path", fi.path, "error", err)
+	fi.update(sd.Event{Instances: fi.current().Instances, Err: err})
+}
+
+// ParseInstances reads one host:port instance per line, skipping blank
+// lines and # comments.
+func ParseInstances(r io.Reader) (instances []string, err error) {
+	instances = []string{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		instance := strings.TrimSpace(scanner.Text())
+		if instance == "" || strings.HasPrefix(instance, "#") {
+			continue
+		}
+
+		if _, port, err := net.SplitHostPort(instance); err != nil || port == "" {
+			return nil, fmt.Errorf("line %d: invalid instance %q, expected host:port", line, instance)
+		}
+
+		instances = append(instances, instance)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
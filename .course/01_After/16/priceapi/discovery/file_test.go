@@ -0,0 +1,137 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockLogger struct{}
+
+func (MockLogger) Log(keyvals ...interface{}) error {
+	return nil
+}
+
+// waitFor polls set until it holds the expected instances and has failed
+// or not as expected, or until a second has passed.
+func waitFor(set *Set, expected []string, failed bool) (instances []string, err error) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		instances, _, err = set.Instances()
+		if fmt.Sprint(instances) == fmt.Sprint(expected) && failed == (err != nil) || time.Now().After(deadline) {
+			return instances, err
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func Test_ParseInstances(t *testing.T) {
+	tests := []struct {
+		input     string
+		instances []string
+		err       string
+	}{
+		{input: "", instances: []string{}},
+		{input: "localhost:8081\n", instances: []string{"localhost:8081"}},
+		{input: "# pricing\n\n  10.0.0.1:8081  \n[::1]:8082\n", instances: []string{"10.0.0.1:8081", "[::1]:8082"}},
+		{input: "localhost:8081\nlocalhost\n", err: "line 2: invalid instance \"localhost\", expected host:port"},
+		{input: "localhost:\n", err: "line 1: invalid instance \"localhost:\", expected host:port"},
+	}
+
+	for id, test := range tests {
+		instances, err := ParseInstances(strings.NewReader(test.input))
+
+		actualErr := ""
+		if err != nil {
+			actualErr = err.Error()
+		}
+		assert.True(t, test.err == actualErr, "~2|Test #%d expected error: %s, not error %s~", id, test.err, actualErr)
+		assert.True(t, fmt.Sprint(test.instances) == fmt.Sprint(instances), "~2|Test #%d expected instances: %v, not instances %v~", id, test.instances, instances)
+	}
+}
+
+func Test_FileInstancer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.txt")
+	write := func(content string, age time.Duration) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := NewFileInstancer(path, 0, new(MockLogger))
+	assert.True(t, errors.Is(err, os.ErrNotExist), "~2|Test expected a missing file to fail, not error %v~", err)
+
+	write("localhost:8082\nlocalhost:8081\n", 4*time.Minute)
+
+	fi, err := NewFileInstancer(path, 0, new(MockLogger))
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+
+	set := NewSet(fi)
+	defer set.Stop()
+
+	tests := []struct {
+		content   string
+		age       time.Duration
+		remove    bool
+		changed   bool
+		failed    bool
+		instances []string
+	}{
+		{age: 4 * time.Minute, instances: []string{"localhost:8081", "localhost:8082"}},
+		{content: "localhost:8081\nlocalhost:8083\n", age: 3 * time.Minute, changed: true, instances: []string{"localhost:8081", "localhost:8083"}},
+		{content: "localhost:8081\nbroken\n", age: 2 * time.Minute, failed: true, instances: []string{"localhost:8081", "localhost:8083"}},
+		{remove: true, failed: true, instances: []string{"localhost:8081", "localhost:8083"}},
+		{content: "# drained\n", age: time.Minute, changed: true, instances: []string{}},
+	}
+
+	for id, test := range tests {
+		switch {
+		case test.remove:
+			os.Remove(path)
+		case test.content != "":
+			write(test.content, test.age)
+		}
+
+		changed, err := fi.Check()
+		assert.True(t, test.changed == changed, "~2|Test #%d expected changed: %t, not changed %t~", id, test.changed, changed)
+		assert.True(t, test.failed == (err != nil), "~2|Test #%d expected failed: %t, not error %v~", id, test.failed, err)
+
+		instances, setErr := waitFor(set, test.instances, test.failed)
+		assert.True(t, fmt.Sprint(test.instances) == fmt.Sprint(instances), "~2|Test #%d expected instances: %v, not instances %v~", id, test.instances, instances)
+		assert.True(t, test.failed == (setErr != nil), "~2|Test #%d expected set failed: %t, not error %v~", id, test.failed, setErr)
+	}
+}
+
+func Test_FileInstancer_Polling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.txt")
+	if err := os.WriteFile(path, []byte("localhost:8081\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := NewFileInstancer(path, 5*time.Millisecond, new(MockLogger))
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+	defer fi.Stop()
+
+	set := NewSet(fi)
+	defer set.Stop()
+
+	expected := []string{"localhost:8081", "localhost:8082"}
+	if err := os.WriteFile(path, []byte("localhost:8081\nlocalhost:8082\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	instances, _ := waitFor(set, expected, false)
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(instances), "~2|Test expected instances: %v, not instances %v~", expected, instances)
+}
@@ -0,0 +1,276 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/sd"
+)
+
+// HealthConfig sets how instances are probed. An instance is ejected after
+// Fall failed probes in a row and admitted again after Rise good probes in a
+// row.
+type HealthConfig struct {
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+	Fall     int
+	Rise     int
+}
+
+// DefaultHealthConfig probes /health every 5 seconds, ejects an instance
+// after 3 failures and admits it again after 2 successes.
+var DefaultHealthConfig = HealthConfig{
+	Path:     "/health",
+	Interval: 5 * time.Second,
+	Timeout:  time.Second,
+	Fall:     3,
+	Rise:     2,
+}
+
+type instanceHealth struct {
+	healthy   bool
+	successes int
+	failures  int
+}
+
+// HealthChecker follows an instancer and yields only the instances whose
+// health endpoint answers 200 OK. Instances are admitted as soon as they
+// are discovered, so that a new instance takes traffic without waiting for
+// a round of probes, and are ejected by the probes if they are not up.
+type HealthChecker struct {
+	*cache
+	instancer sd.Instancer
+	events    chan sd.Event
+	config    HealthConfig
+	client    *http.Client
+	healthy   metrics.Gauge
+	logger    log.Logger
+	quit      chan struct{}
+
+	checking  sync.Mutex
+	mu        sync.Mutex
+	instances []string
+	err       error
+	health    map[string]*instanceHealth
+}
+
+// NewHealthChecker starts following instancer and, when config has an
+// interval, probes its instances every interval until Stop is called. The
+// healthy gauge is set to 1 or 0 for each instance, labelled by instance.
+func NewHealthChecker(instancer sd.Instancer, config HealthConfig, healthy metrics.Gauge, logger log.Logger) (hc *HealthChecker) {
+	if config.Path == "" {
+		config.Path = DefaultHealthConfig.Path
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultHealthConfig.Timeout
+	}
+	if config.Fall < 1 {
+		config.Fall = 1
+	}
+	if config.Rise < 1 {
+		config.Rise = 1
+	}
+
+	hc = &HealthChecker{
+		cache:     newCache(),
+		instancer: instancer,
+		events:    make(chan sd.Event),
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		healthy:   healthy,
+		logger:    logger,
+		quit:      make(chan struct{}),
+		health:    make(map[string]*instanceHealth),
+	}
+
+	go hc.receive()
+	instancer.Register(hc.events)
+
+	if config.Interval > 0 {
+		go hc.loop(config.Interval)
+	}
+
+	return hc
+}
+
+// Stop stops probing and following the instancer. The events channel is
+// left open, as the instancer may still be sending on it; receive is
+// stopped by quit instead, once Deregister has let any send in progress
+// through.
+func (hc *HealthChecker) Stop() {
+	hc.instancer.Deregister(hc.events)
+	close(hc.quit)
+}
+
+func (hc *HealthChecker) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hc.quit:
+			return
+		case <-ticker.C:
+			hc.Check(context.Background())
+		}
+	}
+}
+
+func (hc *HealthChecker) receive() {
+	for {
+		select {
+		case <-hc.quit:
+			return
+		case event := <-hc.events:
+			hc.track(event)
+		}
+	}
+}
+
+// track brings the tracked instances in line with event. Instances that
+// have gone are forgotten, so they start afresh if they come back.
+func (hc *HealthChecker) track(event sd.Event) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.err = event.Err
+	if event.Err != nil {
+		hc.publish()
+		return
+	}
+
+	current := make(map[string]bool, len(event.Instances))
+	for _, instance := range event.Instances {
+		current[instance] = true
+
+		if _, found := hc.health[instance]; !found {
+			hc.health[instance] = &instanceHealth{healthy: true}
+		}
+	}
+
+	for instance := range hc.health {
+		if !current[instance] {
+			delete(hc.health, instance)
+			hc.healthy.With("instance", instance).Set(0)
+		}
+	}
+
+	hc.instances = event.Instances
+	hc.publish()
+}
+
+// Check probes every instance once, concurrently, and updates the
+// instances yielded.
+func (hc *HealthChecker) Check(ctx context.Context) {
+	hc.checking.Lock()
+	defer hc.checking.Unlock()
+
+	hc.mu.Lock()
+	instances := make([]string, len(hc.instances))
+	copy(instances, hc.instances)
+	hc.mu.Unlock()
+
+	results := make([]error, len(instances))
+
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance string) {
+			defer wg.Done()
+			results[i] = hc.probe(ctx, instance)
+		}(i, instance)
+	}
+	wg.Wait()
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	for i, instance := range instances {
+		if health, found := hc.health[instance]; found {
+			hc.record(instance, health, results[i])
+		}
+	}
+
+	hc.publish()
+}
+
+func (hc *HealthChecker) probe(ctx context.Context, instance string) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+instance+hc.config.Path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// record counts the outcome of a probe against the thresholds, ejecting or
+// admitting the instance when it crosses one.
+func (hc *HealthChecker) record(instance string, health *instanceHealth, err error) {
+	if err != nil {
+		health.successes = 0
+		health.failures++
+
+		if health.healthy && health.failures >= hc.config.Fall {
+			health.healthy = false
+			_ = hc.logger.Log("instance", instance, "health", "ejected", "failures", health.failures, "error", err)
+		}
+
+		return
+	}
+
+	health.failures = 0
+	health.successes++
+
+	if !health.healthy && health.successes >= hc.config.Rise {
+		health.healthy = true
+		_ = hc.logger.Log("instance", instance, "health", "admitted", "successes", health.successes)
+	}
+}
+
+// publish yields the healthy instances and exports the health of each. It
+// must be called with mu held.
+func (hc *HealthChecker) publish() {
+	healthy := []string{}
+	for _, instance := range hc.instances {
+		health, found := hc.health[instance]
+		if !found {
+			continue
+		}
+
+		if health.healthy {
+			healthy = append(healthy, instance)
+			hc.healthy.With("instance", instance).Set(1)
+		} else {
+			hc.healthy.With("instance", instance).Set(0)
+		}
+	}
+
+	hc.update(sd.Event{Instances: healthy, Err: hc.err})
+}
+
+// Healthy reports whether instance is being yielded.
+func (hc *HealthChecker) Healthy(instance string) (healthy bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	health, found := hc.health[instance]
+	return found && health.healthy
+}
@@ -0,0 +1,148 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockGauge keeps the last value set for each instance label.
+type MockGauge struct {
+	mu     *sync.Mutex
+	values map[string]float64
+	label  string
+}
+
+func (mg *MockGauge) With(labelValues ...string) metrics.Gauge {
+	return &MockGauge{mu: mg.mu, values: mg.values, label: labelValues[1]}
+}
+
+func (mg *MockGauge) Set(value float64) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	mg.values[mg.label] = value
+}
+
+func (mg *MockGauge) Add(delta float64) {}
+
+func newHealthServer(down *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || atomic.LoadInt32(down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+}
+
+func Test_HealthChecker(t *testing.T) {
+	var downA, downB int32
+	serverA, serverB := newHealthServer(&downA), newHealthServer(&downB)
+	defer serverA.Close()
+	defer serverB.Close()
+
+	a, b := strings.TrimPrefix(serverA.URL, "http://"), strings.TrimPrefix(serverB.URL, "http://")
+
+	gauge := &MockGauge{mu: new(sync.Mutex), values: make(map[string]float64)}
+	config := HealthConfig{Path: "/health", Fall: 2, Rise: 2}
+
+	hc := NewHealthChecker(sd.FixedInstancer{a, b}, config, gauge, new(MockLogger))
+	defer hc.Stop()
+
+	set := NewSet(hc)
+	defer set.Stop()
+
+	both := []string{a, b}
+	if a > b {
+		both = []string{b, a}
+	}
+
+	tests := []struct {
+		downA     int32
+		instances []string
+		gaugeA    float64
+	}{
+		{downA: 0, instances: both, gaugeA: 1},
+		{downA: 1, instances: both, gaugeA: 1},
+		{downA: 1, instances: []string{b}, gaugeA: 0},
+		{downA: 0, instances: []string{b}, gaugeA: 0},
+		{downA: 1, instances: []string{b}, gaugeA: 0},
+		{downA: 0, instances: []string{b}, gaugeA: 0},
+		{downA: 0, instances: both, gaugeA: 1},
+	}
+
+	for id, test := range tests {
+		atomic.StoreInt32(&downA, test.downA)
+		hc.Check(context.Background())
+
+		instances, _ := waitFor(set, test.instances, false)
+		assert.True(t, fmt.Sprint(test.instances) == fmt.Sprint(instances), "~2|Test #%d expected instances: %v, not instances %v~", id, test.instances, instances)
+
+		gauge.mu.Lock()
+		gaugeA, gaugeB := gauge.values[a], gauge.values[b]
+		gauge.mu.Unlock()
+		assert.True(t, test.gaugeA == gaugeA, "~2|Test #%d expected gauge of A: %v, not gauge %v~", id, test.gaugeA, gaugeA)
+		assert.True(t, gaugeB == 1, "~2|Test #%d expected gauge of B: 1, not gauge %v~", id, gaugeB)
+	}
+}
+
+// MockBusyInstancer sends events from its own goroutine, without waiting
+// for Deregister, as an instancer that broadcasts outside its lock does.
+type MockBusyInstancer struct {
+	done chan struct{}
+}
+
+func (mi *MockBusyInstancer) Register(ch chan<- sd.Event) {
+	go func() {
+		for {
+			select {
+			case <-mi.done:
+				return
+			case ch <- sd.Event{Instances: []string{"localhost:1"}}:
+			}
+		}
+	}()
+}
+
+func (mi *MockBusyInstancer) Deregister(ch chan<- sd.Event) {}
+
+func (mi *MockBusyInstancer) Stop() {}
+
+func Test_HealthChecker_StopWhileSending(t *testing.T) {
+	gauge := &MockGauge{mu: new(sync.Mutex), values: make(map[string]float64)}
+
+	for i := 0; i < 100; i++ {
+		instancer := &MockBusyInstancer{done: make(chan struct{})}
+
+		hc := NewHealthChecker(instancer, HealthConfig{}, gauge, new(MockLogger))
+		hc.Stop()
+
+		// A send on a closed channel would have panicked by now.
+		time.Sleep(time.Millisecond)
+		close(instancer.done)
+	}
+}
+
+func Test_Set_StopWhileSending(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		instancer := &MockBusyInstancer{done: make(chan struct{})}
+
+		set := NewSet(instancer)
+		set.Stop()
+
+		time.Sleep(time.Millisecond)
+		close(instancer.done)
+	}
+}
@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// Set follows an instancer and keeps the instances it yielded last, so that
+// they can be reported.
+type Set struct {
+	instancer sd.Instancer
+	events    chan sd.Event
+	quit      chan struct{}
+
+	mu        sync.RWMutex
+	instances []string
+	updated   time.Time
+	err       error
+}
+
+// NewSet starts following instancer until Stop is called.
+func NewSet(instancer sd.Instancer) (s *Set) {
+	s = &Set{
+		instancer: instancer,
+		events:    make(chan sd.Event),
+		quit:      make(chan struct{}),
+	}
+
+	go s.receive()
+	instancer.Register(s.events)
+
+	return s
+}
+
+func (s *Set) receive() {
+	for {
+		select {
+		case <-s.quit:
+			return
+		case event := <-s.events:
+			s.mu.Lock()
+			if event.Err == nil {
+				s.instances = event.Instances
+			}
+			s.err = event.Err
+			s.updated = time.Now()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Instances returns the current instances, when the instancer last reported
+// and the error of that report, if it failed. The instances found before a
+// failure are kept.
+func (s *Set) Instances() (instances []string, updated time.Time, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	instances = make([]string, len(s.instances))
+	copy(instances, s.instances)
+
+	return instances, s.updated, s.err
+}
+
+// Stop stops following the instancer. As with HealthChecker.Stop, the
+// events channel is left open for the instancer and receive is stopped by
+// quit.
+func (s *Set) Stop() {
+	s.instancer.Deregister(s.events)
+	close(s.quit)
+}
@@ -0,0 +1,21 @@
+package discovery
+
+import (
+	"net"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/sd/dnssrv"
+)
+
+// NewSRVInstancer yields the targets of the DNS SRV record name, such as
+// _pricing._tcp.example.com, looked up every interval. A nil lookup uses
+// net.LookupSRV; tests can pass a fake resolver instead. Priorities and
+// weights are ignored, and a failed lookup keeps the instances found last.
+func NewSRVInstancer(name string, interval time.Duration, lookup dnssrv.Lookup, logger log.Logger) (in *dnssrv.Instancer) {
+	if lookup == nil {
+		lookup = net.LookupSRV
+	}
+
+	return dnssrv.NewInstancerDetailed(name, time.NewTicker(interval), lookup, logger)
+}
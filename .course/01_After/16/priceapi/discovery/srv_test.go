@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// MockResolver answers SRV lookups from a record set that tests can change.
+type MockResolver struct {
+	mu      sync.Mutex
+	records map[string][]*net.SRV
+	err     error
+}
+
+func (mr *MockResolver) Set(name string, records []*net.SRV, err error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.records[name] = records
+	mr.err = err
+}
+
+func (mr *MockResolver) LookupSRV(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.err != nil {
+		return "", nil, mr.err
+	}
+
+	records, found := mr.records[name]
+	if !found {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return name, records, nil
+}
+
+func Test_SRVInstancer(t *testing.T) {
+	name := "_pricing._tcp.example.com"
+
+	resolver := &MockResolver{records: map[string][]*net.SRV{
+		name: {{Target: "a.example.com.", Port: 8081}, {Target: "b.example.com.", Port: 8081}},
+	}}
+
+	in := NewSRVInstancer(name, 5*time.Millisecond, resolver.LookupSRV, new(MockLogger))
+	defer in.Stop()
+
+	set := NewSet(in)
+	defer set.Stop()
+
+	tests := []struct {
+		records   []*net.SRV
+		err       error
+		instances []string
+	}{
+		{
+			records:   []*net.SRV{{Target: "a.example.com.", Port: 8081}, {Target: "b.example.com.", Port: 8081}},
+			instances: []string{"a.example.com.:8081", "b.example.com.:8081"},
+		},
+		{
+			records:   []*net.SRV{{Target: "b.example.com.", Port: 8081}, {Target: "c.example.com.", Port: 8082}},
+			instances: []string{"b.example.com.:8081", "c.example.com.:8082"},
+		},
+		{
+			err:       errors.New("server misbehaving"),
+			instances: []string{"b.example.com.:8081", "c.example.com.:8082"},
+		},
+		{
+			records:   []*net.SRV{{Target: "c.example.com.", Port: 8082}},
+			instances: []string{"c.example.com.:8082"},
+		},
+	}
+
+	for id, test := range tests {
+		if test.err != nil {
+			resolver.Set(name, resolver.records[name], test.err)
+		} else {
+			resolver.Set(name, test.records, nil)
+		}
+
+		instances, err := waitFor(set, test.instances, test.err != nil)
+
+		assert.True(t, fmt.Sprint(test.instances) == fmt.Sprint(instances), "~2|Test #%d expected instances: %v, not instances %v~", id, test.instances, instances)
+		assert.True(t, (test.err != nil) == (err != nil), "~2|Test #%d expected error: %v, not error %v~", id, test.err, err)
+	}
+}
@@ -0,0 +1,24 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StaticInstancer(t *testing.T) {
+	si := NewStaticInstancer([]string{"localhost:8082", "localhost:8081"})
+
+	first, second := make(chan sd.Event, 1), make(chan sd.Event, 1)
+	si.Register(first)
+	si.Register(second)
+
+	a, b := <-first, <-second
+	a.Instances[0] = "changed:8080"
+
+	expected := []string{"localhost:8081", "localhost:8082"}
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(b.Instances), "~2|Test expected instances: %v, not instances %v~", expected, b.Instances)
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(si.current().Instances), "~2|Test expected instances: %v, not instances %v~", expected, si.current().Instances)
+}
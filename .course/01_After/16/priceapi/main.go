@@ -4,61 +4,85 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/discovery"
 	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/telemetry"
 	"github.com/britzc/go-kit_0dot12_fundamentals/current/transport"
 	"github.com/gorilla/mux"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 
 	"github.com/go-kit/kit/log"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/kit/sd"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	defaults := transport.DefaultResiliencePolicy.Default
+
 	var (
-		listen = flag.String("listen", ":8080", "HTTP listen address")
-		proxy  = flag.String("proxy", "localhost:8081,localhost:8082,localhost:8083", "List of URLs to proxy pricing requests")
+		listen    = flag.String("listen", ":8080", "HTTP listen address")
+		proxy     = flag.String("proxy", "localhost:8081,localhost:8082,localhost:8083", "List of URLs to proxy pricing requests")
+		discover  = flag.String("discovery", "static", "How to find the pricing instances: static, file or dns")
+		instances = flag.String("instances", "instances.txt", "File of host:port pricing instances, one per line, for file discovery")
+		srv       = flag.String("srv", "", "DNS SRV name of the pricing instances for dns discovery")
+		refresh   = flag.Duration("refresh", 5*time.Second, "How often to refresh the pricing instances for file and dns discovery")
+		health    = flag.Duration("health", discovery.DefaultHealthConfig.Interval, "Interval between health checks of the pricing instances, 0 disables them")
+		fall      = flag.Int("health-fall", discovery.DefaultHealthConfig.Fall, "Failed health checks in a row that eject a pricing instance")
+		rise      = flag.Int("health-rise", discovery.DefaultHealthConfig.Rise, "Passed health checks in a row that admit an ejected pricing instance again")
+
+		balance = flag.String("balancer", transport.DefaultBalancerConfig.Strategy, "How requests are spread over the pricing instances: round-robin, random, least-outstanding or consistent-hash")
+		hashKey = flag.String("hash-key", transport.DefaultBalancerConfig.HashKey, "Request field that consistent-hash balancing keys on: code or partner")
+
+		resilience      = flag.String("resilience", "", "YAML or JSON file of the rate limit, retry and breaker policy of each route")
+		qps             = flag.Float64("qps", defaults.RateLimit, "Requests per second allowed to each pricing instance")
+		burst           = flag.Int("burst", defaults.Burst, "Requests allowed at once to each pricing instance")
+		maxAttempts     = flag.Int("max-attempts", defaults.MaxAttempts, "Attempts at each request, retries included")
+		retryBudget     = flag.Duration("retry-budget", time.Duration(defaults.Budget), "Time allowed for all attempts at a request")
+		attemptTimeout  = flag.Duration("attempt-timeout", time.Duration(defaults.AttemptTimeout), "Time allowed for each attempt, 0 leaves it to the retry budget")
+		breakerFailures = flag.Uint("breaker-failures", uint(defaults.Breaker.Failures), "Failures in a row that open the breaker of a pricing instance")
+		breakerOpen     = flag.Duration("breaker-open", time.Duration(defaults.Breaker.OpenTimeout), "Time an open breaker waits before testing its pricing instance again")
+		backoffBase     = flag.Duration("backoff", time.Duration(defaults.Backoff.Base), "Wait before the first retry, doubled for each retry after, 0 disables backoff")
+		backoffMax      = flag.Duration("backoff-max", time.Duration(defaults.Backoff.Max), "Longest wait before a retry, 0 for no limit")
+		jitter          = flag.Float64("jitter", defaults.Backoff.Jitter, "Fraction of each backoff drawn at random, from 0 to 1")
+		hedgeDelay      = flag.Duration("hedge-delay", time.Duration(defaults.Hedge.Delay), "Wait before a request is also sent to another pricing instance, 0 disables hedging")
+		hedgeQuantile   = flag.Float64("hedge-quantile", defaults.Hedge.Quantile, "Hedge once a request is slower than this quantile of recent replies, such as 0.95, 0 hedges after -hedge-delay")
+
+		environment   = flag.String("environment", telemetry.DefaultConfig.Environment, "Deployment environment the traces are tagged with")
+		traceExporter = flag.String("trace-exporter", telemetry.DefaultConfig.Exporter, "Where spans are exported to: none, file, stdout or otlp-http, which Jaeger also accepts")
+		traceFile     = flag.String("trace-file", telemetry.DefaultConfig.File, "File the file trace exporter appends to")
+		otlpEndpoint  = flag.String("otlp-endpoint", telemetry.DefaultConfig.Endpoint, "host:port of the collector the otlp-http trace exporter sends to")
+		traceSampler  = flag.String("trace-sampler", telemetry.DefaultConfig.Sampler, "Which traces are kept: always, ratio or parent-based")
+		traceRatio    = flag.Float64("trace-ratio", telemetry.DefaultConfig.Ratio, "Fraction of traces kept by the ratio and parent-based samplers")
 	)
 	flag.Parse()
 
-	proxyList := strings.Split(*proxy, ",")
-	for i := range proxyList {
-		proxyList[i] = strings.TrimSpace(proxyList[i])
-	}
-
 	fmt.Println("Logging and tracing: In progress")
 
 	logger := log.NewLogfmtLogger(os.Stderr)
 
-	// Write telemetry data to a file.
-	f, err := os.Create("traces.txt")
-	if err != nil {
-		logger.Log("err", err)
-		return
-	}
-	defer f.Close()
+	tracing := telemetry.DefaultConfig
+	tracing.ServiceName = "PriceAPI"
+	tracing.Environment = *environment
+	tracing.Exporter = *traceExporter
+	tracing.File = *traceFile
+	tracing.Endpoint = *otlpEndpoint
+	tracing.Sampler = *traceSampler
+	tracing.Ratio = *traceRatio
 
-	exp, err := newExporter(f)
+	tp, shutdown, err := telemetry.NewTracerProvider(context.Background(), tracing)
 	if err != nil {
 		logger.Log("err", err)
 		return
 	}
-
-	tp := trace.NewTracerProvider(
-		trace.WithBatcher(exp),
-		trace.WithResource(newResource()),
-	)
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
+		if err := shutdown(context.Background()); err != nil {
 			logger.Log("err", err)
 			return
 		}
@@ -67,10 +91,139 @@ func main() {
 
 	fmt.Println("Logging and tracing: Ready")
 
+	balancing := transport.BalancerConfig{Strategy: *balance, HashKey: *hashKey}
+	if err := balancing.Validate(); err != nil {
+		logger.Log("err", err)
+		return
+	}
+
+	fmt.Println("Resilience policy: In progress")
+
+	policy := transport.DefaultResiliencePolicy
+	if *resilience != "" {
+		if policy, err = transport.LoadResiliencePolicy(*resilience); err != nil {
+			logger.Log("err", err)
+			return
+		}
+	}
+
+	// Flags given on the command line win over the resilience file, for
+	// every route.
+	flag.Visit(func(f *flag.Flag) {
+		policy.Override(func(p *transport.RoutePolicy) {
+			switch f.Name {
+			case "qps":
+				p.RateLimit = *qps
+			case "burst":
+				p.Burst = *burst
+			case "max-attempts":
+				p.MaxAttempts = *maxAttempts
+			case "retry-budget":
+				p.Budget = transport.Duration(*retryBudget)
+			case "attempt-timeout":
+				p.AttemptTimeout = transport.Duration(*attemptTimeout)
+			case "breaker-failures":
+				p.Breaker.Failures = uint32(*breakerFailures)
+			case "breaker-open":
+				p.Breaker.OpenTimeout = transport.Duration(*breakerOpen)
+			case "backoff":
+				p.Backoff.Base = transport.Duration(*backoffBase)
+			case "backoff-max":
+				p.Backoff.Max = transport.Duration(*backoffMax)
+			case "jitter":
+				p.Backoff.Jitter = *jitter
+			case "hedge-delay":
+				p.Hedge.Delay = transport.Duration(*hedgeDelay)
+			case "hedge-quantile":
+				p.Hedge.Quantile = *hedgeQuantile
+			}
+		})
+	})
+	if err := policy.Validate(); err != nil {
+		logger.Log("err", err)
+		return
+	}
+
+	proxyMetrics := transport.ProxyMetrics{
+		BreakerState: kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "gokitfundamentals",
+			Subsystem: "pricing_api",
+			Name:      "breaker_state",
+			Help:      "State of the circuit breaker of a route and pricing instance, 0 closed, 1 half-open and 2 open.",
+		}, []string{"route", "instance"}),
+		HedgesFired: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "gokitfundamentals",
+			Subsystem: "pricing_api",
+			Name:      "hedges_fired",
+			Help:      "Number of requests also sent to a second pricing instance.",
+		}, []string{"route"}),
+		HedgesWon: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "gokitfundamentals",
+			Subsystem: "pricing_api",
+			Name:      "hedges_won",
+			Help:      "Number of hedged requests answered first by the second pricing instance.",
+		}, []string{"route"}),
+	}
+
+	fmt.Println("Resilience policy: Ready")
+
+	fmt.Println("Service discovery: In progress")
+
+	var instancer sd.Instancer
+	switch *discover {
+	case "static":
+		proxyList := strings.Split(*proxy, ",")
+		for i := range proxyList {
+			proxyList[i] = strings.TrimSpace(proxyList[i])
+		}
+		instancer = discovery.NewStaticInstancer(proxyList)
+	case "file":
+		fi, err := discovery.NewFileInstancer(*instances, *refresh, log.With(logger, "instancer", "file"))
+		if err != nil {
+			logger.Log("err", err)
+			return
+		}
+		defer fi.Stop()
+		instancer = fi
+	case "dns":
+		if *srv == "" {
+			logger.Log("err", "dns discovery needs an SRV name, set -srv")
+			return
+		}
+		di := discovery.NewSRVInstancer(*srv, *refresh, nil, log.With(logger, "instancer", "dns"))
+		defer di.Stop()
+		instancer = di
+	default:
+		logger.Log("err", fmt.Sprintf("unknown discovery %q, expected static, file or dns", *discover))
+		return
+	}
+
+	set := discovery.NewSet(instancer)
+	defer set.Stop()
+
+	if *health > 0 {
+		instanceHealthy := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "gokitfundamentals",
+			Subsystem: "pricing_api",
+			Name:      "instance_healthy",
+			Help:      "Whether a pricing instance passes its health checks, 1 when it does.",
+		}, []string{"instance"})
+
+		healthConfig := discovery.DefaultHealthConfig
+		healthConfig.Interval, healthConfig.Fall, healthConfig.Rise = *health, *fall, *rise
+
+		hc := discovery.NewHealthChecker(instancer, healthConfig, instanceHealthy, log.With(logger, "component", "HealthChecker"))
+		defer hc.Stop()
+		instancer = hc
+	}
+
+	fmt.Println("Service discovery: Ready")
+
 	fmt.Println("Endpoints and handlers: In progress")
 
 	var svc service.PricingService
-	svc = transport.NewPricingServiceProxy(context.Background(), proxyList, logger)
+	svc = transport.NewPricingServiceProxy(context.Background(), instancer, balancing, policy, proxyMetrics, logger)
+	svc = service.NewTracingMiddleware(otel.Tracer("Service.PricingProxy"), svc)
 
 	rtr := mux.NewRouter().StrictSlash(true)
 
@@ -80,32 +233,17 @@ func main() {
 	totalWholesalePriceHandler := transport.MakeTotalWholesalePriceHttpHandler(logger, svc)
 	rtr.Handle("/wholesale", totalWholesalePriceHandler).Methods(http.MethodPost)
 
+	quoteHandler := transport.MakeQuoteHttpHandler(logger, svc)
+	rtr.Handle("/quote", quoteHandler).Methods(http.MethodPost)
+
 	rtr.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 
+	instancesHandler := transport.MakeInstancesHttpHandler(logger, set)
+	rtr.Handle("/debug/instances", instancesHandler).Methods(http.MethodGet)
+
 	fmt.Println("Endpoints and handlers: Ready")
 
 	fmt.Printf("Hosting on %s\n", *listen)
 
 	http.ListenAndServe(*listen, rtr)
 }
-
-func newExporter(w io.Writer) (trace.SpanExporter, error) {
-	return stdouttrace.New(
-		stdouttrace.WithWriter(w),
-		stdouttrace.WithPrettyPrint(),
-		stdouttrace.WithoutTimestamps(),
-	)
-}
-
-func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("PriceAPI"),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "pluralsight"),
-		),
-	)
-	return r
-}
@@ -0,0 +1,120 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimal is an exact decimal number of any precision, used to show amounts
+// before they are rounded. The zero Decimal is 0.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// ParseDecimal reads a decimal string such as "165.615" exactly.
+func ParseDecimal(s string) (d Decimal, err error) {
+	s = strings.TrimSpace(s)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	unscaled, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok || strings.ContainsAny(frac, "+-") || (whole == "" && frac == "") {
+		return Decimal{}, fmt.Errorf("%w %q", ErrInvalidDecimal, s)
+	}
+
+	return Decimal{unscaled: unscaled, scale: len(frac)}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics on error. It is meant for
+// constants and tests.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+// Decimal returns a as an exact Decimal.
+func (a Amount) Decimal() Decimal {
+	return Decimal{unscaled: big.NewInt(int64(a)), scale: MinorUnits}
+}
+
+// Mul returns a * r exactly, such as the saving a discount makes on a unit
+// price.
+func (a Amount) Mul(r Rate) Decimal {
+	unscaled := big.NewInt(int64(a))
+	unscaled.Mul(unscaled, big.NewInt(int64(r)))
+
+	return Decimal{unscaled: unscaled, scale: MinorUnits + RatePlaces}
+}
+
+// Sub returns d - e exactly.
+func (d Decimal) Sub(e Decimal) Decimal {
+	scale := d.scale
+	if e.scale > scale {
+		scale = e.scale
+	}
+
+	return Decimal{unscaled: new(big.Int).Sub(d.rescaled(scale), e.rescaled(scale)), scale: scale}
+}
+
+// Cmp compares d and e and returns -1, 0 or +1.
+func (d Decimal) Cmp(e Decimal) int {
+	return d.Sub(e).value().Sign()
+}
+
+// String writes d in full, with at least MinorUnits decimal places, e.g.
+// 165.615 or 2.50.
+func (d Decimal) String() string {
+	s := formatBig(d.value(), d.scale)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) < MinorUnits {
+		frac += strings.Repeat("0", MinorUnits-len(frac))
+	}
+
+	return whole + "." + frac
+}
+
+// MarshalJSON encodes the decimal as a JSON number, in full.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (d *Decimal) UnmarshalJSON(data []byte) (err error) {
+	*d, err = ParseDecimal(strings.Trim(string(data), `"`))
+	return err
+}
+
+func (d Decimal) value() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+
+	return d.unscaled
+}
+
+func (d Decimal) rescaled(scale int) *big.Int {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil)
+
+	return factor.Mul(factor, d.value())
+}
+
+func formatBig(v *big.Int, places int) string {
+	sign := ""
+	if v.Sign() < 0 {
+		sign = "-"
+	}
+
+	s := new(big.Int).Abs(v).String()
+	if len(s) <= places {
+		s = strings.Repeat("0", places-len(s)+1) + s
+	}
+
+	return sign + s[:len(s)-places] + "." + s[len(s)-places:]
+}
@@ -0,0 +1,95 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseDecimal(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+		err    bool
+	}{
+		{input: "165.615", output: "165.615"},
+		{input: "165.6150000", output: "165.615"},
+		{input: "2.5", output: "2.50"},
+		{input: " 12 ", output: "12.00"},
+		{input: "-0.005", output: "-0.005"},
+		{input: ".5", output: "0.50"},
+		{input: "1e3", err: true},
+		{input: "1.-5", err: true},
+		{input: "abc", err: true},
+		{input: "", err: true},
+	}
+
+	for id, test := range tests {
+		actual, err := ParseDecimal(test.input)
+		assert.True(t, test.err == (err != nil), "~2|Test #%d expected failure: %t, not error %v~", id, test.err, err)
+		if err != nil {
+			continue
+		}
+
+		assert.True(t, test.output == actual.String(), "~2|Test #%d expected string: %s, not %s~", id, test.output, actual)
+	}
+}
+
+func Test_Amount_Mul(t *testing.T) {
+	tests := []struct {
+		amount string
+		rate   string
+		output string
+	}{
+		{amount: "12.99", rate: "0.15", output: "1.9485"},
+		{amount: "2.90", rate: "0", output: "0.00"},
+		{amount: "0.01", rate: "0.000001", output: "0.00000001"},
+	}
+
+	for id, test := range tests {
+		actual := MustParse(test.amount).Mul(MustParseRate(test.rate))
+
+		assert.True(t, test.output == actual.String(), "~2|Test #%d expected product: %s, not product %s~", id, test.output, actual)
+	}
+}
+
+func Test_Decimal_Sub(t *testing.T) {
+	tests := []struct {
+		rounded string
+		exact   string
+		output  string
+		cmp     int
+	}{
+		{rounded: "165.62", exact: "165.6225", output: "-0.0025", cmp: -1},
+		{rounded: "41.33", exact: "41.325", output: "0.005", cmp: 1},
+		{rounded: "194.85", exact: "194.850000", output: "0.00", cmp: 0},
+	}
+
+	for id, test := range tests {
+		rounded := MustParse(test.rounded).Decimal()
+		exact := MustParseDecimal(test.exact)
+
+		actual := rounded.Sub(exact)
+		assert.True(t, test.output == actual.String(), "~2|Test #%d expected difference: %s, not difference %s~", id, test.output, actual)
+		assert.True(t, test.cmp == rounded.Cmp(exact), "~2|Test #%d expected comparison: %d, not comparison %d~", id, test.cmp, rounded.Cmp(exact))
+	}
+
+	var zero Decimal
+	assert.True(t, zero.String() == "0.00", "~2|Test expected zero: 0.00, not %s~", zero)
+}
+
+func Test_DecimalJSON(t *testing.T) {
+	data, _ := json.Marshal(struct {
+		Exact Decimal `json:"exact"`
+	}{Exact: Exact(MustParse("12.99"), 15, MustParseRate("0.15"), MustParseRate("1"))})
+
+	assert.True(t, string(data) == `{"exact":165.6225}`, "~2|Test expected json: {\"exact\":165.6225}, not %s~", data)
+
+	var decoded struct {
+		Exact Decimal `json:"exact"`
+	}
+	err := json.Unmarshal(data, &decoded)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+	assert.True(t, decoded.Exact.String() == "165.6225", "~2|Test expected decoded: 165.6225, not %s~", decoded.Exact)
+}
@@ -0,0 +1,305 @@
+// Package money implements exact decimal arithmetic for prices and discount
+// rates, so totals never pass through float64.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinorUnits is the number of decimal places held by an Amount.
+	MinorUnits = 2
+	// RatePlaces is the number of decimal places held by a Rate.
+	RatePlaces = 6
+
+	minorScale = 100
+	// RateScale is the Rate value of 100%.
+	RateScale Rate = 1000000
+)
+
+var (
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrInvalidRate     = errors.New("invalid rate")
+	ErrInvalidRounding = errors.New("invalid rounding mode")
+	ErrInvalidDecimal  = errors.New("invalid decimal")
+)
+
+// Amount is a money amount held as an integer number of minor units (cents).
+type Amount int64
+
+// FromMinor returns the Amount of the given number of minor units.
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// Parse reads a decimal string such as "12.99" without going through float64.
+// More than MinorUnits decimal places is an error rather than being rounded.
+func Parse(s string) (a Amount, err error) {
+	v, err := parseDecimal(s, MinorUnits)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidAmount, s)
+	}
+
+	return Amount(v), nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for constants and tests.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return a + b
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return a - b
+}
+
+func (a Amount) String() string {
+	return formatDecimal(int64(a), MinorUnits, false)
+}
+
+// MarshalJSON encodes the amount as a JSON number with exactly MinorUnits
+// decimal places, e.g. 194.85.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (a *Amount) UnmarshalJSON(data []byte) (err error) {
+	*a, err = Parse(strings.Trim(string(data), `"`))
+	return err
+}
+
+// Rate is a fraction such as a discount or an exchange rate, held in
+// millionths.
+type Rate int64
+
+// ParseRate reads a decimal fraction such as "0.15" without going through float64.
+func ParseRate(s string) (r Rate, err error) {
+	v, err := parseDecimal(s, RatePlaces)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidRate, s)
+	}
+
+	return Rate(v), nil
+}
+
+// MustParseRate is like ParseRate but panics on error.
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func (r Rate) String() string {
+	return formatDecimal(int64(r), RatePlaces, true)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) (err error) {
+	*r, err = ParseRate(strings.Trim(string(data), `"`))
+	return err
+}
+
+// Rounding selects how an exact result is brought back to whole minor units.
+type Rounding int
+
+const (
+	// HalfUp rounds halves away from zero: 41.325 becomes 41.33.
+	HalfUp Rounding = iota
+	// HalfEven rounds halves to the even neighbour (banker's rounding): 41.325 becomes 41.32.
+	HalfEven
+)
+
+// ParseRounding accepts "half-up" or "half-even".
+func ParseRounding(s string) (r Rounding, err error) {
+	switch s {
+	case "half-up":
+		return HalfUp, nil
+	case "half-even":
+		return HalfEven, nil
+	}
+
+	return HalfUp, fmt.Errorf("%w %q, expected half-up or half-even", ErrInvalidRounding, s)
+}
+
+func (r Rounding) String() string {
+	if r == HalfEven {
+		return "half-even"
+	}
+
+	return "half-up"
+}
+
+// MarshalJSON encodes the rounding mode by name, e.g. "half-even".
+func (r Rounding) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON accepts the names ParseRounding does.
+func (r *Rounding) UnmarshalJSON(data []byte) (err error) {
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("%w %s, expected half-up or half-even", ErrInvalidRounding, data)
+	}
+
+	*r, err = ParseRounding(s)
+	return err
+}
+
+// Discounted returns price * qty * (1 - discount), rounded to whole minor
+// units. It is Converted at a rate of 1.
+func (r Rounding) Discounted(price Amount, qty int, discount Rate) Amount {
+	return r.Converted(price, qty, discount, RateScale, MinorUnits)
+}
+
+// Converted returns price * qty * (1 - discount) * fx for a currency with
+// minorUnits decimal places, between 0 and MinorUnits. The product is
+// computed exactly and rounded once, here, to whole minor units of that
+// currency. This is the only place where pricing rounds.
+func (r Rounding) Converted(price Amount, qty int, discount Rate, fx Rate, minorUnits int) Amount {
+	step := int64(1)
+	for i := minorUnits; i < MinorUnits; i++ {
+		step *= 10
+	}
+
+	num := exact(price, qty, discount, fx)
+
+	den := big.NewInt(int64(RateScale))
+	den.Mul(den, big.NewInt(int64(RateScale)))
+	den.Mul(den, big.NewInt(step))
+
+	return Amount(r.divide(num, den) * step)
+}
+
+// Exact returns price * qty * (1 - discount) * fx without rounding it: the
+// value that Converted rounds.
+func Exact(price Amount, qty int, discount Rate, fx Rate) Decimal {
+	return Decimal{unscaled: exact(price, qty, discount, fx), scale: MinorUnits + 2*RatePlaces}
+}
+
+// exact returns price * qty * (1 - discount) * fx in units of
+// 10^-(MinorUnits+2*RatePlaces).
+func exact(price Amount, qty int, discount Rate, fx Rate) *big.Int {
+	num := big.NewInt(int64(price))
+	num.Mul(num, big.NewInt(int64(qty)))
+	num.Mul(num, big.NewInt(int64(RateScale-discount)))
+	num.Mul(num, big.NewInt(int64(fx)))
+
+	return num
+}
+
+// CrossRate returns the rate from one currency to another given both their
+// rates against a common base, rounded half up to RatePlaces.
+func CrossRate(from Rate, to Rate) Rate {
+	num := big.NewInt(int64(to))
+	num.Mul(num, big.NewInt(int64(RateScale)))
+
+	return Rate(HalfUp.divide(num, big.NewInt(int64(from))))
+}
+
+func (r Rounding) divide(num *big.Int, den *big.Int) int64 {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo.Int64()
+	}
+
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+
+	away := false
+	switch twice.Cmp(new(big.Int).Abs(den)) {
+	case 1:
+		away = true
+	case 0:
+		away = r == HalfUp || quo.Bit(0) == 1
+	}
+
+	if away {
+		if num.Sign()*den.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	return quo.Int64()
+}
+
+func parseDecimal(s string, places int) (v int64, err error) {
+	s = strings.TrimSpace(s)
+
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	if len(frac) > places {
+		return 0, ErrInvalidAmount
+	}
+	frac += strings.Repeat("0", places-len(frac))
+
+	digits := whole + frac
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	v, err = strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		v = -v
+	}
+
+	return v, nil
+}
+
+func formatDecimal(v int64, places int, trim bool) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-v)
+	}
+
+	s := strconv.FormatUint(u, 10)
+	if len(s) <= places {
+		s = strings.Repeat("0", places-len(s)+1) + s
+	}
+
+	whole, frac := s[:len(s)-places], s[len(s)-places:]
+	if trim {
+		frac = strings.TrimRight(frac, "0")
+	}
+	if frac == "" {
+		return sign + whole
+	}
+
+	return sign + whole + "." + frac
+}
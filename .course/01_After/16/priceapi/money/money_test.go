@@ -0,0 +1,214 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		input  string
+		minor  int64
+		output string
+		err    bool
+	}{
+		{input: "12.99", minor: 1299, output: "12.99"},
+		{input: "12.9", minor: 1290, output: "12.90"},
+		{input: " 12 ", minor: 1200, output: "12.00"},
+		{input: "-0.05", minor: -5, output: "-0.05"},
+		{input: ".5", minor: 50, output: "0.50"},
+		{input: "12.999", err: true},
+		{input: "abc", err: true},
+		{input: "1e3", err: true},
+		{input: "", err: true},
+	}
+
+	for id, test := range tests {
+		actual, err := Parse(test.input)
+		assert.True(t, test.err == (err != nil), "~2|Test #%d expected failure: %t, not error %v~", id, test.err, err)
+		if err != nil {
+			continue
+		}
+
+		assert.True(t, test.minor == actual.Minor(), "~2|Test #%d expected minor units: %d, not %d~", id, test.minor, actual.Minor())
+		assert.True(t, test.output == actual.String(), "~2|Test #%d expected string: %s, not %s~", id, test.output, actual)
+	}
+}
+
+func Test_ParseRate(t *testing.T) {
+	tests := []struct {
+		input  string
+		rate   Rate
+		output string
+		err    bool
+	}{
+		{input: "0.15", rate: 150000, output: "0.15"},
+		{input: "1", rate: RateScale, output: "1"},
+		{input: "-0.1", rate: -100000, output: "-0.1"},
+		{input: "0.1234567", err: true},
+		{input: "ten", err: true},
+	}
+
+	for id, test := range tests {
+		actual, err := ParseRate(test.input)
+		assert.True(t, test.err == (err != nil), "~2|Test #%d expected failure: %t, not error %v~", id, test.err, err)
+		if err != nil {
+			continue
+		}
+
+		assert.True(t, test.rate == actual, "~2|Test #%d expected rate: %d, not %d~", id, test.rate, actual)
+		assert.True(t, test.output == actual.String(), "~2|Test #%d expected string: %s, not %s~", id, test.output, actual)
+	}
+}
+
+func Test_Discounted(t *testing.T) {
+	tests := []struct {
+		rounding Rounding
+		price    string
+		qty      int
+		discount string
+		total    string
+	}{
+		{rounding: HalfUp, price: "12.99", qty: 15, discount: "0", total: "194.85"},
+		{rounding: HalfUp, price: "12.99", qty: 15, discount: "0.15", total: "165.62"},
+		{rounding: HalfUp, price: "2.90", qty: 15, discount: "0.05", total: "41.33"},
+		{rounding: HalfEven, price: "2.90", qty: 15, discount: "0.05", total: "41.32"},
+		{rounding: HalfUp, price: "0.10", qty: 3, discount: "0.15", total: "0.26"},
+		{rounding: HalfEven, price: "0.10", qty: 5, discount: "0.15", total: "0.42"},
+		{rounding: HalfUp, price: "0.10", qty: 5, discount: "0.15", total: "0.43"},
+		{rounding: HalfUp, price: "99999.99", qty: 1000000000, discount: "0.333333", total: "66666693333330.00"},
+	}
+
+	for id, test := range tests {
+		actual := test.rounding.Discounted(MustParse(test.price), test.qty, MustParseRate(test.discount))
+
+		assert.True(t, test.total == actual.String(), "~2|Test #%d expected total: %s, not total %s~", id, test.total, actual)
+	}
+}
+
+func Test_Converted(t *testing.T) {
+	tests := []struct {
+		rounding   Rounding
+		price      string
+		qty        int
+		discount   string
+		fx         string
+		minorUnits int
+		total      string
+	}{
+		{rounding: HalfUp, price: "12.99", qty: 15, discount: "0", fx: "1", minorUnits: 2, total: "194.85"},
+		{rounding: HalfUp, price: "12.99", qty: 15, discount: "0", fx: "0.92", minorUnits: 2, total: "179.26"},
+		{rounding: HalfUp, price: "12.99", qty: 15, discount: "0.15", fx: "0.92", minorUnits: 2, total: "152.37"},
+		{rounding: HalfUp, price: "12.99", qty: 15, discount: "0", fx: "151.3", minorUnits: 0, total: "29481.00"},
+		{rounding: HalfUp, price: "2.90", qty: 15, discount: "0.05", fx: "1", minorUnits: 0, total: "41.00"},
+		{rounding: HalfUp, price: "0.10", qty: 5, discount: "0", fx: "1", minorUnits: 0, total: "1.00"},
+		{rounding: HalfEven, price: "0.10", qty: 5, discount: "0", fx: "1", minorUnits: 0, total: "0.00"},
+		{rounding: HalfUp, price: "1.00", qty: 1, discount: "0", fx: "0.125", minorUnits: 1, total: "0.10"},
+		{rounding: HalfUp, price: "1.00", qty: 1, discount: "0", fx: "0.15", minorUnits: 1, total: "0.20"},
+	}
+
+	for id, test := range tests {
+		actual := test.rounding.Converted(MustParse(test.price), test.qty, MustParseRate(test.discount), MustParseRate(test.fx), test.minorUnits)
+
+		assert.True(t, test.total == actual.String(), "~2|Test #%d expected total: %s, not total %s~", id, test.total, actual)
+	}
+}
+
+func Test_Exact(t *testing.T) {
+	tests := []struct {
+		price    string
+		qty      int
+		discount string
+		fx       string
+		exact    string
+	}{
+		{price: "12.99", qty: 15, discount: "0", fx: "1", exact: "194.85"},
+		{price: "12.99", qty: 15, discount: "0", fx: "0.92", exact: "179.262"},
+		{price: "12.99", qty: 15, discount: "0.15", fx: "0.92", exact: "152.3727"},
+		{price: "12.99", qty: 15, discount: "0", fx: "151.3", exact: "29480.805"},
+		{price: "2.90", qty: 15, discount: "0.05", fx: "1", exact: "41.325"},
+	}
+
+	for id, test := range tests {
+		actual := Exact(MustParse(test.price), test.qty, MustParseRate(test.discount), MustParseRate(test.fx))
+
+		assert.True(t, test.exact == actual.String(), "~2|Test #%d expected exact total: %s, not exact total %s~", id, test.exact, actual)
+	}
+}
+
+func Test_CrossRate(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		rate string
+	}{
+		{from: "1", to: "0.92", rate: "0.92"},
+		{from: "0.92", to: "1", rate: "1.086957"},
+		{from: "0.92", to: "151.3", rate: "164.456522"},
+		{from: "0.79", to: "0.79", rate: "1"},
+	}
+
+	for id, test := range tests {
+		actual := CrossRate(MustParseRate(test.from), MustParseRate(test.to))
+
+		assert.True(t, test.rate == actual.String(), "~2|Test #%d expected rate: %s, not rate %s~", id, test.rate, actual)
+	}
+}
+
+func Test_AmountJSON(t *testing.T) {
+	data, _ := json.Marshal(struct {
+		Total    Amount `json:"total"`
+		Discount Rate   `json:"discount"`
+	}{MustParse("194.85"), MustParseRate("0.15")})
+
+	expected := `{"total":194.85,"discount":0.15}`
+	assert.True(t, expected == string(data), "~2|Test expected json: %s, not json %s~", expected, data)
+
+	var actual struct {
+		Total Amount `json:"total"`
+	}
+	err := json.Unmarshal([]byte(`{"total":"10.5"}`), &actual)
+	assert.True(t, err == nil && actual.Total == FromMinor(1050), "~2|Test expected total: 10.50, not total %s (%v)~", actual.Total, err)
+}
+
+func Test_ParseRounding(t *testing.T) {
+	r, err := ParseRounding("half-even")
+	assert.True(t, err == nil && r == HalfEven, "~2|Test expected rounding: half-even, not %s~", r)
+
+	_, err = ParseRounding("down")
+	assert.True(t, err != nil, "~2|Test expected an error for an unknown rounding mode~")
+}
+
+func Test_RoundingJSON(t *testing.T) {
+	data, _ := json.Marshal(struct {
+		Rounding Rounding `json:"rounding"`
+	}{HalfEven})
+
+	expected := `{"rounding":"half-even"}`
+	assert.True(t, expected == string(data), "~2|Test expected json: %s, not json %s~", expected, data)
+
+	tests := []struct {
+		data     string
+		rounding Rounding
+		failed   bool
+	}{
+		{data: `{"rounding":"half-even"}`, rounding: HalfEven},
+		{data: `{"rounding":"half-up"}`, rounding: HalfUp},
+		{data: `{"rounding":"down"}`, failed: true},
+		{data: `{"rounding":1}`, failed: true},
+	}
+
+	for id, test := range tests {
+		var actual struct {
+			Rounding Rounding `json:"rounding"`
+		}
+		err := json.Unmarshal([]byte(test.data), &actual)
+
+		assert.True(t, test.failed == (err != nil), "~2|Test #%d expected failure: %t, not error %v~", id, test.failed, err)
+		if !test.failed {
+			assert.True(t, test.rounding == actual.Rounding, "~2|Test #%d expected rounding: %s, not rounding %s~", id, test.rounding, actual.Rounding)
+		}
+	}
+}
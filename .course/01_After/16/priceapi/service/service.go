@@ -1,8 +1,147 @@
 package service
 
-import "context"
+import (
+	"context"
+	"time"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+)
+
+// PricingService prices quantities of a product at retail or for a
+// partner, and whole quotes, as its options say.
 type PricingService interface {
-	GetRetailTotal(ctx context.Context, code string, qty int) (total float64, err error)
-	GetWholesaleTotal(ctx context.Context, partner string, code string, qty int) (total float64, err error)
+	GetRetailTotal(ctx context.Context, code string, qty int, opts RetailOptions) (price Price, err error)
+	GetWholesaleTotal(ctx context.Context, partner string, code string, qty int, opts PriceOptions) (price Price, err error)
+	GetQuote(ctx context.Context, partner string, currency string, region string, lines []QuoteLine) (quote Quote, err error)
+}
+
+// PriceOptions say how a price is worked out. It is priced at the instant
+// AsOf; a zero AsOf means now. It is converted into Currency, or left in
+// the product's own currency when Currency is blank, and taxed at the rates
+// of Region, or left untaxed when Region is blank. With Explain set the
+// price carries a Breakdown of its total.
+type PriceOptions struct {
+	AsOf     time.Time
+	Currency string
+	Region   string
+	Explain  bool
+}
+
+// RetailOptions are the PriceOptions of a retail price and the Coupon, if
+// any, whose promotion is taken off its total before tax. A RedemptionKey
+// uses the coupon up once under that key; without one the coupon is only
+// checked.
+type RetailOptions struct {
+	PriceOptions
+	Coupon        string
+	RedemptionKey string
+}
+
+// The errors of the pricing service, as rebuilt from the codes of its error
+// responses.
+var (
+	ErrInvalidPartner  = &Error{Code: INVALID_PARTNER, Msg: "Invalid Partner Requested"}
+	ErrPartnerNotFound = &Error{Code: PARTNER_NOT_FOUND, Msg: "Partner Not Found"}
+	ErrInvalidCode     = &Error{Code: INVALID_CODE, Msg: "Invalid Code Requested"}
+	ErrCodeNotFound    = &Error{Code: CODE_NOT_FOUND, Msg: "Code Not Found"}
+	ErrInvalidQty      = &Error{Code: INVALID_QTY, Msg: "Invalid Quantity Requested"}
+	ErrRepoUnavailable = &Error{Code: REPO_UNAVAILABLE, Msg: "Repository Unavailable"}
+	ErrEmptyQuote      = &Error{Code: EMPTY_QUOTE, Msg: "Empty Quote Requested"}
+	ErrNoPriceTier     = &Error{Code: NO_PRICE_TIER, Msg: "No Price Tier For Quantity"}
+	ErrUnknownCurrency = &Error{Code: UNKNOWN_CURRENCY, Msg: "Unknown Currency"}
+	ErrUnknownRegion   = &Error{Code: UNKNOWN_REGION, Msg: "Unknown Tax Region"}
+	ErrNoTaxRate       = &Error{Code: NO_TAX_RATE, Msg: "No Tax Rate For Product"}
+
+	ErrUnknownCoupon       = &Error{Code: UNKNOWN_COUPON, Msg: "Unknown Coupon"}
+	ErrExpiredCoupon       = &Error{Code: COUPON_EXPIRED, Msg: "Expired Coupon"}
+	ErrCouponNotStarted    = &Error{Code: COUPON_NOT_STARTED, Msg: "Coupon Not Yet Valid"}
+	ErrCouponUsedUp        = &Error{Code: COUPON_USED_UP, Msg: "Coupon Usage Limit Reached"}
+	ErrCouponNotApplicable = &Error{Code: COUPON_NOT_APPLICABLE, Msg: "Coupon Not Applicable"}
+)
+
+// Tier is the unit price that applies from MinQty up to and including
+// MaxQty. A MaxQty of 0 means the tier has no upper bound.
+type Tier struct {
+	MinQty int
+	MaxQty int
+	Price  money.Amount
+}
+
+// Price is a priced quantity of one product: the tier picked for the
+// quantity, the discount applied to its unit price and the rounded total.
+// NetPriced is set when a partner's fixed net price was used instead; Tier and
+// Discount are then left empty. Amounts are in Currency, converted from the
+// product's currency at FxRate. Total is after any Promotion and before
+// tax; Net is the same amount as taxed, and Tax is charged on it at TaxRate
+// for Region, giving Gross.
+type Price struct {
+	UnitPrice money.Amount
+	Tier      Tier
+	Discount  money.Rate
+	NetPriced bool
+	Currency  string
+	FxRate    money.Rate
+	Promotion AppliedPromotion
+	Total     money.Amount
+	Net       money.Amount
+	Region    string
+	TaxRate   money.Rate
+	Tax       money.Amount
+	Gross     money.Amount
+	Breakdown *Breakdown
+}
+
+// Breakdown shows how the total of a price was worked out, so that it can
+// be checked by hand. ExactTotal is UnitPrice * Qty * (1 - Discount) *
+// FxRate before rounding; Adjustment is what Rounding added to it to give
+// LineTotal, and CouponSaving what Coupon took off LineTotal to give Total,
+// the total before tax.
+type Breakdown struct {
+	UnitPrice      money.Amount
+	Discount       money.Rate
+	UnitSaving     money.Decimal
+	Qty            int
+	FxRate         money.Rate
+	ExactTotal     money.Decimal
+	Rounding       money.Rounding
+	Adjustment     money.Decimal
+	LineTotal      money.Amount
+	Coupon         string
+	CouponSaving   money.Amount
+	Total          money.Amount
+	CatalogVersion int
+}
+
+// AppliedPromotion is the saving a coupon took off a price, and why.
+type AppliedPromotion struct {
+	Coupon string
+	Saving money.Amount
+	Reason string
+}
+
+type QuoteLine struct {
+	Code string
+	Qty  int
+}
+
+// QuotedLine is a priced QuoteLine. Err is set instead of the Price when
+// the line could not be priced; such lines are left out of the quote total.
+type QuotedLine struct {
+	Code string
+	Qty  int
+	Price
+	Err error
+}
+
+// Quote totals add up the lines that could be priced: Total before tax,
+// the same amount as Net, Tax and Gross.
+type Quote struct {
+	Partner  string
+	Currency string
+	Region   string
+	Lines    []QuotedLine
+	Total    money.Amount
+	Net      money.Amount
+	Tax      money.Amount
+	Gross    money.Amount
 }
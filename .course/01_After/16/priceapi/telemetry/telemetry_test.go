@@ -0,0 +1,214 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStub is an OTLP/HTTP collector that keeps the spans it is sent.
+type collectorStub struct {
+	mu        sync.Mutex
+	resources []map[string]string
+	spans     []*tracepb.Span
+}
+
+func newCollectorStub() (cs *collectorStub, server *httptest.Server) {
+	cs = new(collectorStub)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/traces", cs.export)
+
+	return cs, httptest.NewServer(mux)
+}
+
+func (cs *collectorStub) export(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cs.mu.Lock()
+	for _, rs := range req.ResourceSpans {
+		attrs := make(map[string]string)
+		for _, attr := range rs.Resource.GetAttributes() {
+			attrs[attr.Key] = attr.Value.GetStringValue()
+		}
+		cs.resources = append(cs.resources, attrs)
+
+		for _, ss := range rs.ScopeSpans {
+			cs.spans = append(cs.spans, ss.Spans...)
+		}
+	}
+	cs.mu.Unlock()
+
+	resp, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+func (cs *collectorStub) received() (resources []map[string]string, spans []*tracepb.Span) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.resources, cs.spans
+}
+
+func Test_Config_Validate(t *testing.T) {
+	tests := []struct {
+		exporter string
+		sampler  string
+		ratio    float64
+		valid    bool
+	}{
+		{exporter: EXPORTER_NONE, sampler: SAMPLER_ALWAYS, ratio: 1, valid: true},
+		{exporter: EXPORTER_FILE, sampler: SAMPLER_RATIO, ratio: 0.25, valid: true},
+		{exporter: EXPORTER_STDOUT, sampler: SAMPLER_PARENT_BASED, ratio: 0, valid: true},
+		{exporter: EXPORTER_OTLP_HTTP, sampler: SAMPLER_PARENT_BASED, ratio: 1, valid: true},
+		{exporter: "jaeger", sampler: SAMPLER_ALWAYS, ratio: 1, valid: false},
+		{exporter: EXPORTER_NONE, sampler: "never", ratio: 1, valid: false},
+		{exporter: EXPORTER_NONE, sampler: SAMPLER_RATIO, ratio: 1.5, valid: false},
+	}
+
+	for id, test := range tests {
+		err := Config{Exporter: test.exporter, Sampler: test.sampler, Ratio: test.ratio}.Validate()
+		assert.True(t, test.valid == (err == nil), "~2|Test #%d expected valid: %t, not error %v~", id, test.valid, err)
+	}
+}
+
+func Test_NewTracerProvider_OTLP(t *testing.T) {
+	collector, server := newCollectorStub()
+	defer server.Close()
+
+	config := Config{
+		ServiceName: "PriceService",
+		Environment: "test",
+		Exporter:    EXPORTER_OTLP_HTTP,
+		Endpoint:    strings.TrimPrefix(server.URL, "http://"),
+		Insecure:    true,
+		Sampler:     SAMPLER_ALWAYS,
+	}
+
+	tp, shutdown, err := NewTracerProvider(context.Background(), config)
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	_, span := tp.Tracer("Telemetry.Test").Start(context.Background(), "GetRetailTotal")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	resources, spans := collector.received()
+	assert.True(t, len(spans) == 1, "~2|Test expected 1 span, not %d~", len(spans))
+	if len(spans) == 1 {
+		assert.True(t, spans[0].Name == "GetRetailTotal", "~2|Test expected name: GetRetailTotal, not name %s~", spans[0].Name)
+	}
+
+	assert.True(t, len(resources) == 1, "~2|Test expected 1 resource, not %d~", len(resources))
+	if len(resources) == 1 {
+		assert.True(t, resources[0]["service.name"] == "PriceService", "~2|Test expected service: PriceService, not service %s~", resources[0]["service.name"])
+		assert.True(t, resources[0]["deployment.environment"] == "test", "~2|Test expected environment: test, not environment %s~", resources[0]["deployment.environment"])
+		assert.True(t, resources[0]["service.version"] == Version(), "~2|Test expected version: %s, not version %s~", Version(), resources[0]["service.version"])
+	}
+}
+
+func Test_NewTracerProvider_FileAppends(t *testing.T) {
+	config := Config{
+		ServiceName: "PriceService",
+		Exporter:    EXPORTER_FILE,
+		File:        filepath.Join(t.TempDir(), "traces.txt"),
+		Sampler:     SAMPLER_ALWAYS,
+	}
+
+	for run := 0; run < 2; run++ {
+		tp, shutdown, err := NewTracerProvider(context.Background(), config)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		_, span := tp.Tracer("Telemetry.Test").Start(context.Background(), "GetRetailTotal")
+		span.End()
+
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+	}
+
+	f, err := os.Open(config.File)
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+	defer f.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		lines++
+	}
+
+	assert.True(t, lines == 2, "~2|Test expected a span from each run, not %d spans~", lines)
+}
+
+func Test_NewTracerProvider_Sampler(t *testing.T) {
+	sampledParent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	tests := []struct {
+		sampler string
+		ratio   float64
+		parent  trace.SpanContext
+		sampled bool
+	}{
+		{sampler: SAMPLER_ALWAYS, ratio: 0, sampled: true},
+		{sampler: SAMPLER_RATIO, ratio: 0, sampled: false},
+		{sampler: SAMPLER_RATIO, ratio: 1, sampled: true},
+		{sampler: SAMPLER_RATIO, ratio: 0, parent: sampledParent, sampled: false},
+		{sampler: SAMPLER_PARENT_BASED, ratio: 0, sampled: false},
+		{sampler: SAMPLER_PARENT_BASED, ratio: 0, parent: sampledParent, sampled: true},
+	}
+
+	for id, test := range tests {
+		tp, shutdown, err := NewTracerProvider(context.Background(), Config{Exporter: EXPORTER_NONE, Sampler: test.sampler, Ratio: test.ratio})
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		ctx := context.Background()
+		if test.parent.IsValid() {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, test.parent)
+		}
+
+		_, span := tp.Tracer("Telemetry.Test").Start(ctx, "GetRetailTotal")
+		sampled := span.SpanContext().IsSampled()
+		span.End()
+		shutdown(context.Background())
+
+		assert.True(t, test.sampled == sampled, "~2|Test #%d expected sampled: %t, not sampled %t~", id, test.sampled, sampled)
+	}
+}
@@ -0,0 +1,366 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+)
+
+const (
+	BALANCE_ROUND_ROBIN       = "round-robin"
+	BALANCE_RANDOM            = "random"
+	BALANCE_LEAST_OUTSTANDING = "least-outstanding"
+	BALANCE_CONSISTENT_HASH   = "consistent-hash"
+
+	HASH_KEY_CODE    = "code"
+	HASH_KEY_PARTNER = "partner"
+)
+
+// BalancerConfig chooses how requests are spread over the instances. With
+// consistent hashing, requests with the same HashKey go to the same
+// instance for as long as it is up.
+type BalancerConfig struct {
+	Strategy string
+	HashKey  string
+}
+
+// DefaultBalancerConfig balances round-robin.
+var DefaultBalancerConfig = BalancerConfig{
+	Strategy: BALANCE_ROUND_ROBIN,
+	HashKey:  HASH_KEY_CODE,
+}
+
+// Validate reports a strategy or hash key that is not known.
+func (c BalancerConfig) Validate() (err error) {
+	switch c.Strategy {
+	case BALANCE_ROUND_ROBIN, BALANCE_RANDOM, BALANCE_LEAST_OUTSTANDING, BALANCE_CONSISTENT_HASH:
+	default:
+		return fmt.Errorf("unknown balancer %q, expected %s, %s, %s or %s", c.Strategy,
+			BALANCE_ROUND_ROBIN, BALANCE_RANDOM, BALANCE_LEAST_OUTSTANDING, BALANCE_CONSISTENT_HASH)
+	}
+
+	switch c.HashKey {
+	case HASH_KEY_CODE, HASH_KEY_PARTNER:
+	default:
+		return fmt.Errorf("unknown hash key %q, expected %s or %s", c.HashKey, HASH_KEY_CODE, HASH_KEY_PARTNER)
+	}
+
+	return nil
+}
+
+func (c BalancerConfig) newStrategy() Strategy {
+	switch c.Strategy {
+	case BALANCE_RANDOM:
+		return newRandom(time.Now().UnixNano())
+	case BALANCE_LEAST_OUTSTANDING:
+		return new(leastOutstanding)
+	case BALANCE_CONSISTENT_HASH:
+		return consistentHash{key: c.HashKey}
+	default:
+		return new(roundRobin)
+	}
+}
+
+// Strategy chooses the instance for a request from instances, which are
+// sorted and never empty. outstanding reports the requests in flight to an
+// instance.
+type Strategy interface {
+	Choose(instances []string, request interface{}, outstanding func(instance string) int) (instance string)
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+func (rr *roundRobin) Choose(instances []string, request interface{}, outstanding func(string) int) string {
+	n := atomic.AddUint64(&rr.next, 1) - 1
+	return instances[n%uint64(len(instances))]
+}
+
+type random struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newRandom(seed int64) *random {
+	return &random{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *random) Choose(instances []string, request interface{}, outstanding func(string) int) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return instances[r.rnd.Intn(len(instances))]
+}
+
+// leastOutstanding chooses the instance with the fewest requests in flight.
+// Ties are taken in turn, so that an idle set is still used evenly.
+type leastOutstanding struct {
+	next uint64
+}
+
+func (lo *leastOutstanding) Choose(instances []string, request interface{}, outstanding func(string) int) string {
+	var least []string
+	for i, instance := range instances {
+		load := outstanding(instance)
+		if i > 0 && load > outstanding(least[0]) {
+			continue
+		}
+		if i == 0 || load < outstanding(least[0]) {
+			least = least[:0]
+		}
+		least = append(least, instance)
+	}
+
+	n := atomic.AddUint64(&lo.next, 1) - 1
+	return least[n%uint64(len(least))]
+}
+
+// consistentHash chooses by rendezvous hashing on the key of the request:
+// each instance scores the key and the highest score wins. Removing an
+// instance moves only the keys it held, and adding one takes only the keys
+// it now scores highest on.
+type consistentHash struct {
+	key string
+}
+
+func (ch consistentHash) Choose(instances []string, request interface{}, outstanding func(string) int) string {
+	key := requestKey(request, ch.key)
+
+	best, highest := instances[0], uint64(0)
+	for _, instance := range instances {
+		if score := rendezvousScore(instance, key); score > highest {
+			best, highest = instance, score
+		}
+	}
+
+	return best
+}
+
+func rendezvousScore(instance, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(instance))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	// Mix the bits, as FNV alone scores similar instances alike.
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb3f95a5c03ac
+	x ^= x >> 33
+
+	return x
+}
+
+// requestKey returns the code or the partner of request. Requests without
+// the field asked for use the other: retail prices have no partner, and a
+// quote spans many codes so is keyed by its partner.
+func requestKey(request interface{}, key string) string {
+	switch req := request.(type) {
+	case TotalRetailPriceRequest:
+		return req.Code
+	case TotalWholesalePriceRequest:
+		if key == HASH_KEY_PARTNER {
+			return req.Partner
+		}
+		return req.Code
+	case QuoteRequest:
+		return req.Partner
+	}
+
+	return ""
+}
+
+// balancer is an lb.Balancer that sees the request before choosing an
+// instance, which go-kit's balancers do not. It learns the instances from
+// the factory of an sd.Endpointer, and its endpoint dispatches each call to
+// the instance its strategy chooses, skipping instances the request has
+// already been tried on while others remain.
+type balancer struct {
+	strategy Strategy
+
+	mu          sync.Mutex
+	endpoints   map[string]endpoint.Endpoint
+	instances   []string
+	outstanding map[string]int
+}
+
+func newBalancer(strategy Strategy) (b *balancer) {
+	b = &balancer{
+		strategy:    strategy,
+		endpoints:   make(map[string]endpoint.Endpoint),
+		outstanding: make(map[string]int),
+	}
+
+	return b
+}
+
+// track wraps factory so that the balancer adds each instance it makes an
+// endpoint for and removes it again when the endpoint is closed.
+func (b *balancer) track(factory sd.Factory) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		e, closer, err := factory(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		b.add(instance, e)
+
+		return e, instanceCloser{b: b, instance: instance, closer: closer}, nil
+	}
+}
+
+type instanceCloser struct {
+	b        *balancer
+	instance string
+	closer   io.Closer
+}
+
+func (ic instanceCloser) Close() error {
+	ic.b.remove(ic.instance)
+	if ic.closer != nil {
+		return ic.closer.Close()
+	}
+
+	return nil
+}
+
+func (b *balancer) add(instance string, e endpoint.Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.endpoints[instance] = e
+	b.sortInstances()
+}
+
+func (b *balancer) remove(instance string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.endpoints, instance)
+	b.sortInstances()
+}
+
+func (b *balancer) sortInstances() {
+	b.instances = make([]string, 0, len(b.endpoints))
+	for instance := range b.endpoints {
+		b.instances = append(b.instances, instance)
+	}
+	sort.Strings(b.instances)
+}
+
+// Endpoint returns the dispatching endpoint, or lb.ErrNoEndpoints while
+// there are no instances.
+func (b *balancer) Endpoint() (endpoint.Endpoint, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.instances) == 0 {
+		return nil, lb.ErrNoEndpoints
+	}
+
+	return b.dispatch, nil
+}
+
+func (b *balancer) dispatch(ctx context.Context, request interface{}) (interface{}, error) {
+	tried, _ := ctx.Value(triedKey{}).(*triedInstances)
+
+	instance, e, err := b.choose(request, tried)
+	if err != nil {
+		return nil, err
+	}
+	defer b.done(instance)
+
+	return e(ctx, request)
+}
+
+// choose picks the instance for request and counts the request as in
+// flight to it.
+func (b *balancer) choose(request interface{}, tried *triedInstances) (instance string, e endpoint.Endpoint, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	candidates := tried.untried(b.instances)
+	if len(candidates) == 0 {
+		return "", nil, lb.ErrNoEndpoints
+	}
+
+	instance = b.strategy.Choose(candidates, request, func(instance string) int { return b.outstanding[instance] })
+	tried.add(instance)
+	b.outstanding[instance]++
+
+	return instance, b.endpoints[instance], nil
+}
+
+func (b *balancer) done(instance string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.outstanding[instance]--
+	if b.outstanding[instance] <= 0 {
+		delete(b.outstanding, instance)
+	}
+}
+
+type triedKey struct{}
+
+// triedInstances records the instances a request has been sent to.
+type triedInstances struct {
+	mu        sync.Mutex
+	instances map[string]bool
+}
+
+// untried returns the instances not yet tried, or all of them once every
+// one has been. A nil set has tried none.
+func (t *triedInstances) untried(instances []string) []string {
+	if t == nil {
+		return instances
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	untried := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		if !t.instances[instance] {
+			untried = append(untried, instance)
+		}
+	}
+	if len(untried) == 0 {
+		return instances
+	}
+
+	return untried
+}
+
+func (t *triedInstances) add(instance string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.instances[instance] = true
+}
+
+// retryElsewhere gives each request its own record of the instances tried,
+// so that its retries go to other instances where there are any.
+func retryElsewhere(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ctx = context.WithValue(ctx, triedKey{}, &triedInstances{instances: make(map[string]bool)})
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/discovery"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+)
+
+var balancedInstances = []string{"10.0.0.1:8081", "10.0.0.2:8081", "10.0.0.3:8081", "10.0.0.4:8081"}
+
+func idle(instance string) int {
+	return 0
+}
+
+func Test_BalancerConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config BalancerConfig
+		err    string
+	}{
+		{config: DefaultBalancerConfig},
+		{config: BalancerConfig{Strategy: BALANCE_CONSISTENT_HASH, HashKey: HASH_KEY_PARTNER}},
+		{config: BalancerConfig{Strategy: "sticky", HashKey: HASH_KEY_CODE}, err: "unknown balancer \"sticky\", expected round-robin, random, least-outstanding or consistent-hash"},
+		{config: BalancerConfig{Strategy: BALANCE_RANDOM, HashKey: "region"}, err: "unknown hash key \"region\", expected code or partner"},
+	}
+
+	for id, test := range tests {
+		err := test.config.Validate()
+
+		actualErr := ""
+		if err != nil {
+			actualErr = err.Error()
+		}
+		assert.True(t, test.err == actualErr, "~2|Test #%d expected error: %s, not error %s~", id, test.err, actualErr)
+	}
+}
+
+func Test_Strategy_Distribution(t *testing.T) {
+	tests := []struct {
+		strategy string
+		min      int
+		max      int
+	}{
+		{strategy: BALANCE_ROUND_ROBIN, min: 1000, max: 1000},
+		{strategy: BALANCE_LEAST_OUTSTANDING, min: 1000, max: 1000},
+		{strategy: BALANCE_RANDOM, min: 850, max: 1150},
+		{strategy: BALANCE_CONSISTENT_HASH, min: 850, max: 1150},
+	}
+
+	for id, test := range tests {
+		strategy := BalancerConfig{Strategy: test.strategy, HashKey: HASH_KEY_CODE}.newStrategy()
+
+		counts := make(map[string]int)
+		for i := 0; i < 4000; i++ {
+			request := TotalRetailPriceRequest{Code: fmt.Sprintf("code%04d", i), Qty: 1}
+			counts[strategy.Choose(balancedInstances, request, idle)]++
+		}
+
+		for _, instance := range balancedInstances {
+			count := counts[instance]
+			assert.True(t, count >= test.min && count <= test.max, "~2|Test #%d expected %s to send %d to %d requests to %s, not %d~", id, test.strategy, test.min, test.max, instance, count)
+		}
+	}
+}
+
+func Test_leastOutstanding(t *testing.T) {
+	loads := map[string]int{"10.0.0.1:8081": 3, "10.0.0.2:8081": 1, "10.0.0.3:8081": 0, "10.0.0.4:8081": 0}
+	outstanding := func(instance string) int { return loads[instance] }
+
+	strategy := new(leastOutstanding)
+
+	counts := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		counts[strategy.Choose(balancedInstances, nil, outstanding)]++
+	}
+
+	expected := map[string]int{"10.0.0.3:8081": 5, "10.0.0.4:8081": 5}
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(counts), "~2|Test expected choices: %v, not choices %v~", expected, counts)
+}
+
+func Test_ConsistentHash_Remapping(t *testing.T) {
+	tests := []struct {
+		key     string
+		request func(i int) interface{}
+	}{
+		{key: HASH_KEY_CODE, request: func(i int) interface{} { return TotalRetailPriceRequest{Code: fmt.Sprintf("code%04d", i)} }},
+		{key: HASH_KEY_CODE, request: func(i int) interface{} {
+			return TotalWholesalePriceRequest{Partner: "superstore", Code: fmt.Sprintf("code%04d", i)}
+		}},
+		{key: HASH_KEY_PARTNER, request: func(i int) interface{} {
+			return TotalWholesalePriceRequest{Partner: fmt.Sprintf("partner%04d", i), Code: "aaa111"}
+		}},
+		{key: HASH_KEY_CODE, request: func(i int) interface{} { return QuoteRequest{Partner: fmt.Sprintf("partner%04d", i)} }},
+	}
+
+	removed := balancedInstances[1]
+	remaining := []string{balancedInstances[0], balancedInstances[2], balancedInstances[3]}
+
+	for id, test := range tests {
+		strategy := consistentHash{key: test.key}
+
+		moved, held := 0, 0
+		for i := 0; i < 1000; i++ {
+			request := test.request(i)
+
+			before := strategy.Choose(balancedInstances, request, idle)
+			again := strategy.Choose(balancedInstances, request, idle)
+			after := strategy.Choose(remaining, request, idle)
+
+			assert.True(t, before == again, "~2|Test #%d expected request %d to stay on %s, not move to %s~", id, i, before, again)
+
+			if before == removed {
+				held++
+				continue
+			}
+			if before != after {
+				moved++
+			}
+		}
+
+		assert.True(t, moved == 0, "~2|Test #%d expected no requests to move off the remaining instances, not %d~", id, moved)
+		assert.True(t, held > 150 && held < 350, "~2|Test #%d expected about 250 requests on the removed instance, not %d~", id, held)
+	}
+}
+
+func Test_PricingServiceProxy_ConsistentHash(t *testing.T) {
+	var hitsA, hitsB int32
+	upstreamA, upstreamB := newUpstream(&hitsA, false), newUpstream(&hitsB, true)
+	defer upstreamA.Close()
+	defer upstreamB.Close()
+
+	instanceA, instanceB := strings.TrimPrefix(upstreamA.URL, "http://"), strings.TrimPrefix(upstreamB.URL, "http://")
+	hashing := consistentHash{key: HASH_KEY_CODE}
+	owner := func(code string) string {
+		return hashing.Choose([]string{instanceA, instanceB}, TotalRetailPriceRequest{Code: code}, idle)
+	}
+
+	// Find a code held by the healthy instance and one held by the one that
+	// is down.
+	var codeA, codeB string
+	for i := 0; codeA == "" || codeB == ""; i++ {
+		code := fmt.Sprintf("code%04d", i)
+		if owner(code) == instanceA && codeA == "" {
+			codeA = code
+		}
+		if owner(code) == instanceB && codeB == "" {
+			codeB = code
+		}
+	}
+
+	proxy := NewPricingServiceProxy(context.Background(), discovery.NewStaticInstancer([]string{instanceA, instanceB}), BalancerConfig{Strategy: BALANCE_CONSISTENT_HASH, HashKey: HASH_KEY_CODE}, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+	tests := []struct {
+		code  string
+		hitsA int32
+		hitsB int32
+	}{
+		{code: codeA, hitsA: 1, hitsB: 0},
+		{code: codeA, hitsA: 1, hitsB: 0},
+		{code: codeB, hitsA: 1, hitsB: 1},
+	}
+
+	for id, test := range tests {
+		atomic.StoreInt32(&hitsA, 0)
+		atomic.StoreInt32(&hitsB, 0)
+
+		proxy.GetRetailTotal(context.Background(), test.code, 1, service.RetailOptions{})
+
+		assert.True(t, test.hitsA == atomic.LoadInt32(&hitsA), "~2|Test #%d expected %d requests to A, not %d~", id, test.hitsA, hitsA)
+		assert.True(t, test.hitsB == atomic.LoadInt32(&hitsB), "~2|Test #%d expected %d requests to B, not %d~", id, test.hitsB, hitsB)
+	}
+}
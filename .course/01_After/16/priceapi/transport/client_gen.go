@@ -0,0 +1,232 @@
+// Code generated by kitgen from service.go; DO NOT EDIT.
+
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/go-kit/kit/endpoint"
+)
+
+func decodeTotalRetailPriceResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, decodeErrorResponse(r)
+	}
+
+	var response TotalRetailPriceResponse
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		return nil, errInvalidResponse
+	}
+	return response, nil
+}
+
+func decodeTotalWholesalePriceResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, decodeErrorResponse(r)
+	}
+
+	var response TotalWholesalePriceResponse
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		return nil, errInvalidResponse
+	}
+	return response, nil
+}
+
+func decodeQuoteResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, decodeErrorResponse(r)
+	}
+
+	var response QuoteResponse
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		return nil, errInvalidResponse
+	}
+	return response, nil
+}
+
+// PricingServiceClient implements PricingService by calling the endpoint of
+// each method, such as a client of its HTTP route. Errors of the endpoints
+// are returned through proxyError.
+type PricingServiceClient struct {
+	GetRetailTotalEndpoint    endpoint.Endpoint
+	GetWholesaleTotalEndpoint endpoint.Endpoint
+	GetQuoteEndpoint          endpoint.Endpoint
+}
+
+func (c PricingServiceClient) GetRetailTotal(ctx context.Context, code string, qty int, opts service.RetailOptions) (price service.Price, err error) {
+	response, err := c.GetRetailTotalEndpoint(ctx, TotalRetailPriceRequest{
+		Code:          code,
+		Qty:           qty,
+		AsOf:          optional(opts.PriceOptions.AsOf),
+		Currency:      opts.PriceOptions.Currency,
+		Region:        opts.PriceOptions.Region,
+		Explain:       opts.PriceOptions.Explain,
+		Coupon:        opts.Coupon,
+		RedemptionKey: opts.RedemptionKey,
+	})
+	if err != nil {
+		err = proxyError(err)
+		return
+	}
+
+	resp := response.(TotalRetailPriceResponse)
+	price = service.Price{
+		UnitPrice: resp.UnitPrice,
+		Tier:      readTierResponse(required(resp.Tier)),
+		Discount:  resp.Discount,
+		NetPriced: resp.NetPriced,
+		Currency:  resp.Currency,
+		FxRate:    resp.FxRate,
+		Promotion: readAppliedPromotionResponse(required(resp.Promotion)),
+		Total:     resp.Total,
+		Net:       resp.Net,
+		Region:    resp.Region,
+		TaxRate:   resp.TaxRate,
+		Tax:       resp.Tax,
+		Gross:     resp.Gross,
+		Breakdown: mapPtr(resp.Breakdown, readBreakdownResponse),
+	}
+
+	return
+}
+
+func (c PricingServiceClient) GetWholesaleTotal(ctx context.Context, partner string, code string, qty int, opts service.PriceOptions) (price service.Price, err error) {
+	response, err := c.GetWholesaleTotalEndpoint(ctx, TotalWholesalePriceRequest{
+		Partner:  partner,
+		Code:     code,
+		Qty:      qty,
+		AsOf:     optional(opts.AsOf),
+		Currency: opts.Currency,
+		Region:   opts.Region,
+		Explain:  opts.Explain,
+	})
+	if err != nil {
+		err = proxyError(err)
+		return
+	}
+
+	resp := response.(TotalWholesalePriceResponse)
+	price = service.Price{
+		UnitPrice: resp.UnitPrice,
+		Tier:      readTierResponse(required(resp.Tier)),
+		Discount:  resp.Discount,
+		NetPriced: resp.NetPriced,
+		Currency:  resp.Currency,
+		FxRate:    resp.FxRate,
+		Promotion: readAppliedPromotionResponse(required(resp.Promotion)),
+		Total:     resp.Total,
+		Net:       resp.Net,
+		Region:    resp.Region,
+		TaxRate:   resp.TaxRate,
+		Tax:       resp.Tax,
+		Gross:     resp.Gross,
+		Breakdown: mapPtr(resp.Breakdown, readBreakdownResponse),
+	}
+
+	return
+}
+
+func (c PricingServiceClient) GetQuote(ctx context.Context, partner string, currency string, region string, lines []service.QuoteLine) (quote service.Quote, err error) {
+	response, err := c.GetQuoteEndpoint(ctx, QuoteRequest{
+		Partner:  partner,
+		Currency: currency,
+		Region:   region,
+		Lines:    mapSlice(lines, makeQuoteLineRequest),
+	})
+	if err != nil {
+		err = proxyError(err)
+		return
+	}
+
+	resp := response.(QuoteResponse)
+	quote = service.Quote{
+		Partner:  resp.Partner,
+		Currency: resp.Currency,
+		Region:   resp.Region,
+		Lines:    mapSlice(resp.Lines, readQuotedLineResponse),
+		Total:    resp.Total,
+		Net:      resp.Net,
+		Tax:      resp.Tax,
+		Gross:    resp.Gross,
+	}
+
+	return
+}
+
+func readTierResponse(tier TierResponse) service.Tier {
+	return service.Tier{
+		MinQty: tier.MinQty,
+		MaxQty: tier.MaxQty,
+		Price:  tier.Price,
+	}
+}
+
+func readAppliedPromotionResponse(appliedPromotion AppliedPromotionResponse) service.AppliedPromotion {
+	return service.AppliedPromotion{
+		Coupon: appliedPromotion.Coupon,
+		Saving: appliedPromotion.Saving,
+		Reason: appliedPromotion.Reason,
+	}
+}
+
+func readBreakdownResponse(breakdown BreakdownResponse) service.Breakdown {
+	return service.Breakdown{
+		UnitPrice:      breakdown.UnitPrice,
+		Discount:       breakdown.Discount,
+		UnitSaving:     breakdown.UnitSaving,
+		Qty:            breakdown.Qty,
+		FxRate:         breakdown.FxRate,
+		ExactTotal:     breakdown.ExactTotal,
+		Rounding:       breakdown.Rounding,
+		Adjustment:     breakdown.Adjustment,
+		LineTotal:      breakdown.LineTotal,
+		Coupon:         breakdown.Coupon,
+		CouponSaving:   breakdown.CouponSaving,
+		Total:          breakdown.Total,
+		CatalogVersion: breakdown.CatalogVersion,
+	}
+}
+
+func makeQuoteLineRequest(quoteLine service.QuoteLine) QuoteLineRequest {
+	return QuoteLineRequest{
+		Code: quoteLine.Code,
+		Qty:  quoteLine.Qty,
+	}
+}
+
+func readQuotedLineResponse(quotedLine QuotedLineResponse) service.QuotedLine {
+	return service.QuotedLine{
+		Code: quotedLine.Code,
+		Qty:  quotedLine.Qty,
+		Price: service.Price{
+			UnitPrice: quotedLine.UnitPrice,
+			Tier:      readTierResponse(required(quotedLine.Tier)),
+			Discount:  quotedLine.Discount,
+			NetPriced: quotedLine.NetPriced,
+			Currency:  quotedLine.Currency,
+			FxRate:    quotedLine.FxRate,
+			Promotion: readAppliedPromotionResponse(required(quotedLine.Promotion)),
+			Total:     quotedLine.Total,
+			Net:       quotedLine.Net,
+			Region:    quotedLine.Region,
+			TaxRate:   quotedLine.TaxRate,
+			Tax:       quotedLine.Tax,
+			Gross:     quotedLine.Gross,
+			Breakdown: mapPtr(quotedLine.Breakdown, readBreakdownResponse),
+		},
+		Err: readErrorField(quotedLine.Error),
+	}
+}
+
+// readErrorField reads the error carried in a response, nil when there is
+// none.
+func readErrorField(resp *ErrorResponse) error {
+	if resp == nil {
+		return nil
+	}
+
+	return proxyError(resp)
+}
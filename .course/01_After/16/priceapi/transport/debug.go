@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	kittransport "github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+)
+
+// InstanceSet reports the pricing service instances the proxy is balancing
+// over, as *discovery.Set does.
+type InstanceSet interface {
+	Instances() (instances []string, updated time.Time, err error)
+}
+
+// InstancesResponse lists the current instances. Updated is when discovery
+// last reported, and Error is set when that report failed; the instances
+// found before are then still in use.
+type InstancesResponse struct {
+	Instances []string  `json:"instances"`
+	Updated   time.Time `json:"updated"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func MakeInstancesEndpoint(set InstanceSet) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		instances, updated, err := set.Instances()
+
+		resp := InstancesResponse{
+			Instances: instances,
+			Updated:   updated,
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+
+		return resp, nil
+	}
+}
+
+func decodeInstancesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// MakeInstancesHttpHandler serves the debug listing of the current instance
+// set.
+func MakeInstancesHttpHandler(logger log.Logger, set InstanceSet) *httptransport.Server {
+	return httptransport.NewServer(
+		MakeInstancesEndpoint(set),
+		decodeInstancesRequest,
+		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerErrorHandler(kittransport.NewLogErrorHandler(logger)),
+	)
+}
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockInstanceSet struct {
+	instances []string
+	updated   time.Time
+	err       error
+}
+
+func (ms MockInstanceSet) Instances() (instances []string, updated time.Time, err error) {
+	return ms.instances, ms.updated, ms.err
+}
+
+func Test_MakeInstancesHttpHandler(t *testing.T) {
+	updated := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		set      MockInstanceSet
+		response InstancesResponse
+	}{
+		{
+			set:      MockInstanceSet{instances: []string{"localhost:8081", "localhost:8082"}, updated: updated},
+			response: InstancesResponse{Instances: []string{"localhost:8081", "localhost:8082"}, Updated: updated},
+		},
+		{
+			set:      MockInstanceSet{instances: []string{"localhost:8081"}, updated: updated, err: errors.New("instances.txt: line 2: invalid instance")},
+			response: InstancesResponse{Instances: []string{"localhost:8081"}, Updated: updated, Error: "instances.txt: line 2: invalid instance"},
+		},
+	}
+
+	for id, test := range tests {
+		server := httptest.NewServer(MakeInstancesHttpHandler(&MockLogger{}, test.set))
+
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		var actual InstancesResponse
+		json.NewDecoder(resp.Body).Decode(&actual)
+		server.Close()
+
+		assert.True(t, resp.StatusCode == http.StatusOK, "~2|Test #%d expected status: 200, not status %d~", id, resp.StatusCode)
+		assert.True(t, fmt.Sprint(test.response.Instances) == fmt.Sprint(actual.Instances), "~2|Test #%d expected instances: %v, not instances %v~", id, test.response.Instances, actual.Instances)
+		assert.True(t, test.response.Updated.Equal(actual.Updated), "~2|Test #%d expected updated: %v, not updated %v~", id, test.response.Updated, actual.Updated)
+		assert.True(t, test.response.Error == actual.Error, "~2|Test #%d expected error: %s, not error %s~", id, test.response.Error, actual.Error)
+	}
+}
@@ -2,36 +2,161 @@ package transport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"net/http/httptest"
-	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	ErrInvalidPartner  = errors.New("Invalid Partner Requested")
-	ErrPartnerNotFound = errors.New("Partner Not Found")
-	ErrInvalidCode     = errors.New("Invalid Code Requested")
-	ErrCodeNotFound    = errors.New("Code Not Found")
-	ErrInvalidQty      = errors.New("Invalid Quantity Requested")
+	ErrInvalidPartner  = &service.Error{Code: service.INVALID_PARTNER, Msg: "Invalid Partner Requested"}
+	ErrPartnerNotFound = &service.Error{Code: service.PARTNER_NOT_FOUND, Msg: "Partner Not Found"}
+	ErrInvalidCode     = &service.Error{Code: service.INVALID_CODE, Msg: "Invalid Code Requested"}
+	ErrCodeNotFound    = &service.Error{Code: service.CODE_NOT_FOUND, Msg: "Code Not Found"}
+	ErrInvalidQty      = &service.Error{Code: service.INVALID_QTY, Msg: "Invalid Quantity Requested"}
+	ErrEmptyQuote      = &service.Error{Code: service.EMPTY_QUOTE, Msg: "Empty Quote Requested"}
+	ErrUnknownCurrency = &service.Error{Code: service.UNKNOWN_CURRENCY, Msg: "Unknown Currency"}
+	ErrUnknownRegion   = &service.Error{Code: service.UNKNOWN_REGION, Msg: "Unknown Tax Region"}
+	ErrUnknownCoupon   = &service.Error{Code: service.UNKNOWN_COUPON, Msg: "Unknown Coupon"}
+	ErrExpiredCoupon   = &service.Error{Code: service.COUPON_EXPIRED, Msg: "Expired Coupon"}
 )
 
+// decodeResponse decodes a successful response into response, and returns
+// the error envelope of a failed one.
+func decodeResponse(resp *http.Response, response interface{}) (errResp ErrorResponse) {
+	if resp.StatusCode != http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return errResp
+	}
+
+	json.NewDecoder(resp.Body).Decode(response)
+	return ErrorResponse{}
+}
+
+// status reads a test status of 0 as 200 OK.
+func status(status int) int {
+	if status == 0 {
+		return http.StatusOK
+	}
+
+	return status
+}
+
+// envelope returns the JSON of an error envelope, or null when there is none.
+func envelope(errResp *ErrorResponse) string {
+	data, _ := json.Marshal(errResp)
+	return string(data)
+}
+
+// launch is when the mock catalog went on sale; retail prices asked for as
+// of an earlier instant are not found.
+var (
+	launch       = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	beforeLaunch = launch.Add(-time.Hour)
+)
+
+// mockConversion prices the mock catalog in USD, converting into EUR at a
+// fixed rate.
+func mockConversion(currency string) (converted string, fxRate money.Rate, err error) {
+	switch currency {
+	case "", "USD":
+		return "USD", money.RateScale, nil
+	case "EUR":
+		return "EUR", money.MustParseRate("0.92"), nil
+	}
+
+	return "", 0, ErrUnknownCurrency
+}
+
+// mockTax charges tax on the mock catalog in the uk only, at a flat rate.
+func mockTax(price service.Price, region string) (taxed service.Price, err error) {
+	taxed = price
+	taxed.Region = region
+	taxed.Net = price.Total
+	taxed.Gross = price.Total
+
+	switch region {
+	case "":
+		return taxed, nil
+	case "uk":
+		taxed.TaxRate = money.MustParseRate("0.20")
+		taxed.Tax = money.HalfUp.Converted(price.Total, 1, 0, taxed.TaxRate, money.MinorUnits)
+		taxed.Gross = price.Total.Add(taxed.Tax)
+		return taxed, nil
+	}
+
+	return service.Price{}, ErrUnknownRegion
+}
+
+// mockPromotion takes 10% off with the SAVE10 coupon and knows OLD as an
+// expired coupon.
+func mockPromotion(price service.Price, coupon string) (promoted service.Price, err error) {
+	switch coupon {
+	case "":
+		return price, nil
+	case "SAVE10":
+		saving := money.HalfUp.Converted(price.Total, 1, 0, money.MustParseRate("0.10"), money.MinorUnits)
+
+		promoted = price
+		promoted.Promotion = service.AppliedPromotion{Coupon: coupon, Saving: saving, Reason: "10% off"}
+		promoted.Total = price.Total.Sub(saving)
+		return promoted, nil
+	case "OLD":
+		return service.Price{}, fmt.Errorf("%w: %s", ErrExpiredCoupon, coupon)
+	}
+
+	return service.Price{}, fmt.Errorf("%w: %s", ErrUnknownCoupon, coupon)
+}
+
+// mockBreakdown explains a mock line total when explain is set, reading the
+// product from version 3 of the mock catalog.
+func mockBreakdown(explain bool, unitPrice money.Amount, qty int, discount money.Rate, fxRate money.Rate, total money.Amount) (b *service.Breakdown) {
+	if !explain {
+		return nil
+	}
+
+	exact := money.Exact(unitPrice, qty, discount, fxRate)
+
+	return &service.Breakdown{
+		UnitPrice:      unitPrice,
+		Discount:       discount,
+		UnitSaving:     unitPrice.Mul(discount),
+		Qty:            qty,
+		FxRate:         fxRate,
+		ExactTotal:     exact,
+		Rounding:       money.HalfUp,
+		Adjustment:     total.Decimal().Sub(exact),
+		LineTotal:      total,
+		Total:          total,
+		CatalogVersion: 3,
+	}
+}
+
 type MockPricingService struct{}
 
-func (MockPricingService) GetRetailTotal(code string, qty int) (total float64, err error) {
+func (MockPricingService) GetRetailTotal(ctx context.Context, code string, qty int, opts service.RetailOptions) (price service.Price, err error) {
 	if code == "" {
-		return 0.0, ErrInvalidCode
+		return service.Price{}, ErrInvalidCode
 	}
 	if qty <= 0 {
-		return 0.0, ErrInvalidQty
+		return service.Price{}, ErrInvalidQty
+	}
+	if opts.AsOf.Before(launch) && !opts.AsOf.IsZero() {
+		return service.Price{}, ErrCodeNotFound
+	}
+	converted, fxRate, err := mockConversion(opts.Currency)
+	if err != nil {
+		return service.Price{}, err
 	}
 
 	data := []string{
@@ -43,24 +168,41 @@ func (MockPricingService) GetRetailTotal(code string, qty int) (total float64, e
 	for _, line := range data {
 		parts := strings.Split(line, ",")
 		if parts[0] == code {
-			price, _ := strconv.ParseFloat(parts[1], 64)
-
-			return (price * float64(qty)), nil
+			unitPrice := money.MustParse(parts[1])
+			total := money.HalfUp.Converted(unitPrice, qty, 0, fxRate, money.MinorUnits)
+
+			price, err = mockPromotion(service.Price{
+				UnitPrice: unitPrice,
+				Tier:      service.Tier{MinQty: 1, Price: unitPrice},
+				Currency:  converted,
+				FxRate:    fxRate,
+				Total:     total,
+				Breakdown: mockBreakdown(opts.Explain, unitPrice, qty, 0, fxRate, total),
+			}, opts.Coupon)
+			if err != nil {
+				return service.Price{}, err
+			}
+
+			return mockTax(price, opts.Region)
 		}
 	}
 
-	return 0.0, ErrCodeNotFound
+	return service.Price{}, ErrCodeNotFound
 }
 
-func (MockPricingService) GetWholesaleTotal(partner, code string, qty int) (total float64, err error) {
+func (MockPricingService) GetWholesaleTotal(ctx context.Context, partner, code string, qty int, opts service.PriceOptions) (price service.Price, err error) {
 	if partner == "" {
-		return 0.0, ErrInvalidPartner
+		return service.Price{}, ErrInvalidPartner
 	}
 	if code == "" {
-		return 0.0, ErrInvalidCode
+		return service.Price{}, ErrInvalidCode
 	}
 	if qty <= 0 {
-		return 0.0, ErrInvalidQty
+		return service.Price{}, ErrInvalidQty
+	}
+	converted, fxRate, err := mockConversion(opts.Currency)
+	if err != nil {
+		return service.Price{}, err
 	}
 
 	prices := []string{
@@ -69,18 +211,18 @@ func (MockPricingService) GetWholesaleTotal(partner, code string, qty int) (tota
 		"ccc333,22.50",
 	}
 
-	price := 0.0
+	var unitPrice money.Amount
 	priceFound := false
 	for _, line := range prices {
 		parts := strings.Split(line, ",")
 		if parts[0] == code {
 			priceFound = true
-			price, _ = strconv.ParseFloat(parts[1], 64)
+			unitPrice = money.MustParse(parts[1])
 		}
 	}
 
 	if !priceFound {
-		return 0.0, ErrCodeNotFound
+		return service.Price{}, ErrCodeNotFound
 	}
 
 	partners := []string{
@@ -88,46 +230,142 @@ func (MockPricingService) GetWholesaleTotal(partner, code string, qty int) (tota
 		"joesdiscount,0.05",
 	}
 
-	discount := 0.0
+	var discount money.Rate
 	discountFound := false
 	for _, line := range partners {
 		parts := strings.Split(line, ",")
 		if parts[0] == partner {
 			discountFound = true
-			discount, _ = strconv.ParseFloat(parts[1], 64)
+			discount = money.MustParseRate(parts[1])
 		}
 	}
 
 	if !discountFound {
-		return 0.0, ErrPartnerNotFound
+		return service.Price{}, ErrPartnerNotFound
 	}
 
-	saved := (price * discount)
-	total = (price - saved) * float64(qty)
+	total := money.HalfUp.Converted(unitPrice, qty, discount, fxRate, money.MinorUnits)
+
+	return mockTax(service.Price{
+		UnitPrice: unitPrice,
+		Tier:      service.Tier{MinQty: 1, Price: unitPrice},
+		Discount:  discount,
+		Currency:  converted,
+		FxRate:    fxRate,
+		Total:     total,
+		Breakdown: mockBreakdown(opts.Explain, unitPrice, qty, discount, fxRate, total),
+	}, opts.Region)
+}
+
+func (mps MockPricingService) GetQuote(ctx context.Context, partner string, currency string, region string, lines []service.QuoteLine) (quote service.Quote, err error) {
+	if len(lines) == 0 {
+		return service.Quote{}, ErrEmptyQuote
+	}
+	if quote.Currency, _, err = mockConversion(currency); err != nil {
+		return service.Quote{}, err
+	}
 
-	return math.Round(total*100) / 100, nil
+	if _, err = mockTax(service.Price{}, region); err != nil {
+		return service.Quote{}, err
+	}
+
+	quote.Partner = partner
+	quote.Region = region
+	for _, line := range lines {
+		quoted := service.QuotedLine{Code: line.Code, Qty: line.Qty}
+		if partner == "" {
+			quoted.Price, quoted.Err = mps.GetRetailTotal(ctx, line.Code, line.Qty, service.RetailOptions{PriceOptions: service.PriceOptions{Currency: currency, Region: region}})
+		} else {
+			quoted.Price, quoted.Err = mps.GetWholesaleTotal(ctx, partner, line.Code, line.Qty, service.PriceOptions{Currency: currency, Region: region})
+		}
+
+		quote.Total = quote.Total.Add(quoted.Total)
+		quote.Net = quote.Net.Add(quoted.Net)
+		quote.Tax = quote.Tax.Add(quoted.Tax)
+		quote.Gross = quote.Gross.Add(quoted.Gross)
+		quote.Lines = append(quote.Lines, quoted)
+	}
+
+	return quote, nil
 }
 
 func Test_MakeTotalRetailPriceEndpoint(t *testing.T) {
 	tests := []struct {
 		request  TotalRetailPriceRequest
 		response TotalRetailPriceResponse
+		status   int
+		err      ErrorResponse
 	}{
 		{
-			request:  TotalRetailPriceRequest{Code: "", Qty: 0},
-			response: TotalRetailPriceResponse{Err: "Invalid Code Requested"},
+			request: TotalRetailPriceRequest{Code: "", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_CODE, Message: "Invalid Code Requested"},
 		},
 		{
-			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 0},
-			response: TotalRetailPriceResponse{Err: "Invalid Quantity Requested"},
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_QTY, Message: "Invalid Quantity Requested"},
 		},
 		{
 			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 15},
-			response: TotalRetailPriceResponse{Total: 194.85},
+			response: TotalRetailPriceResponse{Total: money.MustParse("194.85"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "USD", FxRate: money.RateScale, Net: money.MustParse("194.85"), Gross: money.MustParse("194.85")},
+		},
+		{
+			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Currency: "EUR"},
+			response: TotalRetailPriceResponse{Total: money.MustParse("179.26"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "EUR", FxRate: money.MustParseRate("0.92"), Net: money.MustParse("179.26"), Gross: money.MustParse("179.26")},
+		},
+		{
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Currency: "GBP"},
+			status:  http.StatusUnprocessableEntity,
+			err:     ErrorResponse{Code: service.UNKNOWN_CURRENCY, Message: "Unknown Currency"},
+		},
+		{
+			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Region: "uk"},
+			response: TotalRetailPriceResponse{Total: money.MustParse("194.85"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "USD", FxRate: money.RateScale, Region: "uk", TaxRate: money.MustParseRate("0.20"), Net: money.MustParse("194.85"), Tax: money.MustParse("38.97"), Gross: money.MustParse("233.82")},
+		},
+		{
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Region: "mars"},
+			status:  http.StatusUnprocessableEntity,
+			err:     ErrorResponse{Code: service.UNKNOWN_REGION, Message: "Unknown Tax Region"},
+		},
+		{
+			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Coupon: "SAVE10"},
+			response: TotalRetailPriceResponse{Total: money.MustParse("175.36"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "USD", FxRate: money.RateScale, Promotion: &AppliedPromotionResponse{Coupon: "SAVE10", Saving: money.MustParse("19.49"), Reason: "10% off"}, Net: money.MustParse("175.36"), Gross: money.MustParse("175.36")},
+		},
+		{
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Coupon: "OLD"},
+			status:  http.StatusUnprocessableEntity,
+			err:     ErrorResponse{Code: service.COUPON_EXPIRED, Message: "Expired Coupon: OLD"},
+		},
+		{
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Coupon: "NOPE"},
+			status:  http.StatusUnprocessableEntity,
+			err:     ErrorResponse{Code: service.UNKNOWN_COUPON, Message: "Unknown Coupon: NOPE"},
 		},
 		{
-			request:  TotalRetailPriceRequest{Code: "fff000", Qty: 10},
-			response: TotalRetailPriceResponse{Err: "Code Not Found"},
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15, Currency: "EUR", Explain: true},
+			response: TotalRetailPriceResponse{Total: money.MustParse("179.26"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "EUR", FxRate: money.MustParseRate("0.92"), Net: money.MustParse("179.26"), Gross: money.MustParse("179.26"), Breakdown: &BreakdownResponse{
+				UnitPrice:      money.MustParse("12.99"),
+				UnitSaving:     money.MustParseDecimal("0.00"),
+				Qty:            15,
+				FxRate:         money.MustParseRate("0.92"),
+				ExactTotal:     money.MustParseDecimal("179.262"),
+				Rounding:       money.HalfUp,
+				Adjustment:     money.MustParseDecimal("-0.002"),
+				LineTotal:      money.MustParse("179.26"),
+				Total:          money.MustParse("179.26"),
+				CatalogVersion: 3,
+			}},
+		},
+		{
+			request: TotalRetailPriceRequest{Code: "fff000", Qty: 10},
+			status:  http.StatusNotFound,
+			err:     ErrorResponse{Code: service.CODE_NOT_FOUND, Message: "Code Not Found"},
+		},
+		{
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15, AsOf: &beforeLaunch},
+			status:  http.StatusNotFound,
+			err:     ErrorResponse{Code: service.CODE_NOT_FOUND, Message: "Code Not Found"},
 		},
 	}
 
@@ -137,6 +375,7 @@ func Test_MakeTotalRetailPriceEndpoint(t *testing.T) {
 		MakeTotalRetailPriceEndpoint(mockPricingService),
 		decodeTotalRetailPriceRequest,
 		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
 	)
 
 	server := httptest.NewServer(totalRetailPriceHandler)
@@ -152,10 +391,22 @@ func Test_MakeTotalRetailPriceEndpoint(t *testing.T) {
 		}
 
 		var actualResponse TotalRetailPriceResponse
-		json.NewDecoder(resp.Body).Decode(&actualResponse)
-
-		assert.True(t, test.response.Err == actualResponse.Err, "~2|Test #%d expected error: %s, not error %s~", id, test.response.Err, actualResponse.Err)
-		assert.True(t, test.response.Total == actualResponse.Total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, test.response.Total, actualResponse.Total)
+		actualErr := decodeResponse(resp, &actualResponse)
+
+		assert.True(t, status(test.status) == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, status(test.status), resp.StatusCode)
+		assert.True(t, test.err.Code == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err.Code, actualErr.Code)
+		assert.True(t, test.err.Message == actualErr.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.err.Message, actualErr.Message)
+		assert.True(t, test.response.Total == actualResponse.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.response.Total, actualResponse.Total)
+		assert.True(t, fmt.Sprintf("%+v", test.response.Tier) == fmt.Sprintf("%+v", actualResponse.Tier), "~2|Test #%d expected tier: %+v, not tier %+v~", id, test.response.Tier, actualResponse.Tier)
+		assert.True(t, fmt.Sprintf("%+v", test.response.Promotion) == fmt.Sprintf("%+v", actualResponse.Promotion), "~2|Test #%d expected promotion: %+v, not promotion %+v~", id, test.response.Promotion, actualResponse.Promotion)
+		assert.True(t, test.response.Currency == actualResponse.Currency, "~2|Test #%d expected currency: %s, not currency %s~", id, test.response.Currency, actualResponse.Currency)
+		assert.True(t, test.response.FxRate == actualResponse.FxRate, "~2|Test #%d expected fx rate: %s, not fx rate %s~", id, test.response.FxRate, actualResponse.FxRate)
+		assert.True(t, test.response.Region == actualResponse.Region, "~2|Test #%d expected region: %s, not region %s~", id, test.response.Region, actualResponse.Region)
+		assert.True(t, test.response.TaxRate == actualResponse.TaxRate, "~2|Test #%d expected tax rate: %s, not tax rate %s~", id, test.response.TaxRate, actualResponse.TaxRate)
+		assert.True(t, test.response.Net == actualResponse.Net, "~2|Test #%d expected net: %s, not net %s~", id, test.response.Net, actualResponse.Net)
+		assert.True(t, test.response.Tax == actualResponse.Tax, "~2|Test #%d expected tax: %s, not tax %s~", id, test.response.Tax, actualResponse.Tax)
+		assert.True(t, test.response.Gross == actualResponse.Gross, "~2|Test #%d expected gross: %s, not gross %s~", id, test.response.Gross, actualResponse.Gross)
+		assert.True(t, fmt.Sprintf("%+v", test.response.Breakdown) == fmt.Sprintf("%+v", actualResponse.Breakdown), "~2|Test #%d expected breakdown: %+v, not breakdown %+v~", id, test.response.Breakdown, actualResponse.Breakdown)
 	}
 }
 
@@ -163,22 +414,50 @@ func Test_MakeTotalWholesalePriceEndpoint(t *testing.T) {
 	tests := []struct {
 		request  TotalWholesalePriceRequest
 		response TotalWholesalePriceResponse
+		status   int
+		err      ErrorResponse
 	}{
 		{
-			request:  TotalWholesalePriceRequest{Partner: "", Code: "aaa111", Qty: 0},
-			response: TotalWholesalePriceResponse{Err: "Invalid Partner Requested"},
+			request: TotalWholesalePriceRequest{Partner: "", Code: "aaa111", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_PARTNER, Message: "Invalid Partner Requested"},
 		},
 		{
-			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "", Qty: 0},
-			response: TotalWholesalePriceResponse{Err: "Invalid Code Requested"},
+			request: TotalWholesalePriceRequest{Partner: "superstore", Code: "", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_CODE, Message: "Invalid Code Requested"},
 		},
 		{
-			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 0},
-			response: TotalWholesalePriceResponse{Err: "Invalid Quantity Requested"},
+			request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_QTY, Message: "Invalid Quantity Requested"},
 		},
 		{
 			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15},
-			response: TotalWholesalePriceResponse{Total: 165.62},
+			response: TotalWholesalePriceResponse{Total: money.MustParse("165.62"), Discount: money.MustParseRate("0.15"), Currency: "USD", FxRate: money.RateScale, Net: money.MustParse("165.62"), Gross: money.MustParse("165.62")},
+		},
+		{
+			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15, Currency: "EUR"},
+			response: TotalWholesalePriceResponse{Total: money.MustParse("152.37"), Discount: money.MustParseRate("0.15"), Currency: "EUR", FxRate: money.MustParseRate("0.92"), Net: money.MustParse("152.37"), Gross: money.MustParse("152.37")},
+		},
+		{
+			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15, Region: "uk"},
+			response: TotalWholesalePriceResponse{Total: money.MustParse("165.62"), Discount: money.MustParseRate("0.15"), Currency: "USD", FxRate: money.RateScale, Region: "uk", TaxRate: money.MustParseRate("0.20"), Net: money.MustParse("165.62"), Tax: money.MustParse("33.12"), Gross: money.MustParse("198.74")},
+		}, {
+			request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15, Explain: true},
+			response: TotalWholesalePriceResponse{Total: money.MustParse("165.62"), Discount: money.MustParseRate("0.15"), Currency: "USD", FxRate: money.RateScale, Net: money.MustParse("165.62"), Gross: money.MustParse("165.62"), Breakdown: &BreakdownResponse{
+				UnitPrice:      money.MustParse("12.99"),
+				Discount:       money.MustParseRate("0.15"),
+				UnitSaving:     money.MustParseDecimal("1.9485"),
+				Qty:            15,
+				FxRate:         money.RateScale,
+				ExactTotal:     money.MustParseDecimal("165.6225"),
+				Rounding:       money.HalfUp,
+				Adjustment:     money.MustParseDecimal("-0.0025"),
+				LineTotal:      money.MustParse("165.62"),
+				Total:          money.MustParse("165.62"),
+				CatalogVersion: 3,
+			}},
 		},
 	}
 
@@ -188,6 +467,7 @@ func Test_MakeTotalWholesalePriceEndpoint(t *testing.T) {
 		MakeTotalWholesalePriceEndpoint(mockPricingService),
 		decodeTotalWholesalePriceRequest,
 		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
 	)
 
 	server := httptest.NewServer(totalWholesalePriceHandler)
@@ -203,9 +483,132 @@ func Test_MakeTotalWholesalePriceEndpoint(t *testing.T) {
 		}
 
 		var actualResponse TotalWholesalePriceResponse
-		json.NewDecoder(resp.Body).Decode(&actualResponse)
+		actualErr := decodeResponse(resp, &actualResponse)
+
+		assert.True(t, status(test.status) == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, status(test.status), resp.StatusCode)
+		assert.True(t, test.err.Code == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err.Code, actualErr.Code)
+		assert.True(t, test.err.Message == actualErr.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.err.Message, actualErr.Message)
+		assert.True(t, test.response.Total == actualResponse.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.response.Total, actualResponse.Total)
+		assert.True(t, test.response.Discount == actualResponse.Discount, "~2|Test #%d expected discount: %s, not discount %s~", id, test.response.Discount, actualResponse.Discount)
+		assert.True(t, test.response.Currency == actualResponse.Currency, "~2|Test #%d expected currency: %s, not currency %s~", id, test.response.Currency, actualResponse.Currency)
+		assert.True(t, test.response.FxRate == actualResponse.FxRate, "~2|Test #%d expected fx rate: %s, not fx rate %s~", id, test.response.FxRate, actualResponse.FxRate)
+		assert.True(t, test.response.Region == actualResponse.Region, "~2|Test #%d expected region: %s, not region %s~", id, test.response.Region, actualResponse.Region)
+		assert.True(t, test.response.TaxRate == actualResponse.TaxRate, "~2|Test #%d expected tax rate: %s, not tax rate %s~", id, test.response.TaxRate, actualResponse.TaxRate)
+		assert.True(t, test.response.Net == actualResponse.Net, "~2|Test #%d expected net: %s, not net %s~", id, test.response.Net, actualResponse.Net)
+		assert.True(t, test.response.Tax == actualResponse.Tax, "~2|Test #%d expected tax: %s, not tax %s~", id, test.response.Tax, actualResponse.Tax)
+		assert.True(t, test.response.Gross == actualResponse.Gross, "~2|Test #%d expected gross: %s, not gross %s~", id, test.response.Gross, actualResponse.Gross)
+		assert.True(t, fmt.Sprintf("%+v", test.response.Breakdown) == fmt.Sprintf("%+v", actualResponse.Breakdown), "~2|Test #%d expected breakdown: %+v, not breakdown %+v~", id, test.response.Breakdown, actualResponse.Breakdown)
+	}
+}
 
-		assert.True(t, test.response.Err == actualResponse.Err, "~2|Test #%d expected error: %s, not error %s~", id, test.response.Err, actualResponse.Err)
-		assert.True(t, test.response.Total == actualResponse.Total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, test.response.Total, actualResponse.Total)
+func Test_MakeQuoteEndpoint(t *testing.T) {
+	tests := []struct {
+		request  QuoteRequest
+		response QuoteResponse
+		status   int
+		err      ErrorResponse
+	}{
+		{
+			request: QuoteRequest{},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.EMPTY_QUOTE, Message: "Empty Quote Requested"},
+		},
+		{
+			request: QuoteRequest{Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 15}, {Code: "fff000", Qty: 10}}},
+			response: QuoteResponse{
+				Currency: "USD",
+				Lines:    []QuotedLineResponse{{Code: "aaa111", Qty: 15, UnitPrice: money.MustParse("12.99"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "USD", FxRate: money.RateScale, Total: money.MustParse("194.85"), Net: money.MustParse("194.85"), Gross: money.MustParse("194.85")}, {Code: "fff000", Qty: 10, Error: &ErrorResponse{Code: service.CODE_NOT_FOUND, Message: "Code Not Found"}}},
+				Total:    money.MustParse("194.85"),
+				Net:      money.MustParse("194.85"),
+				Gross:    money.MustParse("194.85"),
+			},
+		},
+		{
+			request: QuoteRequest{Partner: "superstore", Currency: "EUR", Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 0}, {Code: "aaa111", Qty: 15}}},
+			response: QuoteResponse{
+				Partner:  "superstore",
+				Currency: "EUR",
+				Lines:    []QuotedLineResponse{{Code: "aaa111", Qty: 0, Error: &ErrorResponse{Code: service.INVALID_QTY, Message: "Invalid Quantity Requested"}}, {Code: "aaa111", Qty: 15, UnitPrice: money.MustParse("12.99"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Discount: money.MustParseRate("0.15"), Currency: "EUR", FxRate: money.MustParseRate("0.92"), Total: money.MustParse("152.37"), Net: money.MustParse("152.37"), Gross: money.MustParse("152.37")}},
+				Total:    money.MustParse("152.37"),
+				Net:      money.MustParse("152.37"),
+				Gross:    money.MustParse("152.37"),
+			},
+		},
+		{
+			request: QuoteRequest{Region: "uk", Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 15}, {Code: "bbb222", Qty: 10}}},
+			response: QuoteResponse{
+				Currency: "USD",
+				Region:   "uk",
+				Lines: []QuotedLineResponse{
+					{Code: "aaa111", Qty: 15, UnitPrice: money.MustParse("12.99"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("12.99")}, Currency: "USD", FxRate: money.RateScale, Total: money.MustParse("194.85"), Region: "uk", TaxRate: money.MustParseRate("0.20"), Net: money.MustParse("194.85"), Tax: money.MustParse("38.97"), Gross: money.MustParse("233.82")},
+					{Code: "bbb222", Qty: 10, UnitPrice: money.MustParse("2.90"), Tier: &TierResponse{MinQty: 1, Price: money.MustParse("2.90")}, Currency: "USD", FxRate: money.RateScale, Total: money.MustParse("29.00"), Region: "uk", TaxRate: money.MustParseRate("0.20"), Net: money.MustParse("29.00"), Tax: money.MustParse("5.80"), Gross: money.MustParse("34.80")},
+				},
+				Total: money.MustParse("223.85"),
+				Net:   money.MustParse("223.85"),
+				Tax:   money.MustParse("44.77"),
+				Gross: money.MustParse("268.62"),
+			},
+		},
+		{
+			request: QuoteRequest{Region: "mars", Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 1}}},
+			status:  http.StatusUnprocessableEntity,
+			err:     ErrorResponse{Code: service.UNKNOWN_REGION, Message: "Unknown Tax Region"},
+		},
+		{
+			request: QuoteRequest{Currency: "GBP", Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 1}}},
+			status:  http.StatusUnprocessableEntity,
+			err:     ErrorResponse{Code: service.UNKNOWN_CURRENCY, Message: "Unknown Currency"},
+		},
+	}
+
+	mockPricingService := new(MockPricingService)
+
+	quoteHandler := httptransport.NewServer(
+		MakeQuoteEndpoint(mockPricingService),
+		decodeQuoteRequest,
+		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+	)
+
+	server := httptest.NewServer(quoteHandler)
+	defer server.Close()
+
+	for id, test := range tests {
+		postBody, _ := json.Marshal(test.request)
+
+		responseBody := bytes.NewBuffer(postBody)
+		resp, err := http.Post(server.URL, "application/json", responseBody)
+		if err != nil {
+			log.Fatalf("An Error Occured %v", err)
+		}
+
+		var actualResponse QuoteResponse
+		actualErr := decodeResponse(resp, &actualResponse)
+
+		assert.True(t, status(test.status) == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, status(test.status), resp.StatusCode)
+		assert.True(t, test.err.Code == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err.Code, actualErr.Code)
+		assert.True(t, test.err.Message == actualErr.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.err.Message, actualErr.Message)
+		assert.True(t, test.response.Total == actualResponse.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.response.Total, actualResponse.Total)
+		assert.True(t, test.response.Currency == actualResponse.Currency, "~2|Test #%d expected currency: %s, not currency %s~", id, test.response.Currency, actualResponse.Currency)
+		assert.True(t, test.response.Region == actualResponse.Region, "~2|Test #%d expected region: %s, not region %s~", id, test.response.Region, actualResponse.Region)
+		assert.True(t, test.response.Net == actualResponse.Net, "~2|Test #%d expected net: %s, not net %s~", id, test.response.Net, actualResponse.Net)
+		assert.True(t, test.response.Tax == actualResponse.Tax, "~2|Test #%d expected tax: %s, not tax %s~", id, test.response.Tax, actualResponse.Tax)
+		assert.True(t, test.response.Gross == actualResponse.Gross, "~2|Test #%d expected gross: %s, not gross %s~", id, test.response.Gross, actualResponse.Gross)
+		assert.True(t, len(test.response.Lines) == len(actualResponse.Lines), "~2|Test #%d expected %d lines, not %d~", id, len(test.response.Lines), len(actualResponse.Lines))
+
+		for i := range actualResponse.Lines {
+			if i >= len(test.response.Lines) {
+				break
+			}
+
+			expected, actual := test.response.Lines[i], actualResponse.Lines[i]
+			assert.True(t, fmt.Sprintf("%+v", expected.Tier) == fmt.Sprintf("%+v", actual.Tier), "~2|Test #%d line #%d expected tier: %+v, not tier %+v~", id, i, expected.Tier, actual.Tier)
+
+			assert.True(t, envelope(expected.Error) == envelope(actual.Error), "~2|Test #%d line #%d expected error: %s, not error %s~", id, i, envelope(expected.Error), envelope(actual.Error))
+
+			expected.Tier, actual.Tier = nil, nil
+			expected.Error, actual.Error = nil, nil
+			assert.True(t, expected == actual, "~2|Test #%d line #%d expected: %+v, not: %+v~", id, i, expected, actual)
+		}
 	}
 }
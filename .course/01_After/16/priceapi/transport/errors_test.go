@@ -0,0 +1,116 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// detailedError names the request value it is about, as a
+// *service.CouponError does.
+type detailedError struct {
+	err   error
+	value string
+}
+
+func (e detailedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.err, e.value)
+}
+
+func (e detailedError) Details() map[string]string {
+	return map[string]string{"coupon": e.value}
+}
+
+func (e detailedError) Unwrap() error {
+	return e.err
+}
+
+func Test_ErrorResponse_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    ErrorResponse
+		expected string
+	}{
+		{
+			input:    ErrorResponse{Code: service.CODE_NOT_FOUND, Message: "Code Not Found"},
+			expected: `{"code":"CODE_NOT_FOUND","message":"Code Not Found"}`,
+		},
+		{
+			input:    ErrorResponse{Code: service.UNKNOWN_COUPON, Message: `Unknown Coupon: "SAVE\10"`, Details: map[string]string{"coupon": `"SAVE\10"`}},
+			expected: `{"code":"UNKNOWN_COUPON","message":"Unknown Coupon: \"SAVE\\10\"","details":{"coupon":"\"SAVE\\10\""}}`,
+		},
+	}
+
+	for id, test := range tests {
+		data, err := json.Marshal(&test.input)
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %v~", id, err)
+		assert.True(t, test.expected == string(data), "~2|Test #%d expected json: %s, not json %s~", id, test.expected, data)
+
+		var actual ErrorResponse
+		err = json.Unmarshal(data, &actual)
+		assert.True(t, err == nil, "~2|Test #%d expected valid json, not error %v~", id, err)
+		assert.True(t, test.input.Message == actual.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.input.Message, actual.Message)
+	}
+}
+
+func Test_EncodeError(t *testing.T) {
+	upstream := &ErrorResponse{Code: service.PARTNER_NOT_FOUND, Message: "Partner Not Found"}
+
+	tests := []struct {
+		err      error
+		status   int
+		expected string
+	}{
+		{
+			err:      ErrInvalidQty,
+			status:   http.StatusBadRequest,
+			expected: `{"code":"INVALID_QTY","message":"Invalid Quantity Requested"}`,
+		},
+		{
+			err:      ErrCodeNotFound,
+			status:   http.StatusNotFound,
+			expected: `{"code":"CODE_NOT_FOUND","message":"Code Not Found"}`,
+		},
+		{
+			err:      detailedError{err: ErrExpiredCoupon, value: "OLD"},
+			status:   http.StatusUnprocessableEntity,
+			expected: `{"code":"COUPON_EXPIRED","message":"Expired Coupon: OLD","details":{"coupon":"OLD"}}`,
+		},
+		{
+			err:      fmt.Errorf("retrying: %w", upstream),
+			status:   http.StatusNotFound,
+			expected: `{"code":"PARTNER_NOT_FOUND","message":"Partner Not Found"}`,
+		},
+		{
+			err:      context.Canceled,
+			status:   499,
+			expected: `{"code":"CANCELED","message":"context canceled"}`,
+		},
+		{
+			err:      fmt.Errorf("fetching product: %w", context.DeadlineExceeded),
+			status:   http.StatusRequestTimeout,
+			expected: `{"code":"TIMEOUT","message":"fetching product: context deadline exceeded"}`,
+		},
+		{
+			err:      errors.New("disk on fire"),
+			status:   http.StatusInternalServerError,
+			expected: `{"code":"INTERNAL","message":"disk on fire"}`,
+		},
+	}
+
+	for id, test := range tests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), test.err, w)
+
+		actual := w.Body.String()
+		assert.True(t, test.status == w.Code, "~2|Test #%d expected status: %d, not status %d~", id, test.status, w.Code)
+		assert.True(t, test.expected == actual, "~2|Test #%d expected body: %s, not body %s~", id, test.expected, actual)
+		assert.True(t, w.Header().Get("Content-Type") == "application/json; charset=utf-8", "~2|Test #%d expected json content type, not %s~", id, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,154 @@
+package transport
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+)
+
+// HedgePolicy sets when a request that has not been answered is sent again
+// to another instance. With a Quantile such as 0.95 the hedge is sent once
+// the request has taken longer than that quantile of recent replies, and
+// after Delay until enough replies have been seen; without one it is sent
+// after Delay. A zero Delay turns hedging off.
+type HedgePolicy struct {
+	Delay    Duration `json:"delay" yaml:"delay"`
+	Quantile float64  `json:"quantile" yaml:"quantile"`
+}
+
+// ProxyMetrics are the metrics of the pricing proxy. BreakerState is the
+// state of the circuit breaker of each route and instance. HedgesFired and
+// HedgesWon count, by route, the hedges sent and those that answered first.
+type ProxyMetrics struct {
+	BreakerState metrics.Gauge
+	HedgesFired  metrics.Counter
+	HedgesWon    metrics.Counter
+}
+
+const (
+	// latencyWindow is how many recent replies the hedge quantile is taken
+	// over, and latencyMinSamples how many are needed before it is used.
+	latencyWindow     = 1000
+	latencyMinSamples = 20
+)
+
+// latencyTracker keeps the latencies of the most recent replies.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (lt *latencyTracker) observe(latency time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if len(lt.samples) < latencyWindow {
+		lt.samples = append(lt.samples, latency)
+		return
+	}
+
+	lt.samples[lt.next] = latency
+	lt.next = (lt.next + 1) % latencyWindow
+}
+
+// quantile returns the q quantile of the recent latencies, and false while
+// there are too few of them.
+func (lt *latencyTracker) quantile(q float64) (latency time.Duration, found bool) {
+	lt.mu.Lock()
+	sorted := make([]time.Duration, len(lt.samples))
+	copy(sorted, lt.samples)
+	lt.mu.Unlock()
+
+	if len(sorted) < latencyMinSamples {
+		return 0, false
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[int(q*float64(len(sorted)-1))], true
+}
+
+type hedgeResult struct {
+	response interface{}
+	err      error
+	hedge    bool
+}
+
+// hedge sends a request on to next and, should no reply have come once the
+// hedge delay of policy has passed, sends it again alongside. The first
+// successful reply is used and the other request is cancelled; when both
+// fail, the error of the original request is returned. next must share the
+// record of instances tried that retryElsewhere puts in the context, so
+// that the hedge goes to another instance. Requests with side effects are
+// never hedged.
+func hedge(route string, policy HedgePolicy, latencies *latencyTracker, m ProxyMetrics) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		if policy.Delay <= 0 {
+			return next
+		}
+
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if hasSideEffects(request) {
+				return next(ctx, request)
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			results := make(chan hedgeResult, 2)
+			send := func(hedged bool) {
+				begin := time.Now()
+				response, err := next(ctx, request)
+				if err == nil {
+					latencies.observe(time.Since(begin))
+				}
+
+				results <- hedgeResult{response: response, err: err, hedge: hedged}
+			}
+
+			go send(false)
+
+			delay := time.Duration(policy.Delay)
+			if policy.Quantile > 0 {
+				if observed, found := latencies.quantile(policy.Quantile); found {
+					delay = observed
+				}
+			}
+
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+
+			select {
+			case result := <-results:
+				return result.response, result.err
+			case <-timer.C:
+			}
+
+			m.HedgesFired.With("route", route).Add(1)
+			go send(true)
+
+			var first error
+			for i := 0; i < 2; i++ {
+				result := <-results
+				if result.err == nil {
+					if result.hedge {
+						m.HedgesWon.With("route", route).Add(1)
+					}
+
+					return result.response, nil
+				}
+
+				if first == nil || !result.hedge {
+					first = result.err
+				}
+			}
+
+			return nil, first
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/discovery"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockCounter sums what is added for each set of label values.
+type MockCounter struct {
+	mu     *sync.Mutex
+	values map[string]float64
+	labels string
+}
+
+func (mc *MockCounter) With(labelValues ...string) metrics.Counter {
+	return &MockCounter{mu: mc.mu, values: mc.values, labels: strings.Join(labelValues, ",")}
+}
+
+func (mc *MockCounter) Add(delta float64) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.values[mc.labels] += delta
+}
+
+func (mc *MockCounter) value(labels string) float64 {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return mc.values[labels]
+}
+
+// newStalledUpstream serves the retail handler after stall, and counts the
+// requests that are cancelled while they wait.
+func newStalledUpstream(stall time.Duration, cancelled *int32) *httptest.Server {
+	retail := MakeTotalRetailPriceHttpHandler(log.NewNopLogger(), new(MockPricingService))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a client going away once the body has
+		// been read.
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		select {
+		case <-time.After(stall):
+			retail.ServeHTTP(w, r)
+		case <-r.Context().Done():
+			atomic.AddInt32(cancelled, 1)
+		}
+	}))
+}
+
+// MockRedeemingService counts the retail prices that redeem a coupon.
+type MockRedeemingService struct {
+	MockPricingService
+	redeemed *int32
+}
+
+func (mrs MockRedeemingService) GetRetailTotal(ctx context.Context, code string, qty int, opts service.RetailOptions) (price service.Price, err error) {
+	if opts.Coupon != "" && opts.RedemptionKey != "" {
+		atomic.AddInt32(mrs.redeemed, 1)
+	}
+
+	return mrs.MockPricingService.GetRetailTotal(ctx, code, qty, opts)
+}
+
+// newRedeemingUpstream serves the retail handler over svc after stall, and
+// counts the requests it receives in hits.
+func newRedeemingUpstream(stall time.Duration, svc PricingService, hits *int32) *httptest.Server {
+	retail := MakeTotalRetailPriceHttpHandler(log.NewNopLogger(), svc)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		time.Sleep(stall)
+		retail.ServeHTTP(w, r)
+	}))
+}
+
+func Test_latencyTracker(t *testing.T) {
+	lt := new(latencyTracker)
+
+	for i := 1; i < latencyMinSamples; i++ {
+		lt.observe(time.Duration(i) * time.Millisecond)
+	}
+	_, found := lt.quantile(0.95)
+	assert.True(t, !found, "~2|Test expected no quantile before %d samples~", latencyMinSamples)
+
+	lt = new(latencyTracker)
+	for i := 1; i <= 100; i++ {
+		lt.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		quantile float64
+		latency  time.Duration
+	}{
+		{quantile: 0.5, latency: 50 * time.Millisecond},
+		{quantile: 0.95, latency: 95 * time.Millisecond},
+		{quantile: 0.99, latency: 99 * time.Millisecond},
+	}
+
+	for id, test := range tests {
+		latency, found := lt.quantile(test.quantile)
+		assert.True(t, found, "~2|Test #%d expected a quantile~", id)
+		assert.True(t, test.latency == latency, "~2|Test #%d expected latency: %s, not latency %s~", id, test.latency, latency)
+	}
+
+	for i := 0; i < latencyWindow; i++ {
+		lt.observe(time.Second)
+	}
+	latency, _ := lt.quantile(0.5)
+	assert.True(t, latency == time.Second, "~2|Test expected old samples to leave the window, not latency %s~", latency)
+}
+
+func Test_hedge(t *testing.T) {
+	errStalled := errors.New("stalled")
+
+	tests := []struct {
+		hedge     HedgePolicy
+		primary   time.Duration
+		hedgeFail bool
+		response  interface{}
+		err       error
+		fired     float64
+		won       float64
+		cancelled int32
+	}{
+		{hedge: HedgePolicy{}, primary: -1, err: errStalled},
+		{hedge: HedgePolicy{Delay: Duration(20 * time.Millisecond)}, primary: 0, response: "primary"},
+		{hedge: HedgePolicy{Delay: Duration(20 * time.Millisecond)}, primary: -1, response: "hedge", fired: 1, won: 1, cancelled: 1},
+		{hedge: HedgePolicy{Delay: Duration(20 * time.Millisecond)}, primary: 40 * time.Millisecond, hedgeFail: true, response: "primary", fired: 1},
+		{hedge: HedgePolicy{Delay: Duration(20 * time.Millisecond), Quantile: 0.95}, primary: -1, response: "hedge", fired: 1, won: 1, cancelled: 1},
+	}
+
+	for id, test := range tests {
+		var calls, cancelled int32
+		next := func(ctx context.Context, request interface{}) (interface{}, error) {
+			if atomic.AddInt32(&calls, 1) == 2 {
+				if test.hedgeFail {
+					return nil, errors.New("hedge failed")
+				}
+				return "hedge", nil
+			}
+
+			if test.primary < 0 {
+				select {
+				case <-ctx.Done():
+					atomic.AddInt32(&cancelled, 1)
+					return nil, errStalled
+				case <-time.After(100 * time.Millisecond):
+					return nil, errStalled
+				}
+			}
+
+			time.Sleep(test.primary)
+			return "primary", nil
+		}
+
+		fired := &MockCounter{mu: new(sync.Mutex), values: make(map[string]float64)}
+		won := &MockCounter{mu: new(sync.Mutex), values: make(map[string]float64)}
+		m := ProxyMetrics{BreakerState: discard.NewGauge(), HedgesFired: fired, HedgesWon: won}
+
+		response, err := hedge(ROUTE_RETAIL, test.hedge, new(latencyTracker), m)(next)(context.Background(), nil)
+
+		assert.True(t, test.response == response || test.response == nil && response == nil, "~2|Test #%d expected response: %v, not response %v~", id, test.response, response)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %v, not error %v~", id, test.err, err)
+		assert.True(t, test.fired == fired.value("route,retail"), "~2|Test #%d expected %v hedges fired, not %v~", id, test.fired, fired.value("route,retail"))
+		assert.True(t, test.won == won.value("route,retail"), "~2|Test #%d expected %v hedges won, not %v~", id, test.won, won.value("route,retail"))
+
+		// The losing request is cancelled as the hedge returns, but may
+		// still be on its way out.
+		time.Sleep(10 * time.Millisecond)
+		assert.True(t, test.cancelled == atomic.LoadInt32(&cancelled), "~2|Test #%d expected %d requests cancelled, not %d~", id, test.cancelled, cancelled)
+	}
+}
+
+func Test_PricingServiceProxy_Hedge(t *testing.T) {
+	var stalledCancelled, fastCancelled int32
+	stalled, fast := newStalledUpstream(time.Second, &stalledCancelled), newStalledUpstream(0, &fastCancelled)
+	defer stalled.Close()
+	defer fast.Close()
+
+	instances := []string{strings.TrimPrefix(stalled.URL, "http://"), strings.TrimPrefix(fast.URL, "http://")}
+
+	policy := DefaultResiliencePolicy
+	policy.Default.Budget = Duration(500 * time.Millisecond)
+	policy.Default.Hedge = HedgePolicy{Delay: Duration(20 * time.Millisecond)}
+
+	fired := &MockCounter{mu: new(sync.Mutex), values: make(map[string]float64)}
+	won := &MockCounter{mu: new(sync.Mutex), values: make(map[string]float64)}
+	m := ProxyMetrics{BreakerState: discard.NewGauge(), HedgesFired: fired, HedgesWon: won}
+
+	proxy := NewPricingServiceProxy(context.Background(), discovery.NewStaticInstancer(instances), DefaultBalancerConfig, policy, m, &MockLogger{})
+
+	// The hedge of each request takes the next round-robin turn, so once a
+	// request has gone to the stalled instance first, every one after does
+	// too and is answered by its hedge. Whether the first request does
+	// depends on the order of the instances.
+	hedges := 3.0
+	if instances[0] < instances[1] {
+		hedges = 4
+	}
+
+	for i := 0; i < 4; i++ {
+		begin := time.Now()
+		price, err := proxy.GetRetailTotal(context.Background(), "aaa111", 15, service.RetailOptions{})
+		elapsed := time.Since(begin)
+
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %v~", i, err)
+		assert.True(t, price.Total.String() == "194.85", "~2|Test #%d expected total: 194.85, not total %s~", i, price.Total)
+		assert.True(t, elapsed < 200*time.Millisecond, "~2|Test #%d expected a reply within 200ms, not %s~", i, elapsed)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, fired.value("route,retail") == hedges, "~2|Test expected %v hedges fired, not %v~", hedges, fired.value("route,retail"))
+	assert.True(t, won.value("route,retail") == hedges, "~2|Test expected %v hedges won, not %v~", hedges, won.value("route,retail"))
+	assert.True(t, float64(atomic.LoadInt32(&stalledCancelled)) == hedges, "~2|Test expected %v stalled requests cancelled, not %d~", hedges, stalledCancelled)
+	assert.True(t, atomic.LoadInt32(&fastCancelled) == 0, "~2|Test expected no fast requests cancelled, not %d~", fastCancelled)
+}
+
+func Test_PricingServiceProxy_SideEffects(t *testing.T) {
+	tests := []struct {
+		key      string
+		hedge    HedgePolicy
+		timeout  time.Duration
+		fired    float64
+		hits     int32
+		redeemed int32
+	}{
+		{key: "", hedge: HedgePolicy{Delay: Duration(10 * time.Millisecond)}, fired: 1, hits: 2},
+		{key: "order-1", hedge: HedgePolicy{Delay: Duration(10 * time.Millisecond)}, fired: 0, hits: 1, redeemed: 1},
+		{key: "", timeout: 20 * time.Millisecond, hits: 3},
+		{key: "order-1", timeout: 20 * time.Millisecond, hits: 1, redeemed: 1},
+	}
+
+	for id, test := range tests {
+		var hits, redeemed int32
+		svc := MockRedeemingService{redeemed: &redeemed}
+		upstreamA, upstreamB := newRedeemingUpstream(50*time.Millisecond, svc, &hits), newRedeemingUpstream(50*time.Millisecond, svc, &hits)
+
+		policy := DefaultResiliencePolicy
+		policy.Default.Hedge = test.hedge
+		policy.Default.AttemptTimeout = Duration(test.timeout)
+
+		fired := &MockCounter{mu: new(sync.Mutex), values: make(map[string]float64)}
+		m := ProxyMetrics{BreakerState: discard.NewGauge(), HedgesFired: fired, HedgesWon: discard.NewCounter()}
+
+		instances := []string{strings.TrimPrefix(upstreamA.URL, "http://"), strings.TrimPrefix(upstreamB.URL, "http://")}
+		proxy := NewPricingServiceProxy(context.Background(), discovery.NewStaticInstancer(instances), DefaultBalancerConfig, policy, m, &MockLogger{})
+
+		_, _ = proxy.GetRetailTotal(context.Background(), "aaa111", 1, service.RetailOptions{Coupon: "SAVE10", RedemptionKey: test.key})
+
+		// Requests that were given up on may still be served.
+		time.Sleep(100 * time.Millisecond)
+		upstreamA.Close()
+		upstreamB.Close()
+
+		assert.True(t, test.fired == fired.value("route,retail"), "~2|Test #%d expected %v hedges fired, not %v~", id, test.fired, fired.value("route,retail"))
+		assert.True(t, test.hits == atomic.LoadInt32(&hits), "~2|Test #%d expected %d upstream requests, not %d~", id, test.hits, hits)
+		assert.True(t, test.redeemed == atomic.LoadInt32(&redeemed), "~2|Test #%d expected %d redemptions, not %d~", id, test.redeemed, redeemed)
+	}
+}
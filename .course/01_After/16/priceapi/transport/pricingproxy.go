@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
 	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
@@ -15,141 +18,271 @@ import (
 	"github.com/go-kit/kit/sd"
 	"github.com/go-kit/kit/sd/lb"
 	httptransport "github.com/go-kit/kit/transport/http"
-	"github.com/sony/gobreaker"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/time/rate"
 )
 
 const (
 	INVALID_RESPONSE = "Invalid Response"
 )
 
-func NewPricingServiceProxy(ctx context.Context, instanceList []string, logger log.Logger) PricingService {
+// NewPricingServiceProxy balances requests over the pricing service
+// instances yielded by instancer. Endpoints are created and closed as
+// instances come and go, without restarting the proxy, until ctx is done;
+// the proxy then stops following instancer. Requests are spread over the
+// instances as balancing sets, each route is guarded and hedged by its
+// policy, and breaker states and hedges are recorded in m.
+func NewPricingServiceProxy(ctx context.Context, instancer sd.Instancer, balancing BalancerConfig, policy ResiliencePolicy, m ProxyMetrics, logger log.Logger) PricingService {
 	tracer := otel.Tracer("Transport.PricingProxy")
 
-	getRetailTotal := makeRetailTotalEndpoint("RetailTotal", instanceList, tracer, logger)
-	getWholesaleTotal := makeWholesaleTotalEndpoint("WholesaleTotal", instanceList, tracer, logger)
+	getRetailTotal := makeRouteEndpoint(ctx, ROUTE_RETAIL, "/retail", encodeRequest, decodeTotalRetailPriceResponse, instancer, balancing, policy, m, tracer, logger)
+	getWholesaleTotal := makeRouteEndpoint(ctx, ROUTE_WHOLESALE, "/wholesale", encodeRequest, decodeTotalWholesalePriceResponse, instancer, balancing, policy, m, tracer, logger)
+	getQuote := makeRouteEndpoint(ctx, ROUTE_QUOTE, "/quote", encodeRequest, decodeQuoteResponse, instancer, balancing, policy, m, tracer, logger)
 
-	return proxyMiddleware{ctx, getRetailTotal, getWholesaleTotal}
+	return PricingServiceClient{
+		GetRetailTotalEndpoint:    getRetailTotal,
+		GetWholesaleTotalEndpoint: getWholesaleTotal,
+		GetQuoteEndpoint:          getQuote,
+	}
 }
 
-func makeRetailTotalEndpoint(name string, instanceList []string, tracer trace.Tracer, logger log.Logger) endpoint.Endpoint {
-	var (
-		qps         = 100
-		maxAttempts = 3
-		maxTime     = 250 * time.Millisecond
-	)
+// makeRouteEndpoint proxies route to path on the instances yielded by
+// instancer. Each instance gets its own client, traced and guarded by the
+// attempt timeout, circuit breaker and rate limit of the route's policy;
+// requests are balanced over the instances, retried and hedged. The
+// instances are followed until ctx is done.
+func makeRouteEndpoint(ctx context.Context, route string, path string, enc httptransport.EncodeRequestFunc, dec httptransport.DecodeResponseFunc, instancer sd.Instancer, balancing BalancerConfig, policy ResiliencePolicy, m ProxyMetrics, tracer trace.Tracer, logger log.Logger) endpoint.Endpoint {
+	routePolicy := policy.Route(route)
 
-	var endpointer sd.FixedEndpointer
-	for _, instance := range instanceList {
-		path := fmt.Sprintf("http://%s/retail", instance)
-		u, _ := url.Parse(path)
+	factory := func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		u, err := url.Parse(fmt.Sprintf("http://%s%s", instance, path))
+		if err != nil {
+			return nil, nil, err
+		}
 
 		var e endpoint.Endpoint
 		e = httptransport.NewClient(
 			"POST",
 			u,
-			encodeRequest,
-			decodeTotalRetailPriceResponse,
-			httptransport.ClientBefore(startTrace(tracer, logger)),
-			httptransport.ClientAfter(stopTrace(tracer, logger)),
+			enc,
+			dec,
+			httptransport.ClientBefore(injectTrace()),
+			httptransport.ClientAfter(traceResponse()),
 		).Endpoint()
-		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(e)
-		e = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), qps))(e)
-		endpointer = append(endpointer, e)
+		e = traceAttempt(tracer, u)(e)
+		e = attemptTimeout(time.Duration(routePolicy.AttemptTimeout))(e)
+		e = shieldClientErrors(circuitbreaker.Gobreaker(newBreaker(route, instance, routePolicy.Breaker, m.BreakerState, logger)))(e)
+		e = ratelimit.NewErroringLimiter(newLimiter(routePolicy))(e)
+
+		return e, nil, nil
 	}
 
-	balancer := lb.NewRoundRobin(endpointer)
-	return lb.Retry(maxAttempts, maxTime, balancer)
+	// The endpointer makes and closes the endpoints as instances come and
+	// go, and the balancer follows them through its factory. Closing it
+	// deregisters it from instancer and stops its goroutine.
+	balancer := newBalancer(balancing.newStrategy())
+	endpointer := sd.NewEndpointer(instancer, balancer.track(factory), logger)
+	go func() {
+		<-ctx.Done()
+		endpointer.Close()
+	}()
+
+	retry := retryWithin(balancer, routePolicy)
+	return retryElsewhere(hedge(route, routePolicy.Hedge, new(latencyTracker), m)(retry))
 }
 
-func makeWholesaleTotalEndpoint(name string, instanceList []string, tracer trace.Tracer, logger log.Logger) endpoint.Endpoint {
-	var (
-		qps         = 100
-		maxAttempts = 3
-		maxTime     = 250 * time.Millisecond
-	)
+// isClientError reports whether err is an error response of the pricing
+// service for a request that will fail the same way however often it is
+// sent, such as an invalid quantity or an unknown code.
+func isClientError(err error) bool {
+	var resp *ErrorResponse
+	return errors.As(err, &resp) && resp.StatusCode() < http.StatusInternalServerError
+}
 
-	var endpointer sd.FixedEndpointer
-	for _, instance := range instanceList {
-		path := fmt.Sprintf("http://%s/wholesale", instance)
-		u, _ := url.Parse(path)
+// hasSideEffects reports whether request changes state upstream, as a
+// retail price that redeems a coupon does, so that sending it twice could
+// apply it twice.
+func hasSideEffects(request interface{}) bool {
+	switch req := request.(type) {
+	case TotalRetailPriceRequest:
+		return req.Coupon != "" && req.RedemptionKey != ""
+	default:
+		return false
+	}
+}
 
-		var e endpoint.Endpoint
-		e = httptransport.NewClient(
-			"POST",
-			u,
-			encodeRequest,
-			decodeTotalWholesalePriceResponse,
-			httptransport.ClientBefore(startTrace(tracer, logger)),
-			httptransport.ClientAfter(stopTrace(tracer, logger)),
-		).Endpoint()
-		e = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{}))(e)
-		e = ratelimit.NewErroringLimiter(rate.NewLimiter(rate.Every(time.Second), qps))(e)
-		endpointer = append(endpointer, e)
+// retryWithin tries each request on balancer until it succeeds or the
+// retries of policy run out, all within its budget.
+func retryWithin(balancer lb.Balancer, policy RoutePolicy) endpoint.Endpoint {
+	budget := time.Duration(policy.Budget)
+
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		deadline := time.Now().Add(budget)
+		retry := lb.RetryWithCallback(budget, balancer, retryServerErrors(ctx, deadline, policy.MaxAttempts, policy.Backoff, hasSideEffects(request)))
+
+		return retry(ctx, request)
 	}
+}
+
+// retryServerErrors retries a request up to maxAttempts times after a
+// transport failure or a 5xx error response, and never after a client
+// error. A request with side effects is not retried after an attempt that
+// timed out either, as the instance may have carried it out without
+// replying. It waits out the backoff before each retry, unless ctx ends
+// first, and gives up when the wait would run past deadline, the end of the
+// budget.
+func retryServerErrors(ctx context.Context, deadline time.Time, maxAttempts int, policy BackoffPolicy, sideEffects bool) lb.Callback {
+	return func(n int, err error) (keepTrying bool, replacement error) {
+		if n >= maxAttempts || isClientError(err) {
+			return false, nil
+		}
+		if sideEffects && errors.Is(err, context.DeadlineExceeded) {
+			return false, nil
+		}
+
+		wait := backoff(policy, n)
+		if wait <= 0 {
+			return true, nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return false, nil
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
 
-	balancer := lb.NewRoundRobin(endpointer)
-	return lb.Retry(maxAttempts, maxTime, balancer)
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-timer.C:
+			return true, nil
+		}
+	}
 }
 
-type proxyMiddleware struct {
-	ctx               context.Context
-	getRetailTotal    endpoint.Endpoint
-	getWholesaleTotal endpoint.Endpoint
+// clientFailure carries a client error past the circuit breaker as a
+// response, so that a caller's mistakes do not count as failures of the
+// instance.
+type clientFailure struct {
+	err error
 }
 
-func (mw proxyMiddleware) GetRetailTotal(ctx context.Context, code string, qty int) (total float64, err error) {
-	ctx, span := otel.Tracer("Transport.PricingProxy").Start(ctx, "GetRetailTotal")
-	defer span.End()
+// shieldClientErrors hides client errors from breaker and returns them
+// again once the breaker has seen the request succeed.
+func shieldClientErrors(breaker endpoint.Middleware) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		shielded := breaker(func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := next(ctx, request)
+			if isClientError(err) {
+				return clientFailure{err: err}, nil
+			}
 
-	req := TotalRetailPriceRequest{Code: code, Qty: qty}
+			return response, err
+		})
 
-	response, err := mw.getRetailTotal(ctx, req)
-	if err != nil {
-		return 0.0, err
-	}
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := shielded(ctx, request)
+			if failure, ok := response.(clientFailure); ok {
+				return nil, failure.err
+			}
 
-	resp := response.(TotalRetailPriceResponse)
-	if resp.Err != "" {
-		return 0.0, errors.New(resp.Err)
+			return response, err
+		}
 	}
+}
 
-	return resp.Total, nil
+// remoteError is an error response of the pricing service that names more
+// than its service error does, such as the coupon that has expired. It keeps
+// the upstream message and details and matches the service error through
+// errors.Is.
+type remoteError struct {
+	resp *ErrorResponse
+	err  *service.Error
 }
 
-func (mw proxyMiddleware) GetWholesaleTotal(ctx context.Context, partner, code string, qty int) (total float64, err error) {
-	ctx, span := otel.Tracer("Transport.PricingProxy").Start(ctx, "GetWholesaleTotal")
-	defer span.End()
+func (e remoteError) Error() string {
+	return e.resp.Message
+}
 
-	req := TotalWholesalePriceRequest{Partner: partner, Code: code, Qty: qty}
+func (e remoteError) Details() map[string]string {
+	return e.resp.Details
+}
 
-	response, err := mw.getWholesaleTotal(ctx, req)
-	if err != nil {
-		return 0.0, err
+func (e remoteError) Unwrap() error {
+	return e.err
+}
+
+// proxyError turns the error that ended the last attempt of a request back
+// into the service error of its code, so that callers of the proxy can match
+// it with errors.Is just as they would behind the service itself. Transport
+// failures and codes without a service error are returned as they are.
+func proxyError(err error) error {
+	var retryErr lb.RetryError
+	if errors.As(err, &retryErr) && retryErr.Final != nil {
+		err = retryErr.Final
 	}
 
-	resp := response.(TotalWholesalePriceResponse)
-	if resp.Err != "" {
-		return 0.0, errors.New(resp.Err)
+	var resp *ErrorResponse
+	if !errors.As(err, &resp) {
+		return err
 	}
 
-	return resp.Total, nil
+	serviceErr, found := service.Lookup(resp.Code)
+	if !found {
+		return resp
+	}
+	if resp.Message == serviceErr.Msg && len(resp.Details) == 0 {
+		return serviceErr
+	}
+
+	return remoteError{resp: resp, err: serviceErr}
 }
 
-func startTrace(tracer trace.Tracer, logger log.Logger) httptransport.RequestFunc {
-	return func(ctx context.Context, req *http.Request) context.Context {
-		ctx, span := otel.Tracer("Transport.PricingProxy").Start(ctx, "StartTrace")
-		span.End()
-		return ctx
+// traceAttempt gives each attempt at an upstream request its own client
+// span, so that retries and hedges each show in the trace. The span is
+// current while the request is sent, so its traceparent names it.
+func traceAttempt(tracer trace.Tracer, u *url.URL) endpoint.Middleware {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(http.MethodPost),
+		semconv.HTTPURLKey.String(u.String()),
+		semconv.NetPeerNameKey.String(u.Hostname()),
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(port))
+	}
+
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, span := tracer.Start(ctx, http.MethodPost+" "+u.Path,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+				trace.WithAttributes(requestAttributes(request)...),
+			)
+			defer span.End()
+
+			response, err := next(ctx, request)
+			if err != nil {
+				span.RecordError(err)
+				span.SetAttributes(pricingErrorCode.String(string(makeErrorResponse(err).Code)))
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return response, err
+		}
 	}
 }
 
-func stopTrace(tracer trace.Tracer, logger log.Logger) httptransport.ClientResponseFunc {
+// traceResponse sets the status code of the upstream response on the
+// client span.
+func traceResponse() httptransport.ClientResponseFunc {
 	return func(ctx context.Context, res *http.Response) context.Context {
-		ctx, span := otel.Tracer("Transport.PricingProxy").Start(ctx, "StopTrace")
-		span.End()
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(res.StatusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(res.StatusCode, trace.SpanKindClient))
+
 		return ctx
 	}
 }
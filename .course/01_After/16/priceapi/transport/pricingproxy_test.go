@@ -0,0 +1,308 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/discovery"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrRepoUnavailable is the 5xx error of an upstream that is down.
+var ErrRepoUnavailable = &service.Error{Code: service.REPO_UNAVAILABLE, Msg: "Repository Unavailable"}
+
+// discardMetrics records nothing.
+var discardMetrics = ProxyMetrics{
+	BreakerState: discard.NewGauge(),
+	HedgesFired:  discard.NewCounter(),
+	HedgesWon:    discard.NewCounter(),
+}
+
+// newUpstream serves the retail and wholesale handlers over the mock pricing
+// service, or fails every retail request with a 5xx error response when down
+// is set. It counts the retail requests it receives in hits.
+func newUpstream(hits *int32, down bool) *httptest.Server {
+	retail := MakeTotalRetailPriceHttpHandler(&MockLogger{}, new(MockPricingService))
+
+	mux := http.NewServeMux()
+	mux.Handle("/wholesale", MakeTotalWholesalePriceHttpHandler(&MockLogger{}, new(MockPricingService)))
+	mux.HandleFunc("/retail", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+
+		if down {
+			encodeError(r.Context(), ErrRepoUnavailable, w)
+			return
+		}
+
+		retail.ServeHTTP(w, r)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func Test_PricingServiceProxy_Errors(t *testing.T) {
+	tests := []struct {
+		code    string
+		qty     int
+		region  string
+		coupon  string
+		down    bool
+		err     error
+		message string
+		hits    int32
+		total   money.Amount
+	}{
+		{code: "aaa111", qty: 15, hits: 1, total: money.MustParse("194.85")},
+		{code: "aaa111", qty: 0, err: service.ErrInvalidQty, message: "Invalid Quantity Requested", hits: 1},
+		{code: "fff000", qty: 10, err: service.ErrCodeNotFound, message: "Code Not Found", hits: 1},
+		{code: "aaa111", qty: 1, region: "mars", err: service.ErrUnknownRegion, message: "Unknown Tax Region", hits: 1},
+		{code: "aaa111", qty: 1, coupon: "OLD", err: service.ErrExpiredCoupon, message: "Expired Coupon: OLD", hits: 1},
+		{code: "aaa111", qty: 1, down: true, err: service.ErrRepoUnavailable, message: "Repository Unavailable", hits: 3},
+	}
+
+	for id, test := range tests {
+		var hits int32
+		upstream := newUpstream(&hits, test.down)
+
+		proxy := NewPricingServiceProxy(context.Background(), sd.FixedInstancer{strings.TrimPrefix(upstream.URL, "http://")}, DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+		price, err := proxy.GetRetailTotal(context.Background(), test.code, test.qty, service.RetailOptions{PriceOptions: service.PriceOptions{Region: test.region}, Coupon: test.coupon})
+		upstream.Close()
+
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %v, not error %v~", id, test.err, err)
+		if test.err != nil {
+			assert.True(t, test.message == err.Error(), "~2|Test #%d expected message: %s, not message %s~", id, test.message, err.Error())
+		}
+		assert.True(t, test.hits == hits, "~2|Test #%d expected %d upstream requests, not %d~", id, test.hits, hits)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+	}
+}
+
+func Test_PricingServiceProxy_GetWholesaleTotal(t *testing.T) {
+	var hits int32
+	upstream := newUpstream(&hits, false)
+	defer upstream.Close()
+
+	proxy := NewPricingServiceProxy(context.Background(), sd.FixedInstancer{strings.TrimPrefix(upstream.URL, "http://")}, DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+	tests := []struct {
+		partner  string
+		code     string
+		qty      int
+		discount money.Rate
+		tier     service.Tier
+		total    money.Amount
+	}{
+		{partner: "superstore", code: "aaa111", qty: 15, discount: money.MustParseRate("0.15"), tier: service.Tier{MinQty: 1, Price: money.MustParse("12.99")}, total: money.MustParse("165.62")},
+		{partner: "joesdiscount", code: "bbb222", qty: 10, discount: money.MustParseRate("0.05"), tier: service.Tier{MinQty: 1, Price: money.MustParse("2.90")}, total: money.MustParse("27.55")},
+	}
+
+	for id, test := range tests {
+		price, err := proxy.GetWholesaleTotal(context.Background(), test.partner, test.code, test.qty, service.PriceOptions{})
+
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %v~", id, err)
+		assert.True(t, test.discount == price.Discount, "~2|Test #%d expected discount: %s, not discount %s~", id, test.discount, price.Discount)
+		assert.True(t, test.tier == price.Tier, "~2|Test #%d expected tier: %+v, not tier %+v~", id, test.tier, price.Tier)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+	}
+}
+
+func Test_PricingServiceProxy_ClientErrorsKeepBreakerClosed(t *testing.T) {
+	var hits int32
+	upstream := newUpstream(&hits, false)
+	defer upstream.Close()
+
+	proxy := NewPricingServiceProxy(context.Background(), sd.FixedInstancer{strings.TrimPrefix(upstream.URL, "http://")}, DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+	for i := 0; i < 10; i++ {
+		_, err := proxy.GetRetailTotal(context.Background(), "aaa111", 0, service.RetailOptions{})
+		assert.True(t, errors.Is(err, service.ErrInvalidQty), "~2|Test #%d expected error: %v, not error %v~", i, service.ErrInvalidQty, err)
+	}
+
+	price, err := proxy.GetRetailTotal(context.Background(), "aaa111", 15, service.RetailOptions{})
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+	assert.True(t, price.Total == money.MustParse("194.85"), "~2|Test expected total: 194.85, not total %s~", price.Total)
+	assert.True(t, hits == 11, "~2|Test expected 11 upstream requests, not %d~", hits)
+}
+
+// MockInstancer yields instances and counts the endpointers that follow it.
+type MockInstancer struct {
+	*discovery.StaticInstancer
+	registered *int32
+}
+
+func (mi MockInstancer) Register(ch chan<- sd.Event) {
+	atomic.AddInt32(mi.registered, 1)
+	mi.StaticInstancer.Register(ch)
+}
+
+func (mi MockInstancer) Deregister(ch chan<- sd.Event) {
+	mi.StaticInstancer.Deregister(ch)
+	atomic.AddInt32(mi.registered, -1)
+}
+
+func Test_PricingServiceProxy_StopsFollowingInstancer(t *testing.T) {
+	var hits, registered int32
+	upstream := newUpstream(&hits, false)
+	defer upstream.Close()
+
+	instancer := MockInstancer{StaticInstancer: discovery.NewStaticInstancer([]string{strings.TrimPrefix(upstream.URL, "http://")}), registered: &registered}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	proxy := NewPricingServiceProxy(ctx, instancer, DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+	_, err := proxy.GetRetailTotal(context.Background(), "aaa111", 15, service.RetailOptions{})
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+	assert.True(t, atomic.LoadInt32(&registered) == 3, "~2|Test expected 3 endpointers following the instancer, not %d~", atomic.LoadInt32(&registered))
+
+	cancel()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && atomic.LoadInt32(&registered) > 0; {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.True(t, atomic.LoadInt32(&registered) == 0, "~2|Test expected no endpointers following the instancer once the proxy is done, not %d~", atomic.LoadInt32(&registered))
+}
+
+func Test_PricingServiceProxy_Discovery(t *testing.T) {
+	var hitsA, hitsB int32
+	upstreamA, upstreamB := newUpstream(&hitsA, false), newUpstream(&hitsB, false)
+	defer upstreamA.Close()
+	defer upstreamB.Close()
+
+	path := filepath.Join(t.TempDir(), "instances.txt")
+	write := func(upstreams ...*httptest.Server) {
+		var lines []string
+		for _, upstream := range upstreams {
+			lines = append(lines, strings.TrimPrefix(upstream.URL, "http://"))
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(upstreamA)
+	instancer, err := discovery.NewFileInstancer(path, 0, &MockLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewPricingServiceProxy(context.Background(), instancer, DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+	// The endpointer picks up instance changes in the background, so each
+	// step waits for the proxy to settle before counting requests.
+	request := func() (hitA, hitB bool) {
+		a, b := atomic.LoadInt32(&hitsA), atomic.LoadInt32(&hitsB)
+		_, err := proxy.GetRetailTotal(context.Background(), "aaa111", 15, service.RetailOptions{})
+		assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+
+		return atomic.LoadInt32(&hitsA) > a, atomic.LoadInt32(&hitsB) > b
+	}
+
+	tests := []struct {
+		upstreams []*httptest.Server
+		toA       bool
+		toB       bool
+	}{
+		{upstreams: []*httptest.Server{upstreamA}, toA: true, toB: false},
+		{upstreams: []*httptest.Server{upstreamA, upstreamB}, toA: true, toB: true},
+		{upstreams: []*httptest.Server{upstreamB}, toA: false, toB: true},
+	}
+
+	for id, test := range tests {
+		write(test.upstreams...)
+		instancer.Check()
+
+		var toA, toB bool
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+			toA, toB = false, false
+			for i := 0; i < 4; i++ {
+				hitA, hitB := request()
+				toA, toB = toA || hitA, toB || hitB
+			}
+			if toA == test.toA && toB == test.toB {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		assert.True(t, test.toA == toA, "~2|Test #%d expected requests to A: %t, not %t~", id, test.toA, toA)
+		assert.True(t, test.toB == toB, "~2|Test #%d expected requests to B: %t, not %t~", id, test.toB, toB)
+	}
+}
+
+func Test_PricingServiceProxy_AttemptSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	tests := []struct {
+		down       bool
+		spans      int
+		status     int64
+		spanStatus codes.Code
+		errorCode  string
+	}{
+		{down: false, spans: 1, status: http.StatusOK, spanStatus: codes.Unset},
+		{down: true, spans: 3, status: http.StatusInternalServerError, spanStatus: codes.Error, errorCode: string(service.REPO_UNAVAILABLE)},
+	}
+
+	for id, test := range tests {
+		var hits int32
+		upstream := newUpstream(&hits, test.down)
+		instance := strings.TrimPrefix(upstream.URL, "http://")
+
+		proxy := NewPricingServiceProxy(context.Background(), discovery.NewStaticInstancer([]string{instance}), DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+		before := len(recorder.Ended())
+		_, _ = proxy.GetRetailTotal(context.Background(), "aaa111", 15, service.RetailOptions{})
+		upstream.Close()
+
+		// Each attempt, retries included, has its own client span.
+		var attempts []sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended()[before:] {
+			if span.SpanKind() == trace.SpanKindClient {
+				attempts = append(attempts, span)
+			}
+		}
+
+		assert.True(t, test.spans == len(attempts), "~2|Test #%d expected %d client spans, not %d~", id, test.spans, len(attempts))
+
+		for _, span := range attempts {
+			assert.True(t, span.Name() == "POST /retail", "~2|Test #%d expected name: POST /retail, not name %s~", id, span.Name())
+			assert.True(t, test.spanStatus == span.Status().Code, "~2|Test #%d expected span status: %s, not span status %s~", id, test.spanStatus, span.Status().Code)
+
+			status, _ := spanAttribute(span, "http.status_code")
+			assert.True(t, test.status == status.AsInt64(), "~2|Test #%d expected status code: %d, not status code %d~", id, test.status, status.AsInt64())
+
+			peer, _ := spanAttribute(span, "net.peer.name")
+			assert.True(t, strings.HasPrefix(instance, peer.AsString()+":"), "~2|Test #%d expected peer: %s, not peer %s~", id, instance, peer.AsString())
+
+			code, _ := spanAttribute(span, pricingCode)
+			assert.True(t, code.AsString() == "aaa111", "~2|Test #%d expected pricing code: aaa111, not pricing code %s~", id, code.AsString())
+
+			errorCode, _ := spanAttribute(span, pricingErrorCode)
+			assert.True(t, test.errorCode == errorCode.AsString(), "~2|Test #%d expected error code: %s, not error code %s~", id, test.errorCode, errorCode.AsString())
+		}
+	}
+}
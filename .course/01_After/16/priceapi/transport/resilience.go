@@ -0,0 +1,427 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/sony/gobreaker"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ROUTE_RETAIL    = "retail"
+	ROUTE_WHOLESALE = "wholesale"
+	ROUTE_QUOTE     = "quote"
+)
+
+// Duration is a time.Duration written as a string such as "250ms" in
+// resilience files.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) parse(s string) (err error) {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) (err error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"250ms\", not %s", data)
+	}
+
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) (err error) {
+	return d.parse(value.Value)
+}
+
+// BreakerPolicy sets when the circuit breaker of an instance opens. It
+// trips after Failures failures in a row, or once it has seen MinRequests
+// requests in an Interval of which FailureRatio or more failed; a zero
+// FailureRatio leaves the ratio out. It stays open for OpenTimeout and then
+// lets HalfOpenRequests through to test the instance.
+type BreakerPolicy struct {
+	Failures         uint32   `json:"failures" yaml:"failures"`
+	FailureRatio     float64  `json:"failure_ratio" yaml:"failure_ratio"`
+	MinRequests      uint32   `json:"min_requests" yaml:"min_requests"`
+	Interval         Duration `json:"interval" yaml:"interval"`
+	OpenTimeout      Duration `json:"open_timeout" yaml:"open_timeout"`
+	HalfOpenRequests uint32   `json:"half_open_requests" yaml:"half_open_requests"`
+}
+
+// BackoffPolicy sets the wait before each retry, which doubles from Base up
+// to Max. Jitter is the fraction of the wait, from 0 to 1, that is drawn at
+// random so that retries from many callers spread out.
+type BackoffPolicy struct {
+	Base   Duration `json:"base" yaml:"base"`
+	Max    Duration `json:"max" yaml:"max"`
+	Jitter float64  `json:"jitter" yaml:"jitter"`
+}
+
+// RoutePolicy is the resilience policy of one proxied route. RateLimit and
+// Burst limit the requests per second to each instance. A request is tried
+// up to MaxAttempts times within Budget, and each attempt is given up after
+// AttemptTimeout; a zero AttemptTimeout leaves attempts to the budget.
+// Hedging is off unless Hedge sets a delay.
+type RoutePolicy struct {
+	RateLimit      float64       `json:"rate_limit" yaml:"rate_limit"`
+	Burst          int           `json:"burst" yaml:"burst"`
+	MaxAttempts    int           `json:"max_attempts" yaml:"max_attempts"`
+	Budget         Duration      `json:"budget" yaml:"budget"`
+	AttemptTimeout Duration      `json:"attempt_timeout" yaml:"attempt_timeout"`
+	Breaker        BreakerPolicy `json:"breaker" yaml:"breaker"`
+	Backoff        BackoffPolicy `json:"backoff" yaml:"backoff"`
+	Hedge          HedgePolicy   `json:"hedge" yaml:"hedge"`
+}
+
+// ResiliencePolicy holds the policy of each route. Routes missing from
+// Routes use Default.
+type ResiliencePolicy struct {
+	Default RoutePolicy            `json:"default" yaml:"default"`
+	Routes  map[string]RoutePolicy `json:"routes" yaml:"routes"`
+}
+
+// DefaultResiliencePolicy allows 100 requests a second to each instance and
+// tries a request 3 times within 250ms, with no backoff. A breaker opens
+// after 5 failures in a row and tests the instance again after a minute.
+var DefaultResiliencePolicy = ResiliencePolicy{
+	Default: RoutePolicy{
+		RateLimit:   100,
+		Burst:       100,
+		MaxAttempts: 3,
+		Budget:      Duration(250 * time.Millisecond),
+		Breaker: BreakerPolicy{
+			Failures:         5,
+			OpenTimeout:      Duration(time.Minute),
+			HalfOpenRequests: 1,
+		},
+	},
+}
+
+// Route returns the policy of route.
+func (p ResiliencePolicy) Route(route string) (policy RoutePolicy) {
+	if policy, found := p.Routes[route]; found {
+		return policy
+	}
+
+	return p.Default
+}
+
+// Override applies set to the default policy and to that of every route,
+// such as to let a flag win over the resilience file.
+func (p *ResiliencePolicy) Override(set func(policy *RoutePolicy)) {
+	set(&p.Default)
+	for route, policy := range p.Routes {
+		set(&policy)
+		p.Routes[route] = policy
+	}
+}
+
+// Validate reports the first setting of p that cannot be used.
+func (p ResiliencePolicy) Validate() (err error) {
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+
+	for route, policy := range p.Routes {
+		switch route {
+		case ROUTE_RETAIL, ROUTE_WHOLESALE, ROUTE_QUOTE:
+		default:
+			return fmt.Errorf("unknown route %q, expected %s, %s or %s", route, ROUTE_RETAIL, ROUTE_WHOLESALE, ROUTE_QUOTE)
+		}
+
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("route %s: %w", route, err)
+		}
+	}
+
+	return nil
+}
+
+func (p RoutePolicy) validate() (err error) {
+	switch {
+	case p.RateLimit <= 0:
+		return fmt.Errorf("rate_limit %v must be positive", p.RateLimit)
+	case p.Burst < 1:
+		return fmt.Errorf("burst %d must be at least 1", p.Burst)
+	case p.MaxAttempts < 1:
+		return fmt.Errorf("max_attempts %d must be at least 1", p.MaxAttempts)
+	case p.Budget <= 0:
+		return fmt.Errorf("budget %s must be positive", p.Budget)
+	case p.AttemptTimeout < 0:
+		return fmt.Errorf("attempt_timeout %s must not be negative", p.AttemptTimeout)
+	case p.Breaker.Failures < 1 && p.Breaker.FailureRatio == 0:
+		return fmt.Errorf("breaker needs failures or a failure_ratio to trip")
+	case p.Breaker.FailureRatio < 0 || p.Breaker.FailureRatio > 1:
+		return fmt.Errorf("breaker failure_ratio %v is outside [0,1]", p.Breaker.FailureRatio)
+	case p.Breaker.OpenTimeout <= 0:
+		return fmt.Errorf("breaker open_timeout %s must be positive", p.Breaker.OpenTimeout)
+	case p.Backoff.Base < 0 || p.Backoff.Max < 0:
+		return fmt.Errorf("backoff base %s and max %s must not be negative", p.Backoff.Base, p.Backoff.Max)
+	case p.Backoff.Jitter < 0 || p.Backoff.Jitter > 1:
+		return fmt.Errorf("backoff jitter %v is outside [0,1]", p.Backoff.Jitter)
+	case p.Hedge.Delay < 0:
+		return fmt.Errorf("hedge delay %s must not be negative", p.Hedge.Delay)
+	case p.Hedge.Quantile < 0 || p.Hedge.Quantile >= 1:
+		return fmt.Errorf("hedge quantile %v is outside [0,1)", p.Hedge.Quantile)
+	case p.Hedge.Quantile > 0 && p.Hedge.Delay == 0:
+		return fmt.Errorf("hedge quantile needs a delay to use until replies have been seen")
+	}
+
+	return nil
+}
+
+// resilienceFile is a resilience file as written. Routes are kept raw so
+// that each can be read over the default policy and need only name the
+// settings it changes.
+type resilienceFile struct {
+	Default yaml.Node            `yaml:"default"`
+	Routes  map[string]yaml.Node `yaml:"routes"`
+}
+
+// LoadResiliencePolicy reads a resilience policy from the YAML or JSON file
+// at path, chosen by its extension. Settings left out of the default policy
+// keep those of DefaultResiliencePolicy, and settings left out of a route
+// keep those of the default policy.
+func LoadResiliencePolicy(path string) (policy ResiliencePolicy, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ResiliencePolicy{}, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		policy, err = parseJSONPolicy(data)
+	case ".yaml", ".yml":
+		policy, err = parseYAMLPolicy(data)
+	default:
+		return ResiliencePolicy{}, fmt.Errorf("%s: unknown format, expected .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return ResiliencePolicy{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return ResiliencePolicy{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+func parseYAMLPolicy(data []byte) (policy ResiliencePolicy, err error) {
+	var file resilienceFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return ResiliencePolicy{}, err
+	}
+
+	policy = ResiliencePolicy{Default: DefaultResiliencePolicy.Default}
+	if err := decodeYAMLRoute(&file.Default, &policy.Default); err != nil {
+		return ResiliencePolicy{}, fmt.Errorf("default: %w", err)
+	}
+
+	for route, node := range file.Routes {
+		routePolicy := policy.Default
+		if err := decodeYAMLRoute(&node, &routePolicy); err != nil {
+			return ResiliencePolicy{}, fmt.Errorf("route %s: %w", route, err)
+		}
+
+		if policy.Routes == nil {
+			policy.Routes = make(map[string]RoutePolicy)
+		}
+		policy.Routes[route] = routePolicy
+	}
+
+	return policy, nil
+}
+
+// decodeYAMLRoute reads node over policy, refusing settings it does not
+// know.
+func decodeYAMLRoute(node *yaml.Node, policy *RoutePolicy) (err error) {
+	if node.Kind == 0 {
+		return nil
+	}
+
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(out))
+	decoder.KnownFields(true)
+
+	return decoder.Decode(policy)
+}
+
+func parseJSONPolicy(data []byte) (policy ResiliencePolicy, err error) {
+	var file struct {
+		Default json.RawMessage            `json:"default"`
+		Routes  map[string]json.RawMessage `json:"routes"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return ResiliencePolicy{}, err
+	}
+
+	policy = ResiliencePolicy{Default: DefaultResiliencePolicy.Default}
+	if err := decodeJSONRoute(file.Default, &policy.Default); err != nil {
+		return ResiliencePolicy{}, fmt.Errorf("default: %w", err)
+	}
+
+	for route, raw := range file.Routes {
+		routePolicy := policy.Default
+		if err := decodeJSONRoute(raw, &routePolicy); err != nil {
+			return ResiliencePolicy{}, fmt.Errorf("route %s: %w", route, err)
+		}
+
+		if policy.Routes == nil {
+			policy.Routes = make(map[string]RoutePolicy)
+		}
+		policy.Routes[route] = routePolicy
+	}
+
+	return policy, nil
+}
+
+// decodeJSONRoute reads raw over policy, refusing settings it does not
+// know.
+func decodeJSONRoute(raw json.RawMessage, policy *RoutePolicy) (err error) {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(policy)
+}
+
+// attemptTimeout gives up an attempt after timeout, or leaves it to the
+// retry budget when timeout is zero.
+func attemptTimeout(timeout time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		if timeout <= 0 {
+			return next
+		}
+
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// newLimiter limits the requests to an instance to the rate of policy.
+func newLimiter(policy RoutePolicy) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(policy.RateLimit), policy.Burst)
+}
+
+// Breaker states as exported by the breaker state gauge.
+const (
+	BREAKER_CLOSED    = 0
+	BREAKER_HALF_OPEN = 1
+	BREAKER_OPEN      = 2
+)
+
+func breakerStateValue(state gobreaker.State) float64 {
+	switch state {
+	case gobreaker.StateHalfOpen:
+		return BREAKER_HALF_OPEN
+	case gobreaker.StateOpen:
+		return BREAKER_OPEN
+	default:
+		return BREAKER_CLOSED
+	}
+}
+
+// newBreaker makes the circuit breaker of instance on route. Every change
+// of state is logged and set on the state gauge, labelled by route and
+// instance.
+func newBreaker(route, instance string, policy BreakerPolicy, state metrics.Gauge, logger log.Logger) *gobreaker.CircuitBreaker {
+	state.With("route", route, "instance", instance).Set(BREAKER_CLOSED)
+
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        route + " " + instance,
+		MaxRequests: policy.HalfOpenRequests,
+		Interval:    time.Duration(policy.Interval),
+		Timeout:     time.Duration(policy.OpenTimeout),
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			if policy.Failures > 0 && counts.ConsecutiveFailures >= policy.Failures {
+				return true
+			}
+
+			return policy.FailureRatio > 0 && counts.Requests >= policy.MinRequests &&
+				float64(counts.TotalFailures)/float64(counts.Requests) >= policy.FailureRatio
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			_ = logger.Log("route", route, "instance", instance, "breaker", to.String(), "from", from.String())
+			state.With("route", route, "instance", instance).Set(breakerStateValue(to))
+		},
+	})
+}
+
+// maxBackoff caps the doubling of a backoff without a Max, well short of
+// overflowing a time.Duration.
+const maxBackoff = time.Duration(math.MaxInt64 / 2)
+
+// backoff returns the wait before retry n, the first retry being 1.
+func backoff(policy BackoffPolicy, n int) (wait time.Duration) {
+	if policy.Base <= 0 {
+		return 0
+	}
+
+	limit := maxBackoff
+	if policy.Max > 0 && time.Duration(policy.Max) < limit {
+		limit = time.Duration(policy.Max)
+	}
+
+	wait = time.Duration(policy.Base)
+	for i := 1; i < n && wait < limit; i++ {
+		if wait > limit/2 {
+			wait = limit
+			break
+		}
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+
+	if policy.Jitter > 0 {
+		spread := time.Duration(float64(wait) * policy.Jitter)
+		wait = wait - spread + time.Duration(rand.Int63n(int64(spread)+1))
+	}
+
+	return wait
+}
@@ -0,0 +1,227 @@
+package transport
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockGauge keeps the last value set for each set of label values.
+type MockGauge struct {
+	mu     *sync.Mutex
+	values map[string]float64
+	labels string
+}
+
+func (mg *MockGauge) With(labelValues ...string) metrics.Gauge {
+	return &MockGauge{mu: mg.mu, values: mg.values, labels: strings.Join(labelValues, ",")}
+}
+
+func (mg *MockGauge) Set(value float64) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	mg.values[mg.labels] = value
+}
+
+func (mg *MockGauge) Add(delta float64) {}
+
+func writePolicy(t *testing.T, name, policy string) (path string) {
+	path = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_LoadResiliencePolicy(t *testing.T) {
+	yamlPolicy := "default:\n" +
+		"  max_attempts: 2\n" +
+		"  attempt_timeout: 100ms\n" +
+		"  backoff:\n" +
+		"    base: 10ms\n" +
+		"    jitter: 0.5\n" +
+		"routes:\n" +
+		"  quote:\n" +
+		"    budget: 1s\n" +
+		"    breaker:\n" +
+		"      failures: 2\n"
+
+	jsonPolicy := `{"default": {"max_attempts": 2, "attempt_timeout": "100ms", "backoff": {"base": "10ms", "jitter": 0.5}},` +
+		` "routes": {"quote": {"budget": "1s", "breaker": {"failures": 2}}}}`
+
+	tests := []struct {
+		name      string
+		policy    string
+		err       string
+		retail    RoutePolicy
+		quote     RoutePolicy
+		unchanged bool
+	}{
+		{name: "policy.yaml", policy: yamlPolicy},
+		{name: "policy.json", policy: jsonPolicy},
+		{name: "policy.yml", policy: "", unchanged: true},
+		{name: "policy.toml", policy: "", err: "unknown format, expected .yaml, .yml or .json"},
+		{name: "policy.yaml", policy: "default:\n  retries: 2\n", err: "default: yaml: unmarshal errors:\n  line 1: field retries not found in type transport.RoutePolicy"},
+		{name: "policy.yaml", policy: "timeouts: {}\n", err: "field timeouts not found in type transport.resilienceFile"},
+		{name: "policy.json", policy: `{"default": {"budget": 250}}`, err: "default: duration must be a string such as \"250ms\", not 250"},
+		{name: "policy.yaml", policy: "routes:\n  refund:\n    burst: 5\n", err: "unknown route \"refund\", expected retail, wholesale or quote"},
+		{name: "policy.yaml", policy: "routes:\n  quote:\n    max_attempts: 0\n", err: "route quote: max_attempts 0 must be at least 1"},
+		{name: "policy.json", policy: `{"default": {"backoff": {"jitter": 2}}}`, err: "default: backoff jitter 2 is outside [0,1]"},
+		{name: "policy.yaml", policy: "routes:\n  retail:\n    hedge:\n      quantile: 0.95\n", err: "route retail: hedge quantile needs a delay to use until replies have been seen"},
+	}
+
+	for id, test := range tests {
+		policy, err := LoadResiliencePolicy(writePolicy(t, test.name, test.policy))
+
+		actualErr := ""
+		if err != nil {
+			actualErr = err.Error()
+		}
+		assert.True(t, strings.HasSuffix(actualErr, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, actualErr)
+		if test.err != "" {
+			continue
+		}
+
+		if test.unchanged {
+			assert.True(t, DefaultResiliencePolicy.Default == policy.Route(ROUTE_RETAIL), "~2|Test #%d expected the default policy, not policy %+v~", id, policy.Route(ROUTE_RETAIL))
+			continue
+		}
+
+		retail, quote := policy.Route(ROUTE_RETAIL), policy.Route(ROUTE_QUOTE)
+
+		assert.True(t, retail.MaxAttempts == 2 && quote.MaxAttempts == 2, "~2|Test #%d expected 2 attempts, not %d and %d~", id, retail.MaxAttempts, quote.MaxAttempts)
+		assert.True(t, retail.AttemptTimeout == Duration(100*time.Millisecond), "~2|Test #%d expected attempt timeout: 100ms, not %s~", id, retail.AttemptTimeout)
+		assert.True(t, retail.Backoff == BackoffPolicy{Base: Duration(10 * time.Millisecond), Jitter: 0.5}, "~2|Test #%d expected backoff: 10ms, not backoff %+v~", id, retail.Backoff)
+		assert.True(t, retail.RateLimit == 100, "~2|Test #%d expected rate limit: 100, not %v~", id, retail.RateLimit)
+		assert.True(t, retail.Budget == Duration(250*time.Millisecond), "~2|Test #%d expected retail budget: 250ms, not %s~", id, retail.Budget)
+		assert.True(t, quote.Budget == Duration(time.Second), "~2|Test #%d expected quote budget: 1s, not %s~", id, quote.Budget)
+		assert.True(t, retail.Breaker.Failures == 5, "~2|Test #%d expected retail breaker failures: 5, not %d~", id, retail.Breaker.Failures)
+		assert.True(t, quote.Breaker.Failures == 2, "~2|Test #%d expected quote breaker failures: 2, not %d~", id, quote.Breaker.Failures)
+		assert.True(t, quote.Breaker.OpenTimeout == Duration(time.Minute), "~2|Test #%d expected quote breaker open timeout: 1m0s, not %s~", id, quote.Breaker.OpenTimeout)
+	}
+}
+
+func Test_ResiliencePolicy_Override(t *testing.T) {
+	policy, err := LoadResiliencePolicy(writePolicy(t, "policy.yaml", "routes:\n  quote:\n    max_attempts: 5\n"))
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+
+	policy.Override(func(p *RoutePolicy) { p.MaxAttempts = 1 })
+
+	for _, route := range []string{ROUTE_RETAIL, ROUTE_WHOLESALE, ROUTE_QUOTE} {
+		attempts := policy.Route(route).MaxAttempts
+		assert.True(t, attempts == 1, "~2|Test expected 1 attempt on %s, not %d~", route, attempts)
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	ms := func(n int) Duration { return Duration(time.Duration(n) * time.Millisecond) }
+
+	tests := []struct {
+		policy BackoffPolicy
+		n      int
+		min    time.Duration
+		max    time.Duration
+	}{
+		{policy: BackoffPolicy{}, n: 1, min: 0, max: 0},
+		{policy: BackoffPolicy{Base: ms(10)}, n: 1, min: 10 * time.Millisecond, max: 10 * time.Millisecond},
+		{policy: BackoffPolicy{Base: ms(10)}, n: 3, min: 40 * time.Millisecond, max: 40 * time.Millisecond},
+		{policy: BackoffPolicy{Base: ms(10), Max: ms(25)}, n: 3, min: 25 * time.Millisecond, max: 25 * time.Millisecond},
+		{policy: BackoffPolicy{Base: ms(10), Max: ms(25)}, n: 60, min: 25 * time.Millisecond, max: 25 * time.Millisecond},
+		{policy: BackoffPolicy{Base: ms(20), Jitter: 0.5}, n: 1, min: 10 * time.Millisecond, max: 20 * time.Millisecond},
+		{policy: BackoffPolicy{Base: ms(10)}, n: 1000, min: maxBackoff, max: maxBackoff},
+		{policy: BackoffPolicy{Base: ms(10), Jitter: 1}, n: 1000, min: 0, max: maxBackoff},
+	}
+
+	for id, test := range tests {
+		for i := 0; i < 20; i++ {
+			wait := backoff(test.policy, test.n)
+			assert.True(t, wait >= test.min && wait <= test.max, "~2|Test #%d expected a wait from %s to %s, not %s~", id, test.min, test.max, wait)
+		}
+	}
+}
+
+func Test_retryServerErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	long := BackoffPolicy{Base: Duration(time.Hour)}
+	short := BackoffPolicy{Base: Duration(time.Millisecond)}
+
+	timedOut := &url.Error{Op: "Post", URL: "http://10.0.0.1:8081/retail", Err: context.DeadlineExceeded}
+
+	tests := []struct {
+		ctx         context.Context
+		deadline    time.Time
+		policy      BackoffPolicy
+		sideEffects bool
+		err         error
+		keepTrying  bool
+		expected    error
+	}{
+		{ctx: context.Background(), deadline: time.Now().Add(time.Minute), policy: short, err: ErrRepoUnavailable, keepTrying: true},
+		{ctx: context.Background(), deadline: time.Now().Add(time.Minute), policy: short, err: &ErrorResponse{Code: service.INVALID_QTY}, keepTrying: false},
+		{ctx: context.Background(), deadline: time.Now().Add(time.Minute), policy: long, err: ErrRepoUnavailable, keepTrying: false},
+		{ctx: canceled, deadline: time.Now().Add(2 * time.Hour), policy: long, err: ErrRepoUnavailable, keepTrying: false, expected: context.Canceled},
+		{ctx: context.Background(), deadline: time.Now().Add(time.Minute), policy: short, err: timedOut, keepTrying: true},
+		{ctx: context.Background(), deadline: time.Now().Add(time.Minute), policy: short, sideEffects: true, err: timedOut, keepTrying: false},
+		{ctx: context.Background(), deadline: time.Now().Add(time.Minute), policy: short, sideEffects: true, err: ErrRepoUnavailable, keepTrying: true},
+	}
+
+	for id, test := range tests {
+		begin := time.Now()
+		keepTrying, replacement := retryServerErrors(test.ctx, test.deadline, 3, test.policy, test.sideEffects)(1, test.err)
+
+		assert.True(t, test.keepTrying == keepTrying, "~2|Test #%d expected keep trying: %t, not %t~", id, test.keepTrying, keepTrying)
+		assert.True(t, test.expected == replacement, "~2|Test #%d expected replacement: %v, not %v~", id, test.expected, replacement)
+		assert.True(t, time.Since(begin) < time.Second, "~2|Test #%d expected no long wait, not %s~", id, time.Since(begin))
+	}
+}
+
+func Test_PricingServiceProxy_BreakerState(t *testing.T) {
+	var hits int32
+	upstream := newUpstream(&hits, true)
+	defer upstream.Close()
+
+	instance := strings.TrimPrefix(upstream.URL, "http://")
+
+	policy := DefaultResiliencePolicy
+	policy.Default.MaxAttempts = 1
+	policy.Default.Breaker.Failures = 2
+
+	gauge := &MockGauge{mu: new(sync.Mutex), values: make(map[string]float64)}
+	proxy := NewPricingServiceProxy(context.Background(), sd.FixedInstancer{instance}, DefaultBalancerConfig, policy, ProxyMetrics{BreakerState: gauge, HedgesFired: discard.NewCounter(), HedgesWon: discard.NewCounter()}, &MockLogger{})
+
+	tests := []struct {
+		hits  int32
+		state float64
+	}{
+		{hits: 1, state: BREAKER_CLOSED},
+		{hits: 2, state: BREAKER_OPEN},
+		{hits: 2, state: BREAKER_OPEN},
+	}
+
+	for id, test := range tests {
+		_, err := proxy.GetRetailTotal(context.Background(), "aaa111", 1, service.RetailOptions{})
+		assert.True(t, err != nil, "~2|Test #%d expected an error~", id)
+
+		gauge.mu.Lock()
+		state := gauge.values["route,retail,instance,"+instance]
+		gauge.mu.Unlock()
+
+		assert.True(t, test.hits == hits, "~2|Test #%d expected %d upstream requests, not %d~", id, test.hits, hits)
+		assert.True(t, test.state == state, "~2|Test #%d expected breaker state: %v, not state %v~", id, test.state, state)
+	}
+}
@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/discovery"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_TraceContext_GatewayAndService(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	service := httptest.NewServer(MakeTotalRetailPriceHttpHandler(&MockLogger{}, new(MockPricingService)))
+	defer service.Close()
+
+	proxy := NewPricingServiceProxy(context.Background(), discovery.NewStaticInstancer([]string{strings.TrimPrefix(service.URL, "http://")}),
+		DefaultBalancerConfig, DefaultResiliencePolicy, discardMetrics, &MockLogger{})
+
+	gateway := httptest.NewServer(MakeTotalRetailPriceHttpHandler(&MockLogger{}, proxy))
+	defer gateway.Close()
+
+	tests := []struct {
+		traceparent string
+		traceID     string
+	}{
+		{traceparent: ""},
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+	}
+
+	for id, test := range tests {
+		before := len(recorder.Ended())
+
+		req, _ := http.NewRequest(http.MethodPost, gateway.URL, bytes.NewBufferString(`{"code":"aaa111","qty":15}`))
+		if test.traceparent != "" {
+			req.Header.Set("traceparent", test.traceparent)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+		resp.Body.Close()
+
+		spans := recorder.Ended()[before:]
+
+		// Both the gateway and the service end a server span for the
+		// request. The one in the service continues the client span of the
+		// proxy, which it sent along in the traceparent header.
+		var sent, gatewaySpan, serviceSpan sdktrace.ReadOnlySpan
+		for _, span := range spans {
+			if span.SpanKind() == trace.SpanKindClient {
+				sent = span
+			}
+		}
+		for _, span := range spans {
+			if span.SpanKind() != trace.SpanKindServer {
+				continue
+			}
+
+			if sent != nil && span.Parent().SpanID() == sent.SpanContext().SpanID() {
+				serviceSpan = span
+			} else {
+				gatewaySpan = span
+			}
+		}
+
+		assert.True(t, gatewaySpan != nil && serviceSpan != nil, "~2|Test #%d expected a gateway and a service span~", id)
+		if gatewaySpan == nil || serviceSpan == nil {
+			continue
+		}
+
+		assert.True(t, serviceSpan.Parent().IsRemote(), "~2|Test #%d expected the service span to have a remote parent~", id)
+
+		traceID := gatewaySpan.SpanContext().TraceID()
+		assert.True(t, traceID == serviceSpan.SpanContext().TraceID(), "~2|Test #%d expected the service span in trace %s, not trace %s~", id, traceID, serviceSpan.SpanContext().TraceID())
+		if test.traceID != "" {
+			assert.True(t, test.traceID == traceID.String(), "~2|Test #%d expected trace ID: %s, not trace ID %s~", id, test.traceID, traceID)
+		}
+
+		for _, span := range spans {
+			assert.True(t, traceID == span.SpanContext().TraceID(), "~2|Test #%d expected span %s in trace %s, not trace %s~", id, span.Name(), traceID, span.SpanContext().TraceID())
+		}
+	}
+}
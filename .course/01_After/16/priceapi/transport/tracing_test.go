@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type FailingPricingService struct {
+	MockPricingService
+}
+
+func (FailingPricingService) GetRetailTotal(ctx context.Context, code string, qty int, opts service.RetailOptions) (price service.Price, err error) {
+	return service.Price{}, errors.New("connection refused")
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (value attribute.Value, found bool) {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+
+	return attribute.Value{}, false
+}
+
+func Test_ServerSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	tests := []struct {
+		pricingService PricingService
+		body           string
+		status         int
+		spanStatus     codes.Code
+		code           string
+		errorCode      string
+	}{
+		{pricingService: new(MockPricingService), body: `{"code":"aaa111","qty":15}`, status: http.StatusOK, spanStatus: codes.Unset, code: "aaa111"},
+		{pricingService: new(MockPricingService), body: `{"code":"fff000","qty":10}`, status: http.StatusNotFound, spanStatus: codes.Unset, code: "fff000", errorCode: string(service.CODE_NOT_FOUND)},
+		{pricingService: new(MockPricingService), body: `"test"`, status: http.StatusBadRequest, spanStatus: codes.Unset, errorCode: string(service.INVALID_REQUEST)},
+		{pricingService: new(FailingPricingService), body: `{"code":"aaa111","qty":15}`, status: http.StatusInternalServerError, spanStatus: codes.Error, code: "aaa111", errorCode: string(service.INTERNAL)},
+	}
+
+	for id, test := range tests {
+		before := len(recorder.Ended())
+		server := httptest.NewServer(MakeTotalRetailPriceHttpHandler(&MockLogger{}, test.pricingService))
+
+		resp, err := http.Post(server.URL, "application/json", bytes.NewBufferString(test.body))
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		var span sdktrace.ReadOnlySpan
+		for _, ended := range recorder.Ended()[before:] {
+			if ended.SpanKind() == trace.SpanKindServer {
+				span = ended
+			}
+		}
+
+		assert.True(t, span != nil, "~2|Test #%d expected a server span~", id)
+		if span == nil {
+			continue
+		}
+
+		assert.True(t, span.Name() == "/retail", "~2|Test #%d expected name: /retail, not name %s~", id, span.Name())
+		assert.True(t, test.spanStatus == span.Status().Code, "~2|Test #%d expected span status: %s, not span status %s~", id, test.spanStatus, span.Status().Code)
+
+		method, _ := spanAttribute(span, "http.method")
+		assert.True(t, method.AsString() == http.MethodPost, "~2|Test #%d expected method: %s, not method %s~", id, http.MethodPost, method.AsString())
+
+		route, _ := spanAttribute(span, "http.route")
+		assert.True(t, route.AsString() == "/retail", "~2|Test #%d expected route: /retail, not route %s~", id, route.AsString())
+
+		status, _ := spanAttribute(span, "http.status_code")
+		assert.True(t, int64(test.status) == status.AsInt64(), "~2|Test #%d expected status code: %d, not status code %d~", id, test.status, status.AsInt64())
+
+		code, _ := spanAttribute(span, pricingCode)
+		assert.True(t, test.code == code.AsString(), "~2|Test #%d expected pricing code: %s, not pricing code %s~", id, test.code, code.AsString())
+
+		errorCode, _ := spanAttribute(span, pricingErrorCode)
+		assert.True(t, test.errorCode == errorCode.AsString(), "~2|Test #%d expected error code: %s, not error code %s~", id, test.errorCode, errorCode.AsString())
+
+		_, peer := spanAttribute(span, "net.peer.ip")
+		assert.True(t, peer, "~2|Test #%d expected the peer address~", id)
+	}
+}
+
+func Test_TraceEndpoint(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tests := []struct {
+		request   TotalRetailPriceRequest
+		status    codes.Code
+		errorCode string
+	}{
+		{request: TotalRetailPriceRequest{Code: "aaa111", Qty: 15}, status: codes.Unset},
+		{request: TotalRetailPriceRequest{Code: "aaa111", Qty: 0}, status: codes.Error, errorCode: string(service.INVALID_QTY)},
+	}
+
+	e := TraceEndpoint(provider.Tracer("Transport.Endpoint"), "GetRetailTotal")(MakeTotalRetailPriceEndpoint(new(MockPricingService)))
+
+	for id, test := range tests {
+		e(context.Background(), test.request)
+
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+
+		assert.True(t, span.Name() == "GetRetailTotal", "~2|Test #%d expected name: GetRetailTotal, not name %s~", id, span.Name())
+		assert.True(t, test.status == span.Status().Code, "~2|Test #%d expected status: %s, not status %s~", id, test.status, span.Status().Code)
+
+		code, _ := spanAttribute(span, pricingCode)
+		assert.True(t, test.request.Code == code.AsString(), "~2|Test #%d expected pricing code: %s, not pricing code %s~", id, test.request.Code, code.AsString())
+
+		errorCode, _ := spanAttribute(span, pricingErrorCode)
+		assert.True(t, test.errorCode == errorCode.AsString(), "~2|Test #%d expected error code: %s, not error code %s~", id, test.errorCode, errorCode.AsString())
+	}
+}
@@ -6,72 +6,61 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 
-	gkendpoint "github.com/go-kit/kit/endpoint"
-	httptransport "github.com/go-kit/kit/transport/http"
-	"github.com/go-kit/log"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
 )
 
+// The payloads, endpoints, handlers, request and response decoders and
+// client of the routes of the pricing service are generated into this
+// package by the pricing service.
+
 const (
 	INVALID_REQUEST = "Invalid Request"
 )
 
-func decodeTotalRetailPriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request TotalRetailPriceRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, &ErrorResponse{Err: INVALID_REQUEST}
-	}
+// errInvalidRequest is the error of a request that does not decode, and
+// errInvalidResponse that of an upstream response.
+var (
+	errInvalidRequest  = &ErrorResponse{Code: service.INVALID_REQUEST, Message: INVALID_REQUEST}
+	errInvalidResponse = &ErrorResponse{Code: service.INTERNAL, Message: INVALID_RESPONSE}
+)
 
-	return request, nil
-}
+func (request *TotalRetailPriceRequest) decodeQuery(query url.Values) (err error) {
+	explain, err := explainQuery(query)
+	request.Explain = request.Explain || explain
 
-func decodeTotalRetailPriceResponse(_ context.Context, r *http.Response) (interface{}, error) {
-	var response TotalRetailPriceResponse
-	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
-		return nil, &ErrorResponse{Err: INVALID_RESPONSE}
-	}
-	return response, nil
+	return err
 }
 
-func MakeTotalRetailPriceHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
-	var retailEndpoint gkendpoint.Endpoint
-	retailEndpoint = MakeTotalRetailPriceEndpoint(svc)
-	retailEndpoint = LogTotalRetailPriceEndpoint(log.With(logger, "service", "PricingService"))(retailEndpoint)
+func (request *TotalWholesalePriceRequest) decodeQuery(query url.Values) (err error) {
+	explain, err := explainQuery(query)
+	request.Explain = request.Explain || explain
 
-	return httptransport.NewServer(
-		retailEndpoint,
-		decodeTotalRetailPriceRequest,
-		encodeResponse,
-	)
+	return err
 }
 
-func decodeTotalWholesalePriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request TotalWholesalePriceRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, &ErrorResponse{Err: INVALID_REQUEST}
+// explainQuery reads the optional explain=true query parameter, which asks
+// for a breakdown just as the explain field of the request body does.
+func explainQuery(query url.Values) (explain bool, err error) {
+	value := query.Get("explain")
+	if value == "" {
+		return false, nil
 	}
 
-	return request, nil
+	return strconv.ParseBool(value)
 }
 
-func decodeTotalWholesalePriceResponse(_ context.Context, r *http.Response) (interface{}, error) {
-	var response TotalWholesalePriceResponse
-	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
-		return nil, &ErrorResponse{Err: INVALID_RESPONSE}
+// decodeErrorResponse reads the error envelope of a failed upstream
+// request, so that its code reaches the caller unchanged.
+func decodeErrorResponse(r *http.Response) error {
+	var response ErrorResponse
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil || response.Code == "" {
+		return errInvalidResponse
 	}
-	return response, nil
-}
-
-func MakeTotalWholesalePriceHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
-	var wholesaleEndpoint gkendpoint.Endpoint
-	wholesaleEndpoint = MakeTotalWholesalePriceEndpoint(svc)
-	wholesaleEndpoint = LogTotalWholesalePriceEndpoint(log.With(logger, "service", "PricingService"))(wholesaleEndpoint)
 
-	return httptransport.NewServer(
-		wholesaleEndpoint,
-		decodeTotalWholesalePriceRequest,
-		encodeResponse,
-	)
+	return &response
 }
 
 func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
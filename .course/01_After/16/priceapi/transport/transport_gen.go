@@ -0,0 +1,158 @@
+// Code generated by kitgen from service.go; DO NOT EDIT.
+
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+	"go.opentelemetry.io/otel"
+)
+
+func LogTotalRetailPriceEndpoint(logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			logger.Log("endpoint", "TotalRetailPriceEndpoint", "msg", "Calling endpoint")
+			defer logger.Log("endpoint", "TotalRetailPriceEndpoint", "msg", "Called endpoint")
+
+			return next(ctx, request)
+		}
+	}
+}
+
+func decodeTotalRetailPriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request TotalRetailPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, errInvalidRequest
+	}
+	if err := decodeQuery(&request, r); err != nil {
+		return nil, errInvalidRequest
+	}
+
+	return request, nil
+}
+
+func MakeTotalRetailPriceHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
+	tracer := otel.Tracer("Transport.Transport")
+
+	var totalRetailPriceEndpoint endpoint.Endpoint
+	totalRetailPriceEndpoint = MakeTotalRetailPriceEndpoint(svc)
+	totalRetailPriceEndpoint = LogTotalRetailPriceEndpoint(log.With(logger, "service", "PricingService"))(totalRetailPriceEndpoint)
+	totalRetailPriceEndpoint = TraceEndpoint(otel.Tracer("Transport.Endpoint"), "GetRetailTotal")(totalRetailPriceEndpoint)
+	totalRetailPriceEndpoint = traceRequest(totalRetailPriceEndpoint)
+
+	return httptransport.NewServer(
+		totalRetailPriceEndpoint,
+		decodeTotalRetailPriceRequest,
+		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(extractTrace(), startServerSpan(tracer, "/retail")),
+		httptransport.ServerFinalizer(finishServerSpan),
+		httptransport.ServerErrorHandler(traceErrors()),
+	)
+}
+
+func LogTotalWholesalePriceEndpoint(logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			logger.Log("endpoint", "TotalWholesalePriceEndpoint", "msg", "Calling endpoint")
+			defer logger.Log("endpoint", "TotalWholesalePriceEndpoint", "msg", "Called endpoint")
+
+			return next(ctx, request)
+		}
+	}
+}
+
+func decodeTotalWholesalePriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request TotalWholesalePriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, errInvalidRequest
+	}
+	if err := decodeQuery(&request, r); err != nil {
+		return nil, errInvalidRequest
+	}
+
+	return request, nil
+}
+
+func MakeTotalWholesalePriceHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
+	tracer := otel.Tracer("Transport.Transport")
+
+	var totalWholesalePriceEndpoint endpoint.Endpoint
+	totalWholesalePriceEndpoint = MakeTotalWholesalePriceEndpoint(svc)
+	totalWholesalePriceEndpoint = LogTotalWholesalePriceEndpoint(log.With(logger, "service", "PricingService"))(totalWholesalePriceEndpoint)
+	totalWholesalePriceEndpoint = TraceEndpoint(otel.Tracer("Transport.Endpoint"), "GetWholesaleTotal")(totalWholesalePriceEndpoint)
+	totalWholesalePriceEndpoint = traceRequest(totalWholesalePriceEndpoint)
+
+	return httptransport.NewServer(
+		totalWholesalePriceEndpoint,
+		decodeTotalWholesalePriceRequest,
+		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(extractTrace(), startServerSpan(tracer, "/wholesale")),
+		httptransport.ServerFinalizer(finishServerSpan),
+		httptransport.ServerErrorHandler(traceErrors()),
+	)
+}
+
+func LogQuoteEndpoint(logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			logger.Log("endpoint", "QuoteEndpoint", "msg", "Calling endpoint")
+			defer logger.Log("endpoint", "QuoteEndpoint", "msg", "Called endpoint")
+
+			return next(ctx, request)
+		}
+	}
+}
+
+func decodeQuoteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, errInvalidRequest
+	}
+	if err := decodeQuery(&request, r); err != nil {
+		return nil, errInvalidRequest
+	}
+
+	return request, nil
+}
+
+func MakeQuoteHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
+	tracer := otel.Tracer("Transport.Transport")
+
+	var quoteEndpoint endpoint.Endpoint
+	quoteEndpoint = MakeQuoteEndpoint(svc)
+	quoteEndpoint = LogQuoteEndpoint(log.With(logger, "service", "PricingService"))(quoteEndpoint)
+	quoteEndpoint = TraceEndpoint(otel.Tracer("Transport.Endpoint"), "GetQuote")(quoteEndpoint)
+	quoteEndpoint = traceRequest(quoteEndpoint)
+
+	return httptransport.NewServer(
+		quoteEndpoint,
+		decodeQuoteRequest,
+		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(extractTrace(), startServerSpan(tracer, "/quote")),
+		httptransport.ServerFinalizer(finishServerSpan),
+		httptransport.ServerErrorHandler(traceErrors()),
+	)
+}
+
+// queryDecoder is a request that takes query parameters as well as its body.
+type queryDecoder interface {
+	decodeQuery(query url.Values) error
+}
+
+// decodeQuery has request read the query parameters of r, if it takes any.
+func decodeQuery(request interface{}, r *http.Request) error {
+	if qd, ok := request.(queryDecoder); ok {
+		return qd.decodeQuery(r.URL.Query())
+	}
+
+	return nil
+}
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	KIND_LOGGING       = "logging"
+	KIND_INSTRUMENTING = "instrumenting"
+	KIND_TRACING       = "tracing"
+	KIND_PAYLOAD       = "payload"
+	KIND_ENDPOINT      = "endpoint"
+	KIND_TRANSPORT     = "transport"
+	KIND_CLIENT        = "client"
+)
+
+// Options set what is generated and where it goes. Package is the package
+// of the generated file, the package of the service when blank. Prefix
+// starts the key of every span attribute, and ErrorCode names the function
+// of the service package that gives the code of an error for spans.
+type Options struct {
+	Kind      string
+	Source    string
+	Package   string
+	Prefix    string
+	ErrorCode string
+}
+
+// knownImports are the packages generated code may use, by name.
+var knownImports = map[string]string{
+	"attribute":     "go.opentelemetry.io/otel/attribute",
+	"codes":         "go.opentelemetry.io/otel/codes",
+	"context":       "context",
+	"endpoint":      "github.com/go-kit/kit/endpoint",
+	"fmt":           "fmt",
+	"http":          "net/http",
+	"httptransport": "github.com/go-kit/kit/transport/http",
+	"json":          "encoding/json",
+	"log":           "github.com/go-kit/log",
+	"metrics":       "github.com/go-kit/kit/metrics",
+	"otel":          "go.opentelemetry.io/otel",
+	"time":          "time",
+	"trace":         "go.opentelemetry.io/otel/trace",
+	"url":           "net/url",
+}
+
+// attributeKinds are the attribute constructors of the basic types. Values
+// of other types are traced as strings.
+var attributeKinds = map[string]string{
+	"string":  "String",
+	"int":     "Int",
+	"int64":   "Int64",
+	"bool":    "Bool",
+	"float64": "Float64",
+}
+
+// Generate returns the source of the file kind for svc.
+func Generate(svc *Service, opts Options) (src []byte, err error) {
+	tmpl, found := templates[opts.Kind]
+	if !found {
+		return nil, fmt.Errorf("unknown kind %q, expected %s, %s, %s, %s, %s, %s or %s", opts.Kind,
+			KIND_LOGGING, KIND_INSTRUMENTING, KIND_TRACING, KIND_PAYLOAD, KIND_ENDPOINT, KIND_TRANSPORT, KIND_CLIENT)
+	}
+
+	pkg := opts.Package
+	if pkg == "" {
+		pkg = svc.Package
+	}
+
+	qualifier := ""
+	if pkg != svc.Package {
+		qualifier = svc.Package
+	}
+
+	view := newView(svc, opts, qualifier)
+	if opts.Kind == KIND_PAYLOAD || opts.Kind == KIND_ENDPOINT || opts.Kind == KIND_CLIENT {
+		if view.payloads, err = derivePayloads(svc, qualifier, view.Methods); err != nil {
+			return nil, err
+		}
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// Code generated by kitgen from %s; DO NOT EDIT.\n\n", path.Base(opts.Source))
+	fmt.Fprintf(&body, "package %s\n\n", pkg)
+	if err := tmpl.Execute(&body, view); err != nil {
+		return nil, err
+	}
+
+	imports := make(map[string]string, len(knownImports)+len(svc.Imports)+2)
+	for name, importPath := range knownImports {
+		imports[name] = importPath
+	}
+	imports["service"] = path.Join(svc.Module, "service")
+	for name, importPath := range svc.Imports {
+		imports[name] = importPath
+	}
+	if qualifier != "" {
+		imports[qualifier] = svc.ImportPath
+	}
+
+	src, err = addImports(body.Bytes(), imports)
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(src)
+}
+
+// addImports adds the import declaration of the packages src uses.
+func addImports(src []byte, imports map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not parse: %w\n%s", err, src)
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	var std, other []string
+	for name := range used {
+		importPath, found := imports[name]
+		if !found {
+			return nil, fmt.Errorf("generated code uses unknown package %s", name)
+		}
+
+		spec := fmt.Sprintf("%q", importPath)
+		if path.Base(importPath) != name {
+			spec = name + " " + spec
+		}
+
+		if strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+			other = append(other, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	if len(std)+len(other) == 0 {
+		return src, nil
+	}
+
+	sort.Slice(std, func(i, j int) bool { return unquoted(std[i]) < unquoted(std[j]) })
+	sort.Slice(other, func(i, j int) bool { return unquoted(other[i]) < unquoted(other[j]) })
+
+	var decl bytes.Buffer
+	decl.WriteString("import (\n")
+	for _, spec := range std {
+		decl.WriteString("\t" + spec + "\n")
+	}
+	if len(std) > 0 && len(other) > 0 {
+		decl.WriteString("\n")
+	}
+	for _, spec := range other {
+		decl.WriteString("\t" + spec + "\n")
+	}
+	decl.WriteString(")\n\n")
+
+	offset := fset.Position(file.Name.End()).Offset
+	for offset < len(src) && src[offset] == '\n' {
+		offset++
+	}
+
+	out := append([]byte{}, src[:offset]...)
+	out = append(out, decl.Bytes()...)
+	return append(out, src[offset:]...), nil
+}
+
+func unquoted(spec string) string {
+	return spec[strings.Index(spec, `"`):]
+}
+
+type view struct {
+	Service       string
+	Methods       []methodView
+	Constants     []constantView
+	ErrorCode     string
+	ErrorConstant string
+
+	*payloads
+}
+
+type methodView struct {
+	Method
+	Ctx         string
+	Signature   string
+	ResultList  string
+	Args        string
+	ResultNames string
+	LogFields   []Item
+	StartAttrs  []string
+	EndAttrs    []string
+
+	ServerArgs     string
+	ServerResponse string
+	ClientRequest  string
+	ClientReads    []string
+}
+
+type constantView struct {
+	Name string
+	Key  string
+}
+
+func newView(svc *Service, opts Options, qualifier string) (v view) {
+	v = view{Service: svc.Name, ErrorCode: opts.ErrorCode}
+
+	constants := make(map[string]bool)
+	constant := func(key string) string {
+		name := attributeName(opts.Prefix, key)
+		if !constants[name] {
+			constants[name] = true
+			v.Constants = append(v.Constants, constantView{Name: name, Key: opts.Prefix + key})
+		}
+		return name
+	}
+
+	for _, m := range svc.Methods {
+		mv := methodView{Method: m, Ctx: m.Params[0].Name, LogFields: m.Log}
+
+		var params, args, results, names []string
+		for _, p := range m.Params {
+			typ := exprString(p.Type, qualifier)
+			if p.Variadic {
+				params = append(params, p.Name+" ..."+typ)
+				args = append(args, p.Name+"...")
+			} else {
+				params = append(params, p.Name+" "+typ)
+				args = append(args, p.Name)
+			}
+		}
+		for _, r := range m.Results {
+			results = append(results, r.Name+" "+exprString(r.Type, qualifier))
+			names = append(names, r.Name)
+		}
+		mv.Signature = strings.Join(params, ", ")
+		mv.Args = strings.Join(args, ", ")
+		mv.ResultList = strings.Join(results, ", ")
+		mv.ResultNames = strings.Join(names, ", ")
+
+		if opts.Kind == KIND_TRACING {
+			for _, item := range m.Trace {
+				attr := attributeValue(constant(item.Key), item)
+				if item.Result {
+					mv.EndAttrs = append(mv.EndAttrs, attr)
+				} else {
+					mv.StartAttrs = append(mv.StartAttrs, attr)
+				}
+			}
+		}
+
+		v.Methods = append(v.Methods, mv)
+	}
+
+	if opts.Kind == KIND_TRACING && opts.ErrorCode != "" {
+		v.ErrorConstant = constant("error_code")
+	}
+
+	return v
+}
+
+// attributeName names the constant of an attribute key: pricing. and
+// error_code give pricingErrorCode.
+func attributeName(prefix string, key string) string {
+	name := ""
+	for i, part := range strings.FieldsFunc(prefix+key, func(r rune) bool { return r == '.' || r == '_' || r == '-' }) {
+		if i == 0 {
+			name += lowerFirst(part)
+		} else {
+			name += upperFirst(part)
+		}
+	}
+
+	return name
+}
+
+func attributeValue(constant string, item Item) string {
+	if kind, found := attributeKinds[item.Type]; found {
+		return fmt.Sprintf("%s.%s(%s)", constant, kind, item.Expr)
+	}
+
+	return fmt.Sprintf("%s.String(fmt.Sprint(%s))", constant, item.Expr)
+}
+
+var funcs = template.FuncMap{
+	"lower": lowerFirst,
+}
+
+var templates = map[string]*template.Template{
+	KIND_LOGGING:       template.Must(template.New(KIND_LOGGING).Funcs(funcs).Parse(loggingTemplate)),
+	KIND_INSTRUMENTING: template.Must(template.New(KIND_INSTRUMENTING).Funcs(funcs).Parse(instrumentingTemplate)),
+	KIND_TRACING:       template.Must(template.New(KIND_TRACING).Funcs(funcs).Parse(tracingTemplate)),
+	KIND_PAYLOAD:       template.Must(template.New(KIND_PAYLOAD).Funcs(funcs).Parse(payloadTemplate)),
+	KIND_ENDPOINT:      template.Must(template.New(KIND_ENDPOINT).Funcs(funcs).Parse(endpointTemplate)),
+	KIND_TRANSPORT:     template.Must(template.New(KIND_TRANSPORT).Funcs(funcs).Parse(transportTemplate)),
+	KIND_CLIENT:        template.Must(template.New(KIND_CLIENT).Funcs(funcs).Parse(clientTemplate)),
+}
+
+const loggingTemplate = `type loggingMiddleware struct {
+	logger log.Logger
+	next   {{.Service}}
+}
+
+// NewLoggingMiddleware logs every call to next, with its values, error and
+// duration.
+func NewLoggingMiddleware(logger log.Logger, next {{.Service}}) (lmw *loggingMiddleware) {
+	lmw = &loggingMiddleware{
+		logger: logger,
+		next:   next,
+	}
+
+	return
+}
+{{range .Methods}}
+func (mw loggingMiddleware) {{.Name}}({{.Signature}}) ({{.ResultList}}) {
+	defer func(begin time.Time) {
+		_ = mw.logger.Log(
+			"method", "{{.Name}}",
+			{{- range .LogFields}}
+			"{{.Key}}", {{.Expr}},
+			{{- end}}
+			"error", err,
+			"duration", time.Since(begin),
+		)
+	}(time.Now())
+
+	{{.ResultNames}} = mw.next.{{.Name}}({{.Args}})
+
+	return
+}
+{{end}}`
+
+const instrumentingTemplate = `type instrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	requestLatency metrics.Histogram
+	next           {{.Service}}
+}
+
+// NewInstrumentingMiddleware counts and times every call to next, labelled
+// by method and by whether it failed.
+func NewInstrumentingMiddleware(requestCount metrics.Counter, requestLatency metrics.Histogram, next {{.Service}}) (imw *instrumentingMiddleware) {
+	imw = &instrumentingMiddleware{
+		requestCount:   requestCount,
+		requestLatency: requestLatency,
+		next:           next,
+	}
+
+	return
+}
+{{range .Methods}}
+func (mw instrumentingMiddleware) {{.Name}}({{.Signature}}) ({{.ResultList}}) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "{{.Name}}", "error", fmt.Sprint(err != nil)}
+
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	{{.ResultNames}} = mw.next.{{.Name}}({{.Args}})
+
+	return
+}
+{{end}}`
+
+const tracingTemplate = `{{if .Constants}}// Attributes of the spans of the service.
+const (
+{{- range .Constants}}
+	{{.Name}} = attribute.Key("{{.Key}}")
+{{- end}}
+)
+
+{{end}}type tracingMiddleware struct {
+	tracer trace.Tracer
+	next   {{.Service}}
+}
+
+// NewTracingMiddleware gives every call to next a span, named by method,
+// with the values it was called with and those it returned. Failed calls
+// record their error and are marked as errors.
+func NewTracingMiddleware(tracer trace.Tracer, next {{.Service}}) (tmw *tracingMiddleware) {
+	tmw = &tracingMiddleware{
+		tracer: tracer,
+		next:   next,
+	}
+
+	return
+}
+{{range .Methods}}
+func (mw tracingMiddleware) {{.Name}}({{.Signature}}) ({{.ResultList}}) {
+	{{.Ctx}}, span := mw.tracer.Start({{.Ctx}}, "{{.Name}}"{{if .StartAttrs}}, trace.WithAttributes(
+		{{- range .StartAttrs}}
+		{{.}},
+		{{- end}}
+	){{end}})
+	defer func() {
+		endSpan(span, err{{range .EndAttrs}}, {{.}}{{end}})
+	}()
+
+	{{.ResultNames}} = mw.next.{{.Name}}({{.Args}})
+
+	return
+}
+{{end}}
+// endSpan adds the values returned to span or, when the call failed, its
+// error, and ends it.
+func endSpan(span trace.Span, err error, returned ...attribute.KeyValue) {
+	defer span.End()
+
+	if err != nil {
+		span.RecordError(err)
+		{{- if .ErrorCode}}
+		span.SetAttributes({{.ErrorConstant}}.String(string({{.ErrorCode}}(err))))
+		{{- end}}
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	span.SetAttributes(returned...)
+}
+`
+
+const payloadTemplate = `{{range .Payloads}}
+// {{.Doc}}
+type {{.Name}} struct{{if .Fields}} {
+	{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`{{.Tag}}`" + `
+	{{- end}}
+}{{else}}{}{{end}}
+{{end}}
+{{- if .Uses.optional}}
+// optional leaves the zero value of a field out of the payload.
+func optional[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+
+	return &v
+}
+
+// required reads a field left out of the payload as the zero value.
+func required[T any](p *T) (v T) {
+	if p != nil {
+		v = *p
+	}
+
+	return v
+}
+{{end}}
+{{- if .Uses.mapPtr}}
+// mapPtr converts the value p points at with f, and nil to nil.
+func mapPtr[S, T any](p *S, f func(S) T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := f(*p)
+	return &v
+}
+{{end}}
+{{- if .Uses.mapSlice}}
+// mapSlice converts every element of s with f.
+func mapSlice[S, T any](s []S, f func(S) T) []T {
+	t := make([]T, len(s))
+	for i, v := range s {
+		t[i] = f(v)
+	}
+
+	return t
+}
+{{end}}`
+
+const endpointTemplate = `// {{.Service}} is the service the endpoints call.
+type {{.Service}} interface {
+	{{- range .Methods}}
+	{{.Name}}({{.Signature}}) ({{.ResultList}})
+	{{- end}}
+}
+{{range .Methods}}
+func Make{{.Payload}}Endpoint(svc {{$.Service}}) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		{{- if .ServerArgs}}
+		req := request.({{.Payload}}Request)
+		{{- end}}
+		{{.ResultNames}} := svc.{{.Name}}(ctx{{if .ServerArgs}}, {{.ServerArgs}}{{end}})
+		if err != nil {
+			return nil, err
+		}
+
+		return {{.ServerResponse}}, nil
+	}
+}
+{{end}}
+{{- range .Server}}
+func {{.Name}}({{.Param}} {{.From}}) {{.To}} {
+	return {{.Result}}
+}
+{{end}}
+{{- if .Uses.errorField}}
+// makeErrorField carries err in a response, nil when there is none.
+func makeErrorField(err error) *ErrorResponse {
+	if err == nil {
+		return nil
+	}
+
+	return makeErrorResponse(err)
+}
+{{end}}`
+
+const transportTemplate = `{{range .Methods}}
+func Log{{.Payload}}Endpoint(logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			logger.Log("endpoint", "{{.Payload}}Endpoint", "msg", "Calling endpoint")
+			defer logger.Log("endpoint", "{{.Payload}}Endpoint", "msg", "Called endpoint")
+
+			return next(ctx, request)
+		}
+	}
+}
+
+func decode{{.Payload}}Request(_ context.Context, r *http.Request) (interface{}, error) {
+	var request {{.Payload}}Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, errInvalidRequest
+	}
+	if err := decodeQuery(&request, r); err != nil {
+		return nil, errInvalidRequest
+	}
+
+	return request, nil
+}
+
+func Make{{.Payload}}HttpHandler(logger log.Logger, svc {{$.Service}}) *httptransport.Server {
+	tracer := otel.Tracer("Transport.Transport")
+
+	var {{lower .Payload}}Endpoint endpoint.Endpoint
+	{{lower .Payload}}Endpoint = Make{{.Payload}}Endpoint(svc)
+	{{lower .Payload}}Endpoint = Log{{.Payload}}Endpoint(log.With(logger, "service", "{{$.Service}}"))({{lower .Payload}}Endpoint)
+	{{lower .Payload}}Endpoint = TraceEndpoint(otel.Tracer("Transport.Endpoint"), "{{.Name}}")({{lower .Payload}}Endpoint)
+	{{lower .Payload}}Endpoint = traceRequest({{lower .Payload}}Endpoint)
+
+	return httptransport.NewServer(
+		{{lower .Payload}}Endpoint,
+		decode{{.Payload}}Request,
+		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(extractTrace(), startServerSpan(tracer, "{{.Route}}")),
+		httptransport.ServerFinalizer(finishServerSpan),
+		httptransport.ServerErrorHandler(traceErrors()),
+	)
+}
+{{end}}
+// queryDecoder is a request that takes query parameters as well as its body.
+type queryDecoder interface {
+	decodeQuery(query url.Values) error
+}
+
+// decodeQuery has request read the query parameters of r, if it takes any.
+func decodeQuery(request interface{}, r *http.Request) error {
+	if qd, ok := request.(queryDecoder); ok {
+		return qd.decodeQuery(r.URL.Query())
+	}
+
+	return nil
+}
+`
+
+const clientTemplate = `{{range .Methods}}
+func decode{{.Payload}}Response(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, decodeErrorResponse(r)
+	}
+
+	var response {{.Payload}}Response
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		return nil, errInvalidResponse
+	}
+	return response, nil
+}
+{{end}}
+// {{.Service}}Client implements {{.Service}} by calling the endpoint of
+// each method, such as a client of its HTTP route. Errors of the endpoints
+// are returned through proxyError.
+type {{.Service}}Client struct {
+	{{- range .Methods}}
+	{{.Name}}Endpoint endpoint.Endpoint
+	{{- end}}
+}
+{{range .Methods}}
+func (c {{$.Service}}Client) {{.Name}}({{.Signature}}) ({{.ResultList}}) {
+	{{- if .ClientReads}}
+	response, err := c.{{.Name}}Endpoint({{.Ctx}}, {{.ClientRequest}})
+	if err != nil {
+		err = proxyError(err)
+		return
+	}
+
+	resp := response.({{.Payload}}Response)
+	{{- range .ClientReads}}
+	{{.}}
+	{{- end}}
+
+	return
+	{{- else}}
+	if _, err = c.{{.Name}}Endpoint({{.Ctx}}, {{.ClientRequest}}); err != nil {
+		err = proxyError(err)
+	}
+
+	return
+	{{- end}}
+}
+{{end}}
+{{- range .Client}}
+func {{.Name}}({{.Param}} {{.From}}) {{.To}} {
+	return {{.Result}}
+}
+{{end}}
+{{- if .Uses.errorField}}
+// readErrorField reads the error carried in a response, nil when there is
+// none.
+func readErrorField(resp *ErrorResponse) error {
+	if resp == nil {
+		return nil
+	}
+
+	return proxyError(resp)
+}
+{{end}}`
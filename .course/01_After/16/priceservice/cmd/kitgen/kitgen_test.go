@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of the tests")
+
+func Test_Generate_Golden(t *testing.T) {
+	source := filepath.Join("testdata", "inventory", "inventory.go")
+
+	tests := []struct {
+		opts   Options
+		golden string
+	}{
+		{opts: Options{Kind: KIND_LOGGING}, golden: "logging.golden"},
+		{opts: Options{Kind: KIND_INSTRUMENTING}, golden: "instrumenting.golden"},
+		{opts: Options{Kind: KIND_TRACING, Prefix: "inventory."}, golden: "tracing.golden"},
+		{opts: Options{Kind: KIND_PAYLOAD, Package: "transport"}, golden: "payload.golden"},
+		{opts: Options{Kind: KIND_ENDPOINT, Package: "transport"}, golden: "endpoint.golden"},
+		{opts: Options{Kind: KIND_TRANSPORT, Package: "transport"}, golden: "transport.golden"},
+		{opts: Options{Kind: KIND_CLIENT, Package: "transport"}, golden: "client.golden"},
+	}
+
+	svc, err := parseService(source, "InventoryService")
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	for id, test := range tests {
+		test.opts.Source = "inventory.go"
+
+		src, err := Generate(svc, test.opts)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		golden := filepath.Join("testdata", test.golden)
+		if *update {
+			if err := os.WriteFile(golden, src, 0644); err != nil {
+				t.Fatalf("An Error Occured %v", err)
+			}
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		assert.True(t, bytes.Equal(expected, src), "~2|Test #%d expected %s to match the generated %s, run go test -update~", id, golden, test.opts.Kind)
+	}
+}
+
+func Test_Generate_PricingService(t *testing.T) {
+	source := filepath.Join("..", "..", "service", "service.go")
+
+	tests := []struct {
+		opts Options
+		file string
+	}{
+		{opts: Options{Kind: KIND_LOGGING}, file: "service/logging_gen.go"},
+		{opts: Options{Kind: KIND_INSTRUMENTING}, file: "service/instrument_gen.go"},
+		{opts: Options{Kind: KIND_TRACING, Prefix: "pricing.", ErrorCode: "ErrorCode"}, file: "service/tracing_gen.go"},
+		{opts: Options{Kind: KIND_TRACING, Prefix: "pricing.", ErrorCode: "ErrorCode"}, file: "../priceapi/service/tracing_gen.go"},
+		{opts: Options{Kind: KIND_PAYLOAD, Package: "transport"}, file: "transport/payload_gen.go"},
+		{opts: Options{Kind: KIND_PAYLOAD, Package: "transport"}, file: "../priceapi/transport/payload_gen.go"},
+		{opts: Options{Kind: KIND_ENDPOINT, Package: "transport"}, file: "transport/endpoint_gen.go"},
+		{opts: Options{Kind: KIND_ENDPOINT, Package: "transport"}, file: "../priceapi/transport/endpoint_gen.go"},
+		{opts: Options{Kind: KIND_TRANSPORT, Package: "transport"}, file: "transport/transport_gen.go"},
+		{opts: Options{Kind: KIND_TRANSPORT, Package: "transport"}, file: "../priceapi/transport/transport_gen.go"},
+		{opts: Options{Kind: KIND_CLIENT, Package: "transport"}, file: "../priceapi/transport/client_gen.go"},
+	}
+
+	svc, err := parseService(source, "PricingService")
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	for id, test := range tests {
+		test.opts.Source = "service.go"
+
+		src, err := Generate(svc, test.opts)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		expected, err := os.ReadFile(filepath.Join("..", "..", filepath.FromSlash(test.file)))
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		assert.True(t, bytes.Equal(expected, src), "~2|Test #%d expected %s to be up to date, run go generate ./...~", id, test.file)
+	}
+}
+
+// Test_Generate_Builds compiles what is generated for the inventory service,
+// the middlewares beside the service and the payloads, endpoints, transport
+// and client around the hand-written helpers of testdata/inventory/transport.
+func Test_Generate_Builds(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not on the PATH")
+	}
+
+	// The copy stays inside the module so that it builds with its dependencies.
+	dir, err := os.MkdirTemp("testdata", "build")
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	transportDir := filepath.Join(dir, "transport")
+	if err := os.Mkdir(transportDir, 0755); err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	source, err := os.ReadFile(filepath.Join("testdata", "inventory", "inventory.go"))
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "inventory.go"), source, 0644); err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	svc, err := parseService(filepath.Join(dir, "inventory.go"), "InventoryService")
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	fixture, err := os.ReadFile(filepath.Join("testdata", "inventory", "transport", "transport.go"))
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(transportDir, "transport.go"), fixture, 0644); err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	tests := []struct {
+		opts Options
+		file string
+	}{
+		{opts: Options{Kind: KIND_LOGGING}, file: filepath.Join(dir, "logging_gen.go")},
+		{opts: Options{Kind: KIND_INSTRUMENTING}, file: filepath.Join(dir, "instrument_gen.go")},
+		{opts: Options{Kind: KIND_TRACING, Prefix: "inventory."}, file: filepath.Join(dir, "tracing_gen.go")},
+		{opts: Options{Kind: KIND_PAYLOAD, Package: "transport"}, file: filepath.Join(transportDir, "payload_gen.go")},
+		{opts: Options{Kind: KIND_ENDPOINT, Package: "transport"}, file: filepath.Join(transportDir, "endpoint_gen.go")},
+		{opts: Options{Kind: KIND_TRANSPORT, Package: "transport"}, file: filepath.Join(transportDir, "transport_gen.go")},
+		{opts: Options{Kind: KIND_CLIENT, Package: "transport"}, file: filepath.Join(transportDir, "client_gen.go")},
+	}
+
+	for _, test := range tests {
+		test.opts.Source = "inventory.go"
+
+		src, err := Generate(svc, test.opts)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		if err := os.WriteFile(test.file, src, 0644); err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+	}
+
+	out, err := exec.Command(goTool, "vet", "./"+filepath.ToSlash(dir), "./"+filepath.ToSlash(transportDir)).CombinedOutput()
+	assert.True(t, err == nil, "~2|Test #%d expected the generated code to build, not: %s~", 0, out)
+}
+
+func Test_Generate_GetWholesaleTotal(t *testing.T) {
+	svc, err := parseService(filepath.Join("..", "..", "service", "service.go"), "PricingService")
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	src, err := Generate(svc, Options{Kind: KIND_INSTRUMENTING, Source: "service.go"})
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	expected := `"method", "GetWholesaleTotal"`
+	assert.True(t, strings.Contains(string(src), expected), "~2|Test #%d expected label: %s, not found~", 0, expected)
+}
+
+func Test_ParseService_Invalid(t *testing.T) {
+	source := filepath.Join("testdata", "invalid", "invalid.go")
+
+	tests := []struct {
+		name string
+		err  string
+	}{
+		{name: "NoContext", err: "Get must take a context.Context first"},
+		{name: "NoError", err: "Get must return an error last"},
+		{name: "UnknownName", err: "Get: unknown name missing"},
+		{name: "PointerPath", err: "Get: result.Next.Name passes through a pointer, which may be nil"},
+		{name: "Embedded", err: "Embedded embeds an interface, list its methods instead"},
+		{name: "TooManyArgs", err: "Get: //kit:http takes a path and optionally a payload name, such as /retail TotalRetailPrice"},
+		{name: "Missing", err: "no interface Missing"},
+	}
+
+	for id, test := range tests {
+		_, err := parseService(source, test.name)
+
+		assert.True(t, err != nil, "~2|Test #%d expected error: %s, not nil~", id, test.err)
+		if err == nil {
+			continue
+		}
+
+		assert.True(t, strings.HasSuffix(err.Error(), test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err.Error())
+	}
+}
+
+func Test_Generate_InvalidPayloads(t *testing.T) {
+	source := filepath.Join("testdata", "invalid", "invalid.go")
+
+	tests := []struct {
+		name string
+		err  string
+	}{
+		{name: "ErrorParam", err: "Get: an error cannot be sent in a request"},
+		{name: "SamePayload", err: "Put: payload ItemRequest is declared twice, name the payload of the method with //kit:http"},
+		{name: "SameField", err: "Get: payload GetRequest has two fields named Name"},
+	}
+
+	for id, test := range tests {
+		svc, err := parseService(source, test.name)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		_, err = Generate(svc, Options{Kind: KIND_PAYLOAD, Source: "invalid.go", Package: "transport"})
+
+		assert.True(t, err != nil, "~2|Test #%d expected error: %s, not nil~", id, test.err)
+		if err == nil {
+			continue
+		}
+
+		assert.True(t, err.Error() == test.err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err.Error())
+	}
+}
+
+func Test_Generate_UnknownKind(t *testing.T) {
+	svc, err := parseService(filepath.Join("testdata", "inventory", "inventory.go"), "InventoryService")
+	if err != nil {
+		t.Fatalf("An Error Occured %v", err)
+	}
+
+	_, err = Generate(svc, Options{Kind: "metrics", Source: "inventory.go"})
+	assert.True(t, err != nil, "~2|Test #%d expected error: unknown kind, not nil~", 0)
+}
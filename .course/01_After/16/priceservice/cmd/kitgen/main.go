@@ -0,0 +1,86 @@
+// Command kitgen generates the go-kit layers around a service interface, so
+// that they follow the interface as methods are added. It is run by go
+// generate from the file that declares the interface:
+//
+//	//go:generate go run ../cmd/kitgen -type PricingService -kind logging -out logging_gen.go
+//
+// The kinds are the logging, instrumenting and tracing middlewares, which
+// go in the package of the service, and the payload (request and response
+// structs), endpoint (the endpoint of each method, with the interface it
+// calls), transport (endpoint logging, request decoders and HTTP handlers)
+// and client (response decoders and a client of the service over the
+// endpoints), which go in a transport package that has the error and trace
+// helpers of this repository. For a payload name such as TotalRetailPrice
+// the payload file declares TotalRetailPriceRequest with the parameters of
+// the method and TotalRetailPriceResponse with its results. A parameter or
+// single result of a struct type of the service package is flattened into
+// the payload, and the other structs of the package it uses travel as
+// payloads of their own. The transport package declares ErrorResponse, the
+// error envelope that error fields are sent in, makeErrorResponse, which
+// wraps an error in it, and errInvalidRequest, the error of a body that
+// does not decode. The client needs errInvalidResponse, the error of a
+// response that does not decode, and proxyError, which maps the errors of
+// its endpoints. A request that takes query parameters as well reads them
+// in a decodeQuery(url.Values) error method.
+//
+// Every method takes a context.Context first and returns an error last.
+// Directives on a method choose what its middlewares record:
+//
+//	//kit:log code qty:quantity price.Total
+//	//kit:trace code qty price.Total
+//	//kit:http /retail TotalRetailPrice
+//
+// Values are parameters, len(parameter) or fields of a parameter or result,
+// each logged or traced under the key after the colon, or else under its
+// own name. Without //kit:log every parameter is logged, and without
+// //kit:trace every parameter of a basic type is traced. //kit:http sets
+// the route, which is otherwise the method name in kebab case, and the
+// payload name, which is otherwise the method name.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var (
+		typeName  = flag.String("type", "", "Name of the service interface")
+		source    = flag.String("source", os.Getenv("GOFILE"), "Go file that declares the interface, the file run by go generate by default")
+		kind      = flag.String("kind", "", "What to generate: logging, instrumenting, tracing, payload, endpoint, transport or client")
+		pkg       = flag.String("package", "", "Package of the generated file, the package of the interface by default")
+		prefix    = flag.String("prefix", "", "Prefix of the span attribute keys of the tracing middleware, such as pricing.")
+		errorCode = flag.String("errcode", "", "Function of the service package that returns the code of an error, traced with failed calls")
+		out       = flag.String("out", "", "File to write, standard output by default")
+	)
+	flag.Parse()
+
+	if *typeName == "" || *source == "" || *kind == "" {
+		fmt.Fprintln(os.Stderr, "kitgen: -type, -source and -kind are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	svc, err := parseService(*source, *typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kitgen:", err)
+		os.Exit(1)
+	}
+
+	src, err := Generate(svc, Options{Kind: *kind, Source: *source, Package: *pkg, Prefix: *prefix, ErrorCode: *errorCode})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kitgen:", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "kitgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	DIRECTIVE_LOG   = "//kit:log"
+	DIRECTIVE_TRACE = "//kit:trace"
+	DIRECTIVE_HTTP  = "//kit:http"
+)
+
+// Service is a service interface and the package it was declared in.
+type Service struct {
+	Name       string
+	Package    string
+	Module     string
+	ImportPath string
+	Imports    map[string]string
+	Methods    []Method
+
+	structs map[string]*ast.StructType
+}
+
+// Method is a method of a service. Its first parameter is the context and
+// its last result the error. Log and Trace are the values its //kit:log and
+// //kit:trace directives name, and Route and Payload the path and payload
+// name of its //kit:http directive.
+type Method struct {
+	Name    string
+	Params  []Var
+	Results []Var
+	Route   string
+	Payload string
+	Log     []Item
+	Trace   []Item
+}
+
+// Var is a parameter or result of a method.
+type Var struct {
+	Name     string
+	Type     ast.Expr
+	Variadic bool
+}
+
+// Item is a value logged or traced: a parameter, len of a parameter, or a
+// field of a parameter or result. Type is the Go type of the value where
+// it is known.
+type Item struct {
+	Expr   string
+	Key    string
+	Type   string
+	Result bool
+}
+
+// parseService reads the interface name from the Go file source, along with
+// the structs of its package, which the fields named by directives are
+// looked up in.
+func parseService(source string, name string) (svc *Service, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, source, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	svc = &Service{
+		Name:    name,
+		Package: file.Name.Name,
+		Imports: make(map[string]string),
+		structs: make(map[string]*ast.StructType),
+	}
+
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		importName := path.Base(importPath)
+		if spec.Name != nil {
+			importName = spec.Name.Name
+		}
+		svc.Imports[importName] = importPath
+	}
+
+	if svc.Module, svc.ImportPath, err = importPath(filepath.Dir(source)); err != nil {
+		return nil, err
+	}
+
+	if err := svc.readStructs(fset, filepath.Dir(source)); err != nil {
+		return nil, err
+	}
+
+	var iface *ast.InterfaceType
+	ast.Inspect(file, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.TypeSpec); ok && spec.Name.Name == name {
+			iface, _ = spec.Type.(*ast.InterfaceType)
+		}
+		return iface == nil
+	})
+	if iface == nil {
+		return nil, fmt.Errorf("%s: no interface %s", source, name)
+	}
+
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: %s embeds an interface, list its methods instead", source, name)
+		}
+
+		method, err := svc.parseMethod(field.Names[0].Name, fn, field.Doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		svc.Methods = append(svc.Methods, method)
+	}
+
+	return svc, nil
+}
+
+// readStructs keeps the struct types declared in the package in dir, and
+// the imports their fields may use.
+func (svc *Service) readStructs(fset *token.FileSet, dir string) error {
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range pkgs[svc.Package].Files {
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			importName := path.Base(importPath)
+			if spec.Name != nil {
+				importName = spec.Name.Name
+			}
+			if _, found := svc.Imports[importName]; !found {
+				svc.Imports[importName] = importPath
+			}
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					svc.structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+
+	return nil
+}
+
+func (svc *Service) parseMethod(name string, fn *ast.FuncType, doc *ast.CommentGroup) (method Method, err error) {
+	method = Method{Name: name, Route: "/" + kebab(name), Payload: name}
+	method.Params = vars(fn.Params, "p")
+	method.Results = vars(fn.Results, "r")
+
+	if len(method.Params) == 0 || exprString(method.Params[0].Type, "") != "context.Context" {
+		return Method{}, fmt.Errorf("%s must take a context.Context first", name)
+	}
+	if len(method.Results) == 0 || exprString(method.Results[len(method.Results)-1].Type, "") != "error" {
+		return Method{}, fmt.Errorf("%s must return an error last", name)
+	}
+	method.Results[len(method.Results)-1].Name = "err"
+
+	logged, traced := false, false
+	if doc != nil {
+		for _, comment := range doc.List {
+			directive, args := splitDirective(comment.Text)
+			switch directive {
+			case DIRECTIVE_LOG:
+				logged = true
+				if method.Log, err = svc.parseItems(method, args); err != nil {
+					return Method{}, err
+				}
+			case DIRECTIVE_TRACE:
+				traced = true
+				if method.Trace, err = svc.parseItems(method, args); err != nil {
+					return Method{}, err
+				}
+			case DIRECTIVE_HTTP:
+				if len(args) < 1 || len(args) > 2 || !strings.HasPrefix(args[0], "/") {
+					return Method{}, fmt.Errorf("%s: %s takes a path and optionally a payload name, such as /retail TotalRetailPrice", name, DIRECTIVE_HTTP)
+				}
+				method.Route = args[0]
+				if len(args) == 2 {
+					method.Payload = args[1]
+				}
+			}
+		}
+	}
+
+	// Without directives every parameter is logged, and every parameter of
+	// a basic type traced.
+	for _, param := range method.Params[1:] {
+		item := Item{Expr: param.Name, Key: param.Name, Type: exprString(param.Type, "")}
+		if param.Variadic {
+			item.Type = "[]" + item.Type
+		}
+
+		if !logged {
+			method.Log = append(method.Log, item)
+		}
+		if !traced && attributeKinds[item.Type] != "" {
+			method.Trace = append(method.Trace, item)
+		}
+	}
+
+	return method, nil
+}
+
+func splitDirective(text string) (directive string, args []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	return fields[0], fields[1:]
+}
+
+// parseItems reads the values named by a directive. Each is a parameter,
+// len(parameter) or a path of fields from a parameter or result, and may
+// be followed by :key to log or trace it under another key.
+func (svc *Service) parseItems(method Method, args []string) (items []Item, err error) {
+	for _, arg := range args {
+		expr, key := arg, ""
+		if i := strings.LastIndex(arg, ":"); i >= 0 {
+			expr, key = arg[:i], arg[i+1:]
+		}
+
+		item, err := svc.parseItem(method, expr)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			item.Key = key
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (svc *Service) parseItem(method Method, expr string) (item Item, err error) {
+	if strings.HasPrefix(expr, "len(") && strings.HasSuffix(expr, ")") {
+		name := strings.TrimSuffix(strings.TrimPrefix(expr, "len("), ")")
+		if _, _, found := method.lookup(name); !found {
+			return Item{}, fmt.Errorf("%s: unknown name %s", method.Name, name)
+		}
+
+		return Item{Expr: expr, Key: name, Type: "int"}, nil
+	}
+
+	path := strings.Split(expr, ".")
+	v, result, found := method.lookup(path[0])
+	if !found {
+		return Item{}, fmt.Errorf("%s: unknown name %s", method.Name, path[0])
+	}
+
+	item = Item{Expr: expr, Key: lowerFirst(path[len(path)-1]), Result: result}
+
+	typ := v.Type
+	for _, field := range path[1:] {
+		if _, pointer := typ.(*ast.StarExpr); pointer {
+			return Item{}, fmt.Errorf("%s: %s passes through a pointer, which may be nil", method.Name, expr)
+		}
+
+		typ = svc.fieldType(typ, field)
+		if typ == nil {
+			return item, nil
+		}
+	}
+
+	item.Type = exprString(typ, "")
+
+	return item, nil
+}
+
+// fieldType returns the type of field in typ, looking through embedded
+// structs as Go does, or nil when typ is not a struct of the package.
+func (svc *Service) fieldType(typ ast.Expr, field string) ast.Expr {
+	ident, ok := typ.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	st, found := svc.structs[ident.Name]
+	if !found {
+		return nil
+	}
+
+	for _, f := range st.Fields.List {
+		for _, name := range f.Names {
+			if name.Name == field {
+				return f.Type
+			}
+		}
+	}
+
+	for _, f := range st.Fields.List {
+		if len(f.Names) > 0 {
+			continue
+		}
+		if embedded, ok := f.Type.(*ast.Ident); ok && embedded.Name == field {
+			return f.Type
+		}
+		if promoted := svc.fieldType(f.Type, field); promoted != nil {
+			return promoted
+		}
+	}
+
+	return nil
+}
+
+func (m Method) lookup(name string) (v Var, result bool, found bool) {
+	for _, param := range m.Params {
+		if param.Name == name {
+			return param, false, true
+		}
+	}
+	for _, res := range m.Results {
+		if res.Name == name {
+			return res, true, true
+		}
+	}
+
+	return Var{}, false, false
+}
+
+// vars lists the fields of list one name at a time, naming those without a
+// name by prefix and position.
+func vars(list *ast.FieldList, prefix string) (vs []Var) {
+	if list == nil {
+		return nil
+	}
+
+	for _, field := range list.List {
+		typ, variadic := field.Type, false
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			typ, variadic = ellipsis.Elt, true
+		}
+
+		if len(field.Names) == 0 {
+			vs = append(vs, Var{Name: fmt.Sprintf("%s%d", prefix, len(vs)), Type: typ, Variadic: variadic})
+			continue
+		}
+
+		for _, name := range field.Names {
+			vs = append(vs, Var{Name: name.Name, Type: typ, Variadic: variadic})
+		}
+	}
+
+	return vs
+}
+
+var predeclared = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true, "int8": true, "int16": true,
+	"int32": true, "int64": true, "rune": true, "string": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+}
+
+// exprString prints the type typ, qualifying the types of the service
+// package with qualifier when it is not blank.
+func exprString(typ ast.Expr, qualifier string) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		if qualifier != "" && !predeclared[t.Name] {
+			return qualifier + "." + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		return exprString(t.X, "") + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(t.X, qualifier)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + exprString(t.Elt, qualifier)
+		}
+		return "[" + exprString(t.Len, "") + "]" + exprString(t.Elt, qualifier)
+	case *ast.MapType:
+		return "map[" + exprString(t.Key, qualifier) + "]" + exprString(t.Value, qualifier)
+	case *ast.Ellipsis:
+		return "..." + exprString(t.Elt, qualifier)
+	case *ast.BasicLit:
+		return t.Value
+	case *ast.InterfaceType:
+		if t.Methods == nil || len(t.Methods.List) == 0 {
+			return "interface{}"
+		}
+	}
+
+	panic(fmt.Sprintf("kitgen: unsupported type %T", typ))
+}
+
+// importPath works out the module the package in dir is in and its import
+// path.
+func importPath(dir string) (module string, importPath string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for rel := ""; ; {
+		if module, found := modulePath(filepath.Join(dir, "go.mod")); found {
+			return module, path.Join(module, filepath.ToSlash(rel)), nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("%s is not in a module", dir)
+		}
+		rel = filepath.Join(filepath.Base(dir), rel)
+		dir = parent
+	}
+}
+
+func modulePath(gomod string) (module string, found bool) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "module" {
+			return fields[1], true
+		}
+	}
+
+	return "", false
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// kebab turns GetRetailTotal into get-retail-total.
+func kebab(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
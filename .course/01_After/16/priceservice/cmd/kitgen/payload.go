@@ -0,0 +1,552 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// The payloads of a method are derived from its signature. The request
+// carries the parameters after the context and the response the results
+// before the error, each in a field named after it. A parameter or single
+// result of a struct type of the service package is flattened instead: its
+// fields, and those of the structs it embeds, become fields of the payload.
+// Fields of other struct types of the package travel as payload structs of
+// their own, named after the struct: a Tier is a TierRequest in a request
+// and a TierResponse in a response.
+
+const (
+	SUFFIX_REQUEST  = "Request"
+	SUFFIX_RESPONSE = "Response"
+)
+
+// Payload is a request or response struct of the transport.
+type Payload struct {
+	Name   string
+	Doc    string
+	Fields []PayloadField
+}
+
+// PayloadField is a field of a payload, with its type and tag as declared.
+type PayloadField struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+// Conversion is a function between a struct of the service and its payload
+// struct, such as makeTierResponse. Result is the literal it returns.
+type Conversion struct {
+	Name   string
+	Param  string
+	From   string
+	To     string
+	Result string
+}
+
+// How a value of the service is carried in a payload field.
+const (
+	carryPlain   = iota // as it is
+	carryTime           // time.Time, as a *time.Time that is nil when zero
+	carryError          // error, as an *ErrorResponse that is nil when nil
+	carryStruct         // struct, as a pointer to its payload that is nil when zero
+	carryValue          // struct whose payload cannot be compared, as its payload
+	carryPointer        // pointer to a struct, as a pointer to its payload
+	carrySlice          // slice of structs, as a slice of their payloads
+)
+
+type carried struct {
+	Kind   int
+	Type   string
+	Struct string
+}
+
+// field is a field of a flattened struct. Path selects it from the struct,
+// through the structs it is embedded in.
+type field struct {
+	Path string
+	Name string
+	Type ast.Expr
+}
+
+// flatten lists the exported fields of the struct name with the fields of
+// the structs it embeds in their place, as Go promotes them.
+func (svc *Service) flatten(name string, prefix string) (fields []field, err error) {
+	for _, f := range svc.structs[name].Fields.List {
+		if len(f.Names) == 0 {
+			embedded, ok := f.Type.(*ast.Ident)
+			if !ok || svc.structs[embedded.Name] == nil {
+				return nil, fmt.Errorf("%s embeds %s, only structs of package %s can be flattened into a payload", name, exprString(f.Type, ""), svc.Package)
+			}
+			if !embedded.IsExported() {
+				continue
+			}
+
+			promoted, err := svc.flatten(embedded.Name, prefix+embedded.Name+".")
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, promoted...)
+			continue
+		}
+
+		for _, n := range f.Names {
+			if n.IsExported() {
+				fields = append(fields, field{Path: prefix + n.Name, Name: n.Name, Type: f.Type})
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// payloads derives the payloads of svc and the conversions between them and
+// the service, which the payload, endpoint and client files are made of.
+type payloads struct {
+	svc       *Service
+	qualifier string
+
+	Payloads []Payload
+	Server   []Conversion
+	Client   []Conversion
+	Uses     map[string]bool
+
+	declared map[string]bool
+	queued   map[string]bool
+	queue    [][2]string
+}
+
+func derivePayloads(svc *Service, qualifier string, methods []methodView) (p *payloads, err error) {
+	p = &payloads{
+		svc:       svc,
+		qualifier: qualifier,
+		Uses:      make(map[string]bool),
+		declared:  map[string]bool{"ErrorResponse": true},
+		queued:    make(map[string]bool),
+	}
+
+	for i := range methods {
+		if err := p.deriveMethod(&methods[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", methods[i].Name, err)
+		}
+	}
+
+	for len(p.queue) > 0 {
+		name, suffix := p.queue[0][0], p.queue[0][1]
+		p.queue = p.queue[1:]
+
+		if err := p.deriveStruct(name, suffix); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// deriveMethod declares the request and response of m and sets the code of
+// its endpoint and client method.
+func (p *payloads) deriveMethod(m *methodView) (err error) {
+	request := Payload{Name: m.Payload + SUFFIX_REQUEST, Doc: fmt.Sprintf("carries the parameters of %s.", m.Name)}
+	response := Payload{Name: m.Payload + SUFFIX_RESPONSE, Doc: fmt.Sprintf("carries the results of %s.", m.Name)}
+
+	var args, sent []string
+	for _, param := range m.Params[1:] {
+		if name, flat := p.flattened(param); flat {
+			fields, err := p.flatFields(name, SUFFIX_REQUEST)
+			if err != nil {
+				return err
+			}
+			request.Fields = append(request.Fields, payloadFields(fields)...)
+
+			literal, err := p.serviceLiteral(name, "req", SUFFIX_REQUEST)
+			if err != nil {
+				return err
+			}
+			args = append(args, literal)
+			sent = append(sent, fieldValues(fields, param.Name)...)
+			continue
+		}
+
+		typ := param.Type
+		if param.Variadic {
+			typ = &ast.ArrayType{Elt: typ}
+		}
+		f, err := p.carriedField(field{Name: upperFirst(param.Name), Type: typ}, SUFFIX_REQUEST)
+		if err != nil {
+			return err
+		}
+		request.Fields = append(request.Fields, f.PayloadField)
+
+		arg := fromPayload(f.carried, "req."+f.PayloadField.Name)
+		if param.Variadic {
+			arg += "..."
+		}
+		args = append(args, arg)
+		sent = append(sent, f.PayloadField.Name+": "+toPayload(f.carried, param.Name))
+	}
+
+	var returned, reads []string
+	results := m.Results[:len(m.Results)-1]
+	if len(results) == 1 {
+		if name, flat := p.flattened(results[0]); flat {
+			fields, err := p.flatFields(name, SUFFIX_RESPONSE)
+			if err != nil {
+				return err
+			}
+			response.Fields = payloadFields(fields)
+
+			literal, err := p.serviceLiteral(name, "resp", SUFFIX_RESPONSE)
+			if err != nil {
+				return err
+			}
+			returned = fieldValues(fields, results[0].Name)
+			reads = append(reads, results[0].Name+" = "+literal)
+			results = nil
+		}
+	}
+	for _, result := range results {
+		f, err := p.carriedField(field{Name: upperFirst(result.Name), Type: result.Type}, SUFFIX_RESPONSE)
+		if err != nil {
+			return err
+		}
+		response.Fields = append(response.Fields, f.PayloadField)
+
+		returned = append(returned, f.PayloadField.Name+": "+toPayload(f.carried, result.Name))
+		reads = append(reads, result.Name+" = "+fromPayload(f.carried, "resp."+f.PayloadField.Name))
+	}
+
+	for _, payload := range []Payload{request, response} {
+		if err := p.declare(payload); err != nil {
+			return err
+		}
+	}
+
+	m.ServerArgs = strings.Join(args, ", ")
+	m.ServerResponse = literalOf(response.Name, returned)
+	m.ClientRequest = literalOf(request.Name, sent)
+	m.ClientReads = reads
+
+	return nil
+}
+
+// deriveStruct declares the payload struct of the service struct name, and
+// the conversions between the two.
+func (p *payloads) deriveStruct(name string, suffix string) (err error) {
+	fields, err := p.flatFields(name, suffix)
+	if err != nil {
+		return err
+	}
+
+	payload := Payload{Name: name + suffix, Doc: fmt.Sprintf("carries %s values.", p.serviceType(name)), Fields: payloadFields(fields)}
+	if err := p.declare(payload); err != nil {
+		return err
+	}
+
+	param := lowerFirst(name)
+	if token.IsKeyword(param) {
+		param = "v"
+	}
+
+	literal, err := p.serviceLiteral(name, param, suffix)
+	if err != nil {
+		return err
+	}
+
+	maker := Conversion{
+		Name:   "make" + payload.Name,
+		Param:  param,
+		From:   p.serviceType(name),
+		To:     payload.Name,
+		Result: literalOf(payload.Name, fieldValues(fields, param)),
+	}
+	reader := Conversion{
+		Name:   "read" + payload.Name,
+		Param:  param,
+		From:   payload.Name,
+		To:     p.serviceType(name),
+		Result: literal,
+	}
+
+	// The service makes responses and reads requests; the client does the
+	// opposite.
+	if suffix == SUFFIX_RESPONSE {
+		p.Server = append(p.Server, maker)
+		p.Client = append(p.Client, reader)
+	} else {
+		p.Server = append(p.Server, reader)
+		p.Client = append(p.Client, maker)
+	}
+
+	return nil
+}
+
+func (p *payloads) declare(payload Payload) error {
+	if p.declared[payload.Name] {
+		return fmt.Errorf("payload %s is declared twice, name the payload of the method with %s", payload.Name, DIRECTIVE_HTTP)
+	}
+	p.declared[payload.Name] = true
+
+	seen := make(map[string]bool)
+	for _, f := range payload.Fields {
+		if seen[f.Name] {
+			return fmt.Errorf("payload %s has two fields named %s", payload.Name, f.Name)
+		}
+		seen[f.Name] = true
+	}
+
+	payload.Doc = payload.Name + " " + payload.Doc
+	p.Payloads = append(p.Payloads, payload)
+
+	return nil
+}
+
+// flattened returns the struct v is flattened from, if it is a struct of
+// the service package passed by value.
+func (p *payloads) flattened(v Var) (name string, flat bool) {
+	ident, ok := v.Type.(*ast.Ident)
+	if !ok || v.Variadic || p.svc.structs[ident.Name] == nil {
+		return "", false
+	}
+
+	return ident.Name, true
+}
+
+// payloadField is a field of a payload and how its value is carried.
+type payloadField struct {
+	PayloadField
+	carried
+	Path string
+}
+
+// flatFields are the fields of the payload of the struct name.
+func (p *payloads) flatFields(name string, suffix string) (fields []payloadField, err error) {
+	flat, err := p.svc.flatten(name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range flat {
+		pf, err := p.carriedField(f, suffix)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, f.Path, err)
+		}
+		fields = append(fields, pf)
+	}
+
+	return fields, nil
+}
+
+// carriedField declares the payload field of f, queueing the payload struct
+// it is carried in, if any. Errors are carried in a field named Error.
+// Pointers, strings and bools are left out of the JSON when empty; other
+// values are always sent.
+func (p *payloads) carriedField(f field, suffix string) (pf payloadField, err error) {
+	c, err := p.carry(f.Type, suffix)
+	if err != nil {
+		return payloadField{}, err
+	}
+
+	name := f.Name
+	switch c.Kind {
+	case carryError:
+		if suffix == SUFFIX_REQUEST {
+			return payloadField{}, fmt.Errorf("an error cannot be sent in a request")
+		}
+		name = "Error"
+		p.Uses["errorField"] = true
+	case carryTime, carryStruct:
+		p.Uses["optional"] = true
+	case carryPointer:
+		p.Uses["mapPtr"] = true
+	case carrySlice:
+		p.Uses["mapSlice"] = true
+	}
+	if c.Struct != "" && !p.queued[c.Struct+suffix] {
+		p.queued[c.Struct+suffix] = true
+		p.queue = append(p.queue, [2]string{c.Struct, suffix})
+	}
+
+	tag := lowerFirst(name)
+	if strings.HasPrefix(c.Type, "*") || c.Type == "string" || c.Type == "bool" {
+		tag += ",omitempty"
+	}
+
+	return payloadField{
+		PayloadField: PayloadField{Name: name, Type: c.Type, Tag: fmt.Sprintf("json:%q", tag)},
+		carried:      c,
+		Path:         f.Path,
+	}, nil
+}
+
+// carry works out how a value of typ is carried in a payload with suffix.
+func (p *payloads) carry(typ ast.Expr, suffix string) (c carried, err error) {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		if t.Name == "error" {
+			return carried{Kind: carryError, Type: "*ErrorResponse"}, nil
+		}
+		if p.svc.structs[t.Name] != nil {
+			if p.comparable(t.Name, suffix) {
+				return carried{Kind: carryStruct, Type: "*" + t.Name + suffix, Struct: t.Name}, nil
+			}
+			return carried{Kind: carryValue, Type: t.Name + suffix, Struct: t.Name}, nil
+		}
+	case *ast.SelectorExpr:
+		if exprString(t, "") == "time.Time" {
+			return carried{Kind: carryTime, Type: "*time.Time"}, nil
+		}
+	case *ast.StarExpr:
+		if ident, ok := t.X.(*ast.Ident); ok && p.svc.structs[ident.Name] != nil {
+			return carried{Kind: carryPointer, Type: "*" + ident.Name + suffix, Struct: ident.Name}, nil
+		}
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && p.svc.structs[ident.Name] != nil {
+			return carried{Kind: carrySlice, Type: "[]" + ident.Name + suffix, Struct: ident.Name}, nil
+		}
+		if exprString(t.Elt, "") == "error" {
+			return carried{}, fmt.Errorf("a slice of errors cannot be carried in a payload")
+		}
+	}
+
+	return carried{Kind: carryPlain, Type: exprString(typ, p.qualifier)}, nil
+}
+
+// comparable reports whether the payload struct of name can be compared,
+// as it must be to be left out when zero.
+func (p *payloads) comparable(name string, suffix string) bool {
+	fields, err := p.svc.flatten(name, "")
+	if err != nil {
+		return false
+	}
+
+	for _, f := range fields {
+		c, err := p.carry(f.Type, suffix)
+		if err != nil || c.Kind == carryValue || c.Kind == carrySlice {
+			return false
+		}
+
+		switch t := f.Type.(type) {
+		case *ast.ArrayType:
+			if t.Len == nil {
+				return false
+			}
+		case *ast.MapType, *ast.FuncType:
+			return false
+		}
+	}
+
+	return true
+}
+
+// toPayload is the expression that carries value, of the service, in a
+// payload field.
+func toPayload(c carried, value string) string {
+	switch c.Kind {
+	case carryTime:
+		return "optional(" + value + ")"
+	case carryError:
+		return "makeErrorField(" + value + ")"
+	case carryStruct:
+		return "optional(make" + c.Type[1:] + "(" + value + "))"
+	case carryValue:
+		return "make" + c.Type + "(" + value + ")"
+	case carryPointer:
+		return "mapPtr(" + value + ", make" + c.Type[1:] + ")"
+	case carrySlice:
+		return "mapSlice(" + value + ", make" + c.Type[2:] + ")"
+	}
+
+	return value
+}
+
+// fromPayload is the expression that reads value, a payload field, back
+// into the service.
+func fromPayload(c carried, value string) string {
+	switch c.Kind {
+	case carryTime:
+		return "required(" + value + ")"
+	case carryError:
+		return "readErrorField(" + value + ")"
+	case carryStruct:
+		return "read" + c.Type[1:] + "(required(" + value + "))"
+	case carryValue:
+		return "read" + c.Type + "(" + value + ")"
+	case carryPointer:
+		return "mapPtr(" + value + ", read" + c.Type[1:] + ")"
+	case carrySlice:
+		return "mapSlice(" + value + ", read" + c.Type[2:] + ")"
+	}
+
+	return value
+}
+
+// serviceLiteral is the literal of the struct name read from the payload
+// src, with the structs it embeds as literals of their own.
+func (p *payloads) serviceLiteral(name string, src string, suffix string) (string, error) {
+	var values []string
+	for _, f := range p.svc.structs[name].Fields.List {
+		if len(f.Names) == 0 {
+			embedded := f.Type.(*ast.Ident)
+			if !embedded.IsExported() {
+				continue
+			}
+
+			value, err := p.serviceLiteral(embedded.Name, src, suffix)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, embedded.Name+": "+value)
+			continue
+		}
+
+		c, err := p.carry(f.Type, suffix)
+		if err != nil {
+			return "", err
+		}
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+
+			from := n.Name
+			if c.Kind == carryError {
+				from = "Error"
+			}
+			values = append(values, n.Name+": "+fromPayload(c, src+"."+from))
+		}
+	}
+
+	return literalOf(p.serviceType(name), values), nil
+}
+
+func (p *payloads) serviceType(name string) string {
+	return exprString(ast.NewIdent(name), p.qualifier)
+}
+
+func payloadFields(fields []payloadField) (pfs []PayloadField) {
+	for _, f := range fields {
+		pfs = append(pfs, f.PayloadField)
+	}
+
+	return pfs
+}
+
+// fieldValues are the keyed values of a payload literal that carries the
+// fields of src.
+func fieldValues(fields []payloadField, src string) (values []string) {
+	for _, f := range fields {
+		values = append(values, f.PayloadField.Name+": "+toPayload(f.carried, src+"."+f.Path))
+	}
+
+	return values
+}
+
+// literalOf is a composite literal of typ with a value on each line.
+func literalOf(typ string, values []string) string {
+	if len(values) == 0 {
+		return typ + "{}"
+	}
+
+	return typ + "{\n" + strings.Join(values, ",\n") + ",\n}"
+}
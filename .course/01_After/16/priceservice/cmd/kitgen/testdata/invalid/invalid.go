@@ -0,0 +1,54 @@
+package invalid
+
+import "context"
+
+type Result struct {
+	Next *Result
+	Name string
+}
+
+type NoContext interface {
+	Get(code string) (err error)
+}
+
+type NoError interface {
+	Get(ctx context.Context, code string) (result Result)
+}
+
+type UnknownName interface {
+	//kit:log missing
+	Get(ctx context.Context, code string) (result Result, err error)
+}
+
+type PointerPath interface {
+	//kit:trace result.Next.Name
+	Get(ctx context.Context, code string) (result Result, err error)
+}
+
+type Named struct {
+	Name string
+}
+
+type ErrorParam interface {
+	Get(ctx context.Context, failure error) (err error)
+}
+
+type SamePayload interface {
+	//kit:http /get Item
+	Get(ctx context.Context, code string) (result Result, err error)
+	//kit:http /put Item
+	Put(ctx context.Context, code string) (err error)
+}
+
+type SameField interface {
+	Get(ctx context.Context, name string, named Named) (err error)
+}
+
+type Embedded interface {
+	NoContext
+}
+
+type TooManyArgs interface {
+	//kit:http /get Get Extra
+	Get(ctx context.Context, code string) (result Result, err error)
+}
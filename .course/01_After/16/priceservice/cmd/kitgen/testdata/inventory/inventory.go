@@ -0,0 +1,33 @@
+package inventory
+
+import (
+	"context"
+	"time"
+)
+
+type Stock struct {
+	Code     string
+	Location Location
+	OnHand   int
+	Reserved *Reservation
+}
+
+type Location struct {
+	Warehouse string
+	Bin       string
+}
+
+type Reservation struct {
+	Until time.Time
+}
+
+type InventoryService interface {
+	//kit:log code warehouse stock.OnHand:on_hand stock.Location.Warehouse:stocked
+	//kit:trace code stock.OnHand
+	//kit:http /stock
+	GetStock(ctx context.Context, code string, warehouse string) (stock Stock, err error)
+	Reserve(ctx context.Context, code string, qty int, until time.Time) (reservation *Reservation, err error)
+	//kit:log len(codes):codes
+	Release(ctx context.Context, reference string, codes ...string) (err error)
+	Count(ctx context.Context, warehouse string) (items int, value float64, err error)
+}
@@ -0,0 +1,95 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	kittransport "github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The hand-written half of the transport of the inventory service, with the
+// error envelope and helpers that the generated payloads, endpoints,
+// transport and client are built around.
+
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ErrorResponse) Error() string {
+	return e.Message
+}
+
+var (
+	errInvalidRequest  = &ErrorResponse{Code: "INVALID_REQUEST", Message: "Invalid Request"}
+	errInvalidResponse = &ErrorResponse{Code: "INVALID_RESPONSE", Message: "Invalid Response"}
+)
+
+// makeErrorResponse wraps err in the error envelope.
+func makeErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{Code: "INTERNAL", Message: err.Error()}
+}
+
+// proxyError is the error of a failed call to an endpoint of the client.
+func proxyError(err error) error {
+	return err
+}
+
+func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	httptransport.DefaultErrorEncoder(ctx, err, w)
+}
+
+func decodeErrorResponse(r *http.Response) error {
+	var resp ErrorResponse
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return errInvalidResponse
+	}
+
+	return &resp
+}
+
+func extractTrace() httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return ctx
+	}
+}
+
+func startServerSpan(tracer trace.Tracer, route string) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		ctx, _ = tracer.Start(ctx, route)
+		return ctx
+	}
+}
+
+func finishServerSpan(ctx context.Context, code int, r *http.Request) {
+	trace.SpanFromContext(ctx).End()
+}
+
+func traceErrors() kittransport.ErrorHandler {
+	return kittransport.ErrorHandlerFunc(func(ctx context.Context, err error) {
+		trace.SpanFromContext(ctx).RecordError(err)
+	})
+}
+
+func traceRequest(next endpoint.Endpoint) endpoint.Endpoint {
+	return next
+}
+
+func TraceEndpoint(tracer trace.Tracer, operation string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, span := tracer.Start(ctx, operation)
+			defer span.End()
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -2,22 +2,22 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
 	"github.com/britzc/go-kit_0dot12_fundamentals/current/repo"
 	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/telemetry"
 	"github.com/britzc/go-kit_0dot12_fundamentals/current/transport"
 	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 
 	"github.com/go-kit/kit/log"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -27,7 +27,20 @@ import (
 
 func main() {
 	var (
-		listen = flag.String("listen", ":8081", "HTTP listen address")
+		listen   = flag.String("listen", ":8081", "HTTP listen address")
+		repoKind = flag.String("repo", "csv", "Catalog repository, csv or sql; coupon uses are only shared between instances with sql")
+		dsn      = flag.String("dsn", "", "Postgres connection string used by the sql repository")
+		rounding = flag.String("rounding", "half-up", "Rounding mode for totals, half-up or half-even")
+		currency = flag.String("currency", "USD", "Base currency of the FX rates and of products without a currency")
+		strict   = flag.Bool("strict", false, "Refuse a catalog with invalid rows, at startup and on reload, rather than serving its valid rows")
+		reload   = flag.Duration("reload", 30*time.Second, "Interval between catalog file checks, 0 disables reloading")
+
+		environment   = flag.String("environment", telemetry.DefaultConfig.Environment, "Deployment environment the traces are tagged with")
+		traceExporter = flag.String("trace-exporter", telemetry.DefaultConfig.Exporter, "Where spans are exported to: none, file, stdout or otlp-http, which Jaeger also accepts")
+		traceFile     = flag.String("trace-file", telemetry.DefaultConfig.File, "File the file trace exporter appends to")
+		otlpEndpoint  = flag.String("otlp-endpoint", telemetry.DefaultConfig.Endpoint, "host:port of the collector the otlp-http trace exporter sends to")
+		traceSampler  = flag.String("trace-sampler", telemetry.DefaultConfig.Sampler, "Which traces are kept: always, ratio or parent-based")
+		traceRatio    = flag.Float64("trace-ratio", telemetry.DefaultConfig.Ratio, "Fraction of traces kept by the ratio and parent-based samplers")
 	)
 	flag.Parse()
 
@@ -35,25 +48,22 @@ func main() {
 
 	logger := log.NewLogfmtLogger(os.Stderr)
 
-	f, err := os.Create("traces.txt")
-	if err != nil {
-		logger.Log("error", err)
-		return
-	}
-	defer f.Close()
+	tracing := telemetry.DefaultConfig
+	tracing.ServiceName = "PriceService"
+	tracing.Environment = *environment
+	tracing.Exporter = *traceExporter
+	tracing.File = *traceFile
+	tracing.Endpoint = *otlpEndpoint
+	tracing.Sampler = *traceSampler
+	tracing.Ratio = *traceRatio
 
-	exp, err := newExporter(f)
+	tp, shutdown, err := telemetry.NewTracerProvider(context.Background(), tracing)
 	if err != nil {
 		logger.Log("error", err)
 		return
 	}
-
-	tp := trace.NewTracerProvider(
-		trace.WithBatcher(exp),
-		trace.WithResource(newResource()),
-	)
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
+		if err := shutdown(context.Background()); err != nil {
 			logger.Log("error", err)
 			return
 		}
@@ -62,9 +72,100 @@ func main() {
 
 	fmt.Println("Logging and tracing: Ready")
 
+	roundingMode, err := money.ParseRounding(*rounding)
+	if err != nil {
+		logger.Log("error", err)
+		return
+	}
+
 	fmt.Println("Repository: In progress")
 
-	productRepo, _ := repo.NewProductRepo("products.csv", "partners.csv")
+	var productRepo service.ProductRepo
+	var couponLedger repo.CouponLedger
+	healthChecks := make(map[string]transport.HealthCheck)
+	switch *repoKind {
+	case "csv":
+		csvRepo, err := repo.NewProductRepo("products.csv", "partners.csv", "pricelists.csv", *strict)
+		if err != nil {
+			logger.Log("error", err)
+
+			if csvRepo == nil {
+				return
+			}
+		}
+
+		catalogVersion := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "gokitfundamentals",
+			Subsystem: "pricing_service",
+			Name:      "catalog_version",
+			Help:      "Version of the loaded product catalog.",
+		}, []string{})
+		catalogLastReload := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "gokitfundamentals",
+			Subsystem: "pricing_service",
+			Name:      "catalog_last_reload_timestamp_seconds",
+			Help:      "Unix time the product catalog was last loaded.",
+		}, []string{})
+
+		catalogWatcher := repo.NewCatalogWatcher(log.With(logger, "component", "ProductRepo"), catalogVersion, catalogLastReload, csvRepo)
+		if *reload > 0 {
+			go catalogWatcher.Run(context.Background(), *reload)
+		}
+
+		productRepo = service.NewCatalogRepoAdapter(csvRepo)
+		couponLedger = repo.NewMemoryLedger()
+	case "sql":
+		db, err := sql.Open("postgres", *dsn)
+		if err != nil {
+			logger.Log("error", err)
+			return
+		}
+		defer db.Close()
+		healthChecks["db"] = db.PingContext
+
+		sqlRepo, err := repo.NewSqlRepo(context.Background(), db)
+		if err != nil {
+			logger.Log("error", err)
+			return
+		}
+		defer sqlRepo.Close()
+
+		productRepo = sqlRepo
+		couponLedger = sqlRepo
+	default:
+		logger.Log("error", fmt.Sprintf("unknown repo %q, expected csv or sql", *repoKind))
+		return
+	}
+
+	fxTable, err := repo.LoadFxTable("fxrates.csv", *currency)
+	if err != nil {
+		logger.Log("error", err)
+
+		var catalogErr *repo.CatalogError
+		if *strict || !errors.As(err, &catalogErr) {
+			return
+		}
+	}
+
+	taxTable, err := repo.LoadTaxTable("taxrates.csv")
+	if err != nil {
+		logger.Log("error", err)
+
+		var catalogErr *repo.CatalogError
+		if *strict || !errors.As(err, &catalogErr) {
+			return
+		}
+	}
+
+	promotionRepo, err := repo.NewPromotionRepo("promotions.csv", couponLedger)
+	if err != nil {
+		logger.Log("error", err)
+
+		var catalogErr *repo.CatalogError
+		if *strict || !errors.As(err, &catalogErr) {
+			return
+		}
+	}
 
 	fmt.Println("Repository: Ready")
 
@@ -85,7 +186,8 @@ func main() {
 	}, fieldKeys)
 
 	var svc service.PricingService
-	svc = service.NewPricingService(productRepo)
+	svc = service.NewPricingService(productRepo, promotionRepo, fxTable, taxTable, roundingMode)
+	svc = service.NewTracingMiddleware(otel.Tracer("Service.Service"), svc)
 	svc = service.NewInstrumentingMiddleware(requestCount, requestLatency, svc)
 	svc = service.NewLoggingMiddleware(logger, svc)
 
@@ -97,6 +199,12 @@ func main() {
 	totalWholesalePriceHandler := transport.MakeTotalWholesalePriceHttpHandler(logger, svc)
 	rtr.Handle("/wholesale", totalWholesalePriceHandler).Methods(http.MethodPost)
 
+	quoteHandler := transport.MakeQuoteHttpHandler(logger, svc)
+	rtr.Handle("/quote", quoteHandler).Methods(http.MethodPost)
+
+	healthHandler := transport.MakeHealthHttpHandler(logger, healthChecks)
+	rtr.Handle("/health", healthHandler).Methods(http.MethodGet)
+
 	rtr.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 
 	fmt.Println("Endpoints and handlers: Ready")
@@ -105,23 +213,3 @@ func main() {
 
 	http.ListenAndServe(*listen, rtr)
 }
-
-func newExporter(w io.Writer) (trace.SpanExporter, error) {
-	return stdouttrace.New(
-		stdouttrace.WithWriter(w),
-		stdouttrace.WithPrettyPrint(),
-	)
-}
-
-func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("PriceService"),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "pluralsight"),
-		),
-	)
-	return r
-}
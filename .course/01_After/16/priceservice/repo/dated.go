@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+)
+
+// validity is the half-open interval [from, to) in which a catalog row
+// applies. A zero from or to leaves that end of the interval open, so the
+// zero validity marks an undated row.
+type validity struct {
+	from time.Time
+	to   time.Time
+}
+
+func (v validity) dated() bool {
+	return !v.from.IsZero() || !v.to.IsZero()
+}
+
+func (v validity) contains(t time.Time) bool {
+	return (v.from.IsZero() || !t.Before(v.from)) && (v.to.IsZero() || t.Before(v.to))
+}
+
+func (v validity) overlaps(o validity) bool {
+	return (v.to.IsZero() || o.from.IsZero() || o.from.Before(v.to)) &&
+		(o.to.IsZero() || v.from.IsZero() || v.from.Before(o.to))
+}
+
+// productVersion is one row of a product. A code may have a single undated
+// row and any number of dated rows whose validities do not overlap.
+type productVersion struct {
+	validity
+	product service.Product
+}
+
+type partnerVersion struct {
+	validity
+	priceList service.PriceList
+}
+
+// termVersion is one row of a partner's terms for a product, either a
+// discount override or a net price.
+type termVersion struct {
+	validity
+	discount money.Rate
+	netPrice money.Amount
+	net      bool
+}
+
+// versionRef records where a row was defined, for reporting conflicts.
+type versionRef struct {
+	validity
+	line int
+}
+
+// conflicting returns the line of a row that v cannot be added next to:
+// another undated row, or a dated row whose validity overlaps v.
+func conflicting(refs []versionRef, v validity) (line int, found bool) {
+	for _, ref := range refs {
+		if ref.dated() != v.dated() {
+			continue
+		}
+		if !v.dated() || ref.overlaps(v) {
+			return ref.line, true
+		}
+	}
+
+	return 0, false
+}
+
+// resolveProduct picks the dated row in effect at asOf, falling back to the
+// undated row. A code that only has dated rows is missing in their gaps.
+func resolveProduct(versions []productVersion, asOf time.Time) (product service.Product, found bool) {
+	for _, v := range versions {
+		if v.dated() && v.contains(asOf) {
+			return v.product, true
+		}
+	}
+	for _, v := range versions {
+		if !v.dated() {
+			return v.product, true
+		}
+	}
+
+	return service.Product{}, false
+}
+
+// resolvePartner picks a partner's price list at asOf in the same way as
+// resolveProduct, with the per-product terms in effect at asOf. The terms
+// are dated apart from the partner row, as in the SQL repo, so a dated
+// discount leaves the undated terms in effect.
+func resolvePartner(versions []partnerVersion, terms map[string][]termVersion, asOf time.Time) (priceList service.PriceList, found bool) {
+	for _, v := range versions {
+		if v.dated() && v.contains(asOf) {
+			return withTerms(v.priceList, terms, asOf), true
+		}
+	}
+	for _, v := range versions {
+		if !v.dated() {
+			return withTerms(v.priceList, terms, asOf), true
+		}
+	}
+
+	return service.PriceList{}, false
+}
+
+// withTerms fills in the discount overrides and net prices of priceList
+// from the terms of each product in effect at asOf.
+func withTerms(priceList service.PriceList, terms map[string][]termVersion, asOf time.Time) service.PriceList {
+	priceList.Discounts = make(map[string]money.Rate, 0)
+	priceList.NetPrices = make(map[string]money.Amount, 0)
+
+	for code, versions := range terms {
+		term, found := resolveTerm(versions, asOf)
+		if !found {
+			continue
+		}
+
+		if term.net {
+			priceList.NetPrices[code] = term.netPrice
+		} else {
+			priceList.Discounts[code] = term.discount
+		}
+	}
+
+	return priceList
+}
+
+// resolveTerm picks the terms for a product at asOf in the same way as
+// resolveProduct. A product that only has dated terms has none in their
+// gaps, and is priced at the partner discount.
+func resolveTerm(versions []termVersion, asOf time.Time) (term termVersion, found bool) {
+	for _, v := range versions {
+		if v.dated() && v.contains(asOf) {
+			return v, true
+		}
+	}
+	for _, v := range versions {
+		if !v.dated() {
+			return v, true
+		}
+	}
+
+	return termVersion{}, false
+}
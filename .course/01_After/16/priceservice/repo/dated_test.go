@@ -0,0 +1,181 @@
+package repo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FetchProduct_AsOf(t *testing.T) {
+	products := "aaa111,12.99\n" +
+		"aaa111,11.99,from=2025-01-01,to=2025-02-01\n" +
+		"aaa111,10.99,from=2025-03-01\n" +
+		"bbb222,2.90,from=2025-01-01,to=2025-02-01\n" +
+		"bbb222,3.10,from=2025-02-01T12:00:00Z\n"
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), products, "superstore,0.15\n", "")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	tests := []struct {
+		code  string
+		asOf  string
+		found bool
+		price money.Amount
+	}{
+		{
+			code:  "aaa111",
+			asOf:  "2024-12-31T23:59:59Z",
+			found: true,
+			price: money.MustParse("12.99"),
+		},
+		{
+			code:  "aaa111",
+			asOf:  "2025-01-01T00:00:00Z",
+			found: true,
+			price: money.MustParse("11.99"),
+		},
+		{
+			code:  "aaa111",
+			asOf:  "2025-02-01T00:00:00Z",
+			found: true,
+			price: money.MustParse("12.99"),
+		},
+		{
+			code:  "aaa111",
+			asOf:  "2025-06-01T00:00:00Z",
+			found: true,
+			price: money.MustParse("10.99"),
+		},
+		{
+			code:  "bbb222",
+			asOf:  "2024-12-31T23:59:59Z",
+			found: false,
+			price: 0,
+		},
+		{
+			code:  "bbb222",
+			asOf:  "2025-01-31T23:59:59Z",
+			found: true,
+			price: money.MustParse("2.90"),
+		},
+		{
+			code:  "bbb222",
+			asOf:  "2025-02-01T06:00:00Z",
+			found: false,
+			price: 0,
+		},
+		{
+			code:  "bbb222",
+			asOf:  "2025-02-01T12:00:00Z",
+			found: true,
+			price: money.MustParse("3.10"),
+		},
+	}
+
+	for id, test := range tests {
+		asOf, _ := time.Parse(time.RFC3339, test.asOf)
+
+		product, found := pr.FetchProduct(test.code, asOf)
+		assert.True(t, test.found == found, "~2|Test #%d expected found: %t, not found %t~", id, test.found, found)
+		if found {
+			assert.True(t, test.price == product.Tiers[0].Price, "~2|Test #%d expected price: %s, not price %s~", id, test.price, product.Tiers[0].Price)
+		}
+	}
+}
+
+func Test_FetchPriceList_AsOf(t *testing.T) {
+	partners := "superstore,0.15\n" +
+		"superstore,0.20,from=2025-01-01,to=2025-02-01\n" +
+		"joesbakery,0.10,from=2025-01-01\n"
+	priceLists := "superstore,aaa111,,10.00\n"
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "aaa111,12.99\n", partners, priceLists)
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	tests := []struct {
+		partner  string
+		asOf     string
+		found    bool
+		discount money.Rate
+	}{
+		{
+			partner:  "superstore",
+			asOf:     "2024-12-31T00:00:00Z",
+			found:    true,
+			discount: money.MustParseRate("0.15"),
+		},
+		{
+			partner:  "superstore",
+			asOf:     "2025-01-15T00:00:00Z",
+			found:    true,
+			discount: money.MustParseRate("0.20"),
+		},
+		{
+			partner:  "joesbakery",
+			asOf:     "2024-12-31T00:00:00Z",
+			found:    false,
+			discount: 0,
+		},
+		{
+			partner:  "joesbakery",
+			asOf:     "2025-01-15T00:00:00Z",
+			found:    true,
+			discount: money.MustParseRate("0.10"),
+		},
+	}
+
+	for id, test := range tests {
+		asOf, _ := time.Parse(time.RFC3339, test.asOf)
+
+		priceList, found := pr.FetchPriceList(test.partner, asOf)
+		assert.True(t, test.found == found, "~2|Test #%d expected found: %t, not found %t~", id, test.found, found)
+		assert.True(t, test.discount == priceList.Discount, "~2|Test #%d expected discount: %s, not discount %s~", id, test.discount, priceList.Discount)
+
+		if test.partner == "superstore" {
+			_, net := priceList.NetPrice("aaa111")
+			assert.True(t, net, "~2|Test #%d expected the aaa111 net price to apply~", id)
+		}
+	}
+}
+
+func Test_FetchPriceList_AsOfTerms(t *testing.T) {
+	products := "aaa111,12.99,10:11.50\n" +
+		"aaa111,12.99,10:10.99,50:9.99,from=2025-01-01,to=2025-02-01\n"
+	priceLists := "superstore,aaa111,0.25,\n" +
+		"superstore,aaa111,,9.50,from=2025-01-01,to=2025-02-01\n"
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), products, "superstore,0.15\n", priceLists)
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	tests := []struct {
+		asOf     string
+		tiers    string
+		discount money.Rate
+		netPrice money.Amount
+	}{
+		{asOf: "2024-12-31T00:00:00Z", tiers: "[{1 9 12.99} {10 0 11.50}]", discount: money.MustParseRate("0.25")},
+		{asOf: "2025-01-15T00:00:00Z", tiers: "[{1 9 12.99} {10 49 10.99} {50 0 9.99}]", discount: money.MustParseRate("0.15"), netPrice: money.MustParse("9.50")},
+		{asOf: "2025-02-01T00:00:00Z", tiers: "[{1 9 12.99} {10 0 11.50}]", discount: money.MustParseRate("0.25")},
+	}
+
+	for id, test := range tests {
+		asOf, _ := time.Parse(time.RFC3339, test.asOf)
+
+		product, found := pr.FetchProduct("aaa111", asOf)
+		assert.True(t, found, "~2|Test #%d expected product aaa111 to be found~", id)
+		assert.True(t, test.tiers == fmt.Sprint(product.Tiers), "~2|Test #%d expected tiers: %s, not tiers %v~", id, test.tiers, product.Tiers)
+
+		priceList, found := pr.FetchPriceList("superstore", asOf)
+		assert.True(t, found, "~2|Test #%d expected partner superstore to be found~", id)
+
+		netPrice, _ := priceList.NetPrice("aaa111")
+		assert.True(t, test.netPrice == netPrice, "~2|Test #%d expected net price: %s, not net price %s~", id, test.netPrice, netPrice)
+		assert.True(t, test.discount == priceList.DiscountFor("aaa111"), "~2|Test #%d expected discount: %s, not discount %s~", id, test.discount, priceList.DiscountFor("aaa111"))
+	}
+}
@@ -0,0 +1,327 @@
+package repo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeDB is an in-process database/sql driver that understands just the
+// statements issued by Migrate and sqlRepo.
+type fakeDB struct {
+	mu       sync.Mutex
+	version  int
+	tables   []string
+	prepared int
+	products map[string]string
+	currency map[string]string
+	taxClass map[string]string
+	tiers    map[string][][]driver.Value
+	partners map[string]string
+	terms    map[string][][]driver.Value
+	exempt   map[string]bool
+
+	prices    map[string][]fakeDated
+	discounts map[string][]fakeDated
+
+	datedTiers map[string][]fakeDatedRow
+	datedTerms map[string][]fakeDatedRow
+
+	uses        map[string]int64
+	redemptions map[string]bool
+
+	err error
+}
+
+// fakeDated is a row of product_prices or partner_discounts.
+type fakeDated struct {
+	from  time.Time
+	to    time.Time
+	value string
+}
+
+// resolveDated mirrors selectPrice and selectDiscount: the latest dated row
+// in effect at asOf wins, otherwise the undated value is used if there is one.
+func resolveDated(dated []fakeDated, undated map[string]string, key string, asOf time.Time) (value string, found bool) {
+	var latest *fakeDated
+	for i, d := range dated {
+		if d.from.After(asOf) || (!d.to.IsZero() && !d.to.After(asOf)) {
+			continue
+		}
+		if latest == nil || d.from.After(latest.from) {
+			latest = &dated[i]
+		}
+	}
+	if latest != nil {
+		return latest.value, true
+	}
+
+	value, found = undated[key]
+
+	return value, found
+}
+
+// fakeDatedRow is a dated row of product_tiers or partner_prices, keyed by
+// its first column.
+type fakeDatedRow struct {
+	from   time.Time
+	to     time.Time
+	values []driver.Value
+}
+
+// resolveRows mirrors selectTiers and selectTerms: for each key the latest
+// row in effect at asOf wins, undated rows being in effect from the start.
+// The rows are returned in key order.
+func resolveRows(undated [][]driver.Value, dated []fakeDatedRow, asOf time.Time) (rows [][]driver.Value) {
+	latest := make(map[string]fakeDatedRow)
+	for _, values := range undated {
+		latest[fmt.Sprint(values[0])] = fakeDatedRow{values: values}
+	}
+	for _, d := range dated {
+		if d.from.After(asOf) || (!d.to.IsZero() && !d.to.After(asOf)) {
+			continue
+		}
+
+		key := fmt.Sprint(d.values[0])
+		if current, found := latest[key]; !found || d.from.After(current.from) {
+			latest[key] = d
+		}
+	}
+
+	for _, row := range latest {
+		rows = append(rows, row.values)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if a, ok := rows[i][0].(int64); ok {
+			return a < rows[j][0].(int64)
+		}
+
+		return fmt.Sprint(rows[i][0]) < fmt.Sprint(rows[j][0])
+	})
+
+	return rows
+}
+
+func oneLine(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+func openFakeDB(name string, fdb *fakeDB) (*sql.DB, error) {
+	fakeDBsMu.Lock()
+	fakeDBs[name] = fdb
+	fakeDBsMu.Unlock()
+
+	return sql.Open("fakedb", name)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+
+	fdb, ok := fakeDBs[name]
+	if !ok {
+		return nil, errors.New("fakedb: unknown database " + name)
+	}
+
+	return &fakeConn{db: fdb}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.prepared++
+
+	return &fakeStmt{db: c.db, query: oneLine(query)}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+// Begin snapshots the coupon ledger, which is all that Rollback restores.
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	tx := &fakeTx{db: c.db, uses: map[string]int64{}, redemptions: map[string]bool{}}
+	for coupon, uses := range c.db.uses {
+		tx.uses[coupon] = uses
+	}
+	for key := range c.db.redemptions {
+		tx.redemptions[key] = true
+	}
+
+	return tx, nil
+}
+
+type fakeTx struct {
+	db          *fakeDB
+	uses        map[string]int64
+	redemptions map[string]bool
+}
+
+func (tx *fakeTx) Commit() error {
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	tx.db.uses, tx.db.redemptions = tx.uses, tx.redemptions
+
+	return nil
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.err != nil {
+		return nil, s.db.err
+	}
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS "):
+		name := strings.Fields(s.query)[5]
+		s.db.tables = append(s.db.tables, name)
+	case strings.HasPrefix(s.query, "ALTER TABLE "):
+	case s.query == insertSchemaVersion:
+		s.db.version = int(args[0].(int64))
+	case s.query == oneLine(insertRedemption):
+		key := args[0].(string) + "/" + args[1].(string)
+		if s.db.redemptions[key] {
+			return driver.RowsAffected(0), nil
+		}
+		if s.db.redemptions == nil {
+			s.db.redemptions = make(map[string]bool)
+		}
+		s.db.redemptions[key] = true
+	case s.query == oneLine(countUse):
+		coupon, limit := args[0].(string), args[1].(int64)
+		if limit > 0 && s.db.uses[coupon] >= limit {
+			return driver.RowsAffected(0), nil
+		}
+		if s.db.uses == nil {
+			s.db.uses = make(map[string]int64)
+		}
+		s.db.uses[coupon]++
+	default:
+		return nil, errors.New("fakedb: unsupported exec " + s.query)
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.err != nil {
+		return nil, s.db.err
+	}
+
+	rows := &fakeRows{columns: []string{"value"}}
+	switch s.query {
+	case selectSchemaVersion:
+		rows.values = append(rows.values, []driver.Value{int64(s.db.version)})
+	case oneLine(selectPrice):
+		code, asOf := args[0].(string), args[1].(time.Time)
+		if price, ok := resolveDated(s.db.prices[code], s.db.products, code, asOf); ok {
+			rows.values = append(rows.values, []driver.Value{price})
+		}
+	case selectAttributes:
+		rows.columns = []string{"currency", "tax_class"}
+		var currency, taxClass driver.Value
+		if value, ok := s.db.currency[args[0].(string)]; ok {
+			currency = value
+		}
+		if value, ok := s.db.taxClass[args[0].(string)]; ok {
+			taxClass = value
+		}
+		rows.values = append(rows.values, []driver.Value{currency, taxClass})
+	case oneLine(selectTiers):
+		code, asOf := args[0].(string), args[1].(time.Time)
+		rows.columns = []string{"min_qty", "price"}
+		rows.values = resolveRows(s.db.tiers[code], s.db.datedTiers[code], asOf)
+	case oneLine(selectTerms):
+		partner, asOf := args[0].(string), args[1].(time.Time)
+		rows.columns = []string{"code", "discount", "net_price"}
+		rows.values = resolveRows(s.db.terms[partner], s.db.datedTerms[partner], asOf)
+	case selectExempt:
+		rows.values = append(rows.values, []driver.Value{s.db.exempt[args[0].(string)]})
+	case selectUses:
+		if uses, ok := s.db.uses[args[0].(string)]; ok {
+			rows.values = append(rows.values, []driver.Value{uses})
+		}
+	case oneLine(selectDiscount):
+		partner, asOf := args[0].(string), args[1].(time.Time)
+		if discount, ok := resolveDated(s.db.discounts[partner], s.db.partners, partner, asOf); ok {
+			rows.values = append(rows.values, []driver.Value{discount})
+		}
+	default:
+		return nil, errors.New("fakedb: unsupported query " + s.query)
+	}
+
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.next])
+	r.next++
+
+	return nil
+}
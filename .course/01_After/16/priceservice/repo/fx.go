@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"fmt"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+)
+
+// LoadFxTable reads the exchange rates against base from the CSV file at
+// path. As with NewProductRepo, rows that fail validation are left out and
+// reported together as a *CatalogError alongside a usable table. A file
+// without a valid rate for base is an error on its own. Currencies with more
+// than money.MinorUnits decimal places, such as KWD, are not supported.
+func LoadFxTable(path string, base string) (fx service.FxTable, err error) {
+	records, err := readCSV(path)
+	if err != nil {
+		return service.FxTable{}, err
+	}
+
+	rates, problems := parseFxRates(path, records, base)
+	if _, found := rates[base]; !found {
+		return service.FxTable{}, fmt.Errorf("%s: no rate for base currency %q", path, base)
+	}
+
+	fx = service.FxTable{
+		Base:  base,
+		Rates: rates,
+	}
+
+	if len(problems) > 0 {
+		return fx, &CatalogError{Problems: problems}
+	}
+
+	return fx, nil
+}
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadFxTable(t *testing.T) {
+	tests := []struct {
+		rates    string
+		err      bool
+		problems []string
+		loaded   []string
+	}{
+		{
+			rates:  "currency,minorUnits,rate\nUSD,2,1\nEUR,2,0.92\nJPY,0,151.30\n",
+			loaded: []string{"EUR", "JPY", "USD"},
+		},
+		{
+			rates: "USD,2,1\n" +
+				"EUR,2\n" +
+				"eur,2,0.92\n" +
+				"GBP,x,0.79\n" +
+				"KWD,3,0.31\n" +
+				"CHF,2,abc\n" +
+				"SEK,2,0\n" +
+				"USD,2,1\n" +
+				"JPY,0,151.30\n",
+			err: true,
+			problems: []string{
+				"fxrates.csv:2: expected 3 fields (currency,minorUnits,rate), found 2",
+				"fxrates.csv:3: invalid currency \"eur\"",
+				"fxrates.csv:4: invalid minor units \"x\" for currency \"GBP\"",
+				"fxrates.csv:5: minor units 3 for currency \"KWD\" is outside [0,2], the decimal places amounts are held to",
+				"fxrates.csv:6: invalid rate \"abc\" for currency \"CHF\"",
+				"fxrates.csv:7: rate 0 for currency \"SEK\" must be positive",
+				"fxrates.csv:8: duplicate currency \"USD\", first defined on line 1",
+			},
+			loaded: []string{"JPY", "USD"},
+		},
+		{
+			rates: "EUR,2,0.92\n",
+			err:   true,
+		},
+		{
+			rates:    "USD,2,1.10\nEUR,2,0.92\n",
+			err:      true,
+			problems: nil,
+		},
+	}
+
+	for id, test := range tests {
+		path := filepath.Join(t.TempDir(), "fxrates.csv")
+		if err := os.WriteFile(path, []byte(test.rates), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		fx, err := LoadFxTable(path, "USD")
+		assert.True(t, test.err == (err != nil), "~2|Test #%d expected error: %v, not error %s~", id, test.err, err)
+
+		var actual []string
+		var catalogErr *CatalogError
+		if errors.As(err, &catalogErr) {
+			for _, problem := range catalogErr.Problems {
+				actual = append(actual, fmt.Sprintf("%s:%d: %s", filepath.Base(problem.File), problem.Line, problem.Msg))
+			}
+		}
+		assert.True(t, fmt.Sprint(test.problems) == fmt.Sprint(actual), "~2|Test #%d expected problems: %v, not problems %v~", id, test.problems, actual)
+
+		var loaded []string
+		for _, currency := range []string{"EUR", "JPY", "USD"} {
+			if _, found := fx.Rates[currency]; found {
+				loaded = append(loaded, currency)
+			}
+		}
+		assert.True(t, fmt.Sprint(test.loaded) == fmt.Sprint(loaded), "~2|Test #%d expected currencies: %v, not currencies %v~", id, test.loaded, loaded)
+	}
+}
+
+func Test_LoadFxTable_Rates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fxrates.csv")
+	if err := os.WriteFile(path, []byte("USD,2,1\nJPY,0,151.30\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fx, err := LoadFxTable(path, "USD")
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	expected := service.FxRate{Currency: "JPY", MinorUnits: 0, Rate: money.MustParseRate("151.3")}
+	assert.True(t, fx.Base == "USD" && fx.Rates["JPY"] == expected, "~2|Test expected base USD and rate %+v, not table %+v~", expected, fx)
+}
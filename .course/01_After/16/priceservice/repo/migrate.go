@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+)
+
+// migrations are applied in order and recorded in schema_migrations, so
+// new schema changes must only ever be appended.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS products (
+		code  VARCHAR(64)   PRIMARY KEY,
+		price NUMERIC(12,2) NOT NULL CHECK (price >= 0)
+	)`,
+	`CREATE TABLE IF NOT EXISTS partners (
+		name     VARCHAR(64)  PRIMARY KEY,
+		discount NUMERIC(5,4) NOT NULL CHECK (discount >= 0 AND discount < 1)
+	)`,
+	`CREATE TABLE IF NOT EXISTS product_tiers (
+		code    VARCHAR(64)   NOT NULL REFERENCES products (code),
+		min_qty INTEGER       NOT NULL CHECK (min_qty > 1),
+		price   NUMERIC(12,2) NOT NULL CHECK (price >= 0),
+		PRIMARY KEY (code, min_qty)
+	)`,
+	`CREATE TABLE IF NOT EXISTS partner_prices (
+		partner   VARCHAR(64)   NOT NULL REFERENCES partners (name),
+		code      VARCHAR(64)   NOT NULL REFERENCES products (code),
+		discount  NUMERIC(5,4)  CHECK (discount >= 0 AND discount < 1),
+		net_price NUMERIC(12,2) CHECK (net_price >= 0),
+		PRIMARY KEY (partner, code),
+		CHECK ((discount IS NULL) <> (net_price IS NULL))
+	)`,
+	// Dated prices and discounts override the undated ones in products and
+	// partners while in effect; a NULL undated value leaves gaps unpriced.
+	`ALTER TABLE products ALTER COLUMN price DROP NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS product_prices (
+		code       VARCHAR(64)   NOT NULL REFERENCES products (code),
+		valid_from TIMESTAMPTZ   NOT NULL DEFAULT '-infinity',
+		valid_to   TIMESTAMPTZ,
+		price      NUMERIC(12,2) NOT NULL CHECK (price >= 0),
+		PRIMARY KEY (code, valid_from),
+		CHECK (valid_to IS NULL OR valid_to > valid_from)
+	)`,
+	`ALTER TABLE partners ALTER COLUMN discount DROP NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS partner_discounts (
+		partner    VARCHAR(64)  NOT NULL REFERENCES partners (name),
+		valid_from TIMESTAMPTZ  NOT NULL DEFAULT '-infinity',
+		valid_to   TIMESTAMPTZ,
+		discount   NUMERIC(5,4) NOT NULL CHECK (discount >= 0 AND discount < 1),
+		PRIMARY KEY (partner, valid_from),
+		CHECK (valid_to IS NULL OR valid_to > valid_from)
+	)`,
+	// A NULL currency prices the product in the base currency.
+	`ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) CHECK (currency ~ '^[A-Z]{3}$')`,
+	// A NULL tax class is the standard class.
+	`ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class VARCHAR(32) CHECK (tax_class <> '')`,
+	`ALTER TABLE partners ADD COLUMN IF NOT EXISTS tax_exempt BOOLEAN NOT NULL DEFAULT FALSE`,
+	// Quantity breaks and per-product partner terms are dated like prices
+	// and discounts. Existing rows stay in effect from '-infinity', and a
+	// dated row overrides them for its min_qty or code while in effect.
+	`ALTER TABLE product_tiers
+		ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ NOT NULL DEFAULT '-infinity',
+		ADD COLUMN IF NOT EXISTS valid_to   TIMESTAMPTZ,
+		ADD CHECK (valid_to IS NULL OR valid_to > valid_from),
+		DROP CONSTRAINT product_tiers_pkey,
+		ADD PRIMARY KEY (code, min_qty, valid_from)`,
+	`ALTER TABLE partner_prices
+		ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ NOT NULL DEFAULT '-infinity',
+		ADD COLUMN IF NOT EXISTS valid_to   TIMESTAMPTZ,
+		ADD CHECK (valid_to IS NULL OR valid_to > valid_from),
+		DROP CONSTRAINT partner_prices_pkey,
+		ADD PRIMARY KEY (partner, code, valid_from)`,
+	// Coupon uses are counted here so that every instance of the service
+	// shares them. A redemption key holds at most one use of its coupon.
+	`CREATE TABLE IF NOT EXISTS coupon_uses (
+		coupon VARCHAR(64) PRIMARY KEY,
+		uses   INTEGER     NOT NULL CHECK (uses >= 0)
+	)`,
+	`CREATE TABLE IF NOT EXISTS coupon_redemptions (
+		coupon         VARCHAR(64)  NOT NULL,
+		redemption_key VARCHAR(128) NOT NULL,
+		redeemed_at    TIMESTAMPTZ  NOT NULL DEFAULT now(),
+		PRIMARY KEY (coupon, redemption_key)
+	)`,
+}
+
+const (
+	createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`
+	selectSchemaVersion   = `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`
+	insertSchemaVersion   = `INSERT INTO schema_migrations (version) VALUES ($1)`
+)
+
+// Migrate brings the database schema up to date, applying each pending
+// migration in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) (err error) {
+	if _, err = db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+
+	var version int
+	if err = db.QueryRowContext(ctx, selectSchemaVersion).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err = applyMigration(ctx, db, i+1, migrations[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version int, migration string) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, insertSchemaVersion, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
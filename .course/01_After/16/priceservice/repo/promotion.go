@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+)
+
+// CouponLedger counts the uses of coupons for a promotion repo. Redeem
+// records one use of coupon under key, and reports false without recording
+// it once limit uses have been recorded; a limit of 0 means no limit. A key
+// that already holds a use of coupon is reported redeemed without recording
+// another.
+type CouponLedger interface {
+	Uses(ctx context.Context, coupon string) (uses int, err error)
+	Redeem(ctx context.Context, coupon string, key string, limit int) (redeemed bool, err error)
+}
+
+type promotionRepo struct {
+	promotions map[string]service.Promotion
+	ledger     CouponLedger
+}
+
+// NewPromotionRepo loads the promotions from the CSV file at path and counts
+// their uses in ledger. As with NewProductRepo, rows that fail validation
+// are left out and reported together as a *CatalogError alongside a usable
+// repo.
+func NewPromotionRepo(path string, ledger CouponLedger) (pr *promotionRepo, err error) {
+	records, err := readCSV(path)
+	if err != nil {
+		return nil, err
+	}
+
+	promotions, problems := parsePromotions(path, records)
+
+	pr = &promotionRepo{
+		promotions: promotions,
+		ledger:     ledger,
+	}
+
+	if len(problems) > 0 {
+		return pr, &CatalogError{Problems: problems}
+	}
+
+	return pr, nil
+}
+
+func (pr *promotionRepo) FetchPromotion(ctx context.Context, coupon string) (promotion service.Promotion, err error) {
+	if err := ctx.Err(); err != nil {
+		return service.Promotion{}, err
+	}
+
+	promotion, found := pr.promotions[coupon]
+	if !found {
+		return service.Promotion{}, service.ErrRecordNotFound
+	}
+
+	return promotion, nil
+}
+
+func (pr *promotionRepo) Uses(ctx context.Context, coupon string) (uses int, err error) {
+	if _, found := pr.promotions[coupon]; !found {
+		return 0, service.ErrRecordNotFound
+	}
+
+	return pr.ledger.Uses(ctx, coupon)
+}
+
+func (pr *promotionRepo) Redeem(ctx context.Context, coupon string, key string) (redeemed bool, err error) {
+	promotion, found := pr.promotions[coupon]
+	if !found {
+		return false, service.ErrRecordNotFound
+	}
+
+	return pr.ledger.Redeem(ctx, coupon, key, promotion.Limit)
+}
+
+type memoryLedger struct {
+	mu   sync.Mutex
+	uses map[string]int
+	keys map[string]map[string]bool
+}
+
+// NewMemoryLedger counts coupon uses in memory. Each instance of the service
+// then keeps its own counts, which start again from zero when it restarts,
+// so usage limits only hold while a single instance runs. The SQL repo is a
+// ledger the instances can share.
+func NewMemoryLedger() (ml *memoryLedger) {
+	ml = &memoryLedger{
+		uses: make(map[string]int, 0),
+		keys: make(map[string]map[string]bool, 0),
+	}
+
+	return ml
+}
+
+func (ml *memoryLedger) Uses(ctx context.Context, coupon string) (uses int, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	return ml.uses[coupon], nil
+}
+
+func (ml *memoryLedger) Redeem(ctx context.Context, coupon string, key string, limit int) (redeemed bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	if ml.keys[coupon][key] {
+		return true, nil
+	}
+	if limit > 0 && ml.uses[coupon] >= limit {
+		return false, nil
+	}
+
+	if ml.keys[coupon] == nil {
+		ml.keys[coupon] = make(map[string]bool, 0)
+	}
+	ml.keys[coupon][key] = true
+	ml.uses[coupon]++
+
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePromotions(t *testing.T, promotions string) (path string) {
+	path = filepath.Join(t.TempDir(), "promotions.csv")
+	if err := os.WriteFile(path, []byte(promotions), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_NewPromotionRepo(t *testing.T) {
+	promotions := "coupon,kind,value\n" +
+		"SAVE10,percent,0.10,from=2024-01-01,to=2025-01-01\n" +
+		"BOGOF,buyxgety,2:1,product=bbb222,limit=100\n" +
+		"TENOFF,amount,10.00,min=50.00,currency=EUR\n" +
+		"SAVE10\n" +
+		",percent,0.10\n" +
+		"HALF,percent,1.5\n" +
+		"BXGY,buyxgety,2\n" +
+		"NONE,amount,0.00\n" +
+		"CHEAP,amount,5.00,min=abc\n" +
+		"MIN,percent,0.10,min=50.00\n" +
+		"ONCE,percent,0.10,limit=0\n" +
+		"FREE,gift,1\n" +
+		"SAVE10,percent,0.20\n"
+
+	pr, err := NewPromotionRepo(writePromotions(t, promotions), NewMemoryLedger())
+
+	expected := []string{
+		"promotions.csv:5: expected at least 3 fields (coupon,kind,value), found 1",
+		"promotions.csv:6: blank coupon",
+		"promotions.csv:7: rate 1.5 for coupon \"HALF\" is outside (0,1]",
+		"promotions.csv:8: invalid quantities \"2\" for coupon \"BXGY\", expected BUY:FREE",
+		"promotions.csv:9: amount 0.00 for coupon \"NONE\" must be positive",
+		"promotions.csv:10: invalid min \"abc\" for coupon \"CHEAP\"",
+		"promotions.csv:11: unknown field \"min\", expected from or to for coupon \"MIN\"",
+		"promotions.csv:12: invalid limit \"0\" for coupon \"ONCE\"",
+		"promotions.csv:13: unknown kind \"gift\" for coupon \"FREE\", expected percent, buyxgety or amount",
+		"promotions.csv:14: duplicate coupon \"SAVE10\", first defined on line 2",
+	}
+
+	var actual []string
+	var catalogErr *CatalogError
+	if errors.As(err, &catalogErr) {
+		for _, problem := range catalogErr.Problems {
+			actual = append(actual, fmt.Sprintf("%s:%d: %s", filepath.Base(problem.File), problem.Line, problem.Msg))
+		}
+	}
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(actual), "~2|Test expected problems: %v, not problems %v~", expected, actual)
+
+	tests := []struct {
+		coupon    string
+		err       error
+		promotion service.Promotion
+	}{
+		{
+			coupon: "SAVE10",
+			promotion: service.Promotion{
+				Coupon: "SAVE10",
+				Kind:   service.PercentOff,
+				Rate:   money.MustParseRate("0.10"),
+				From:   time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+				To:     time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			coupon:    "BOGOF",
+			promotion: service.Promotion{Coupon: "BOGOF", Kind: service.BuyXGetY, Product: "bbb222", BuyQty: 2, FreeQty: 1, Limit: 100},
+		},
+		{
+			coupon:    "TENOFF",
+			promotion: service.Promotion{Coupon: "TENOFF", Kind: service.AmountOff, Amount: money.MustParse("10.00"), Threshold: money.MustParse("50.00"), Currency: "EUR"},
+		},
+		{
+			coupon: "HALF",
+			err:    service.ErrRecordNotFound,
+		},
+	}
+
+	for id, test := range tests {
+		promotion, err := pr.FetchPromotion(context.Background(), test.coupon)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %v, not error %v~", id, test.err, err)
+		assert.True(t, test.promotion == promotion, "~2|Test #%d expected promotion: %+v, not promotion %+v~", id, test.promotion, promotion)
+	}
+}
+
+func Test_PromotionRepo_Redeem(t *testing.T) {
+	ctx := context.Background()
+
+	promotions := "coupon,kind,value\n" +
+		"TWICE,percent,0.10,limit=2\n" +
+		"ALWAYS,percent,0.10\n"
+
+	pr, err := NewPromotionRepo(writePromotions(t, promotions), NewMemoryLedger())
+	assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+
+	tests := []struct {
+		coupon   string
+		key      string
+		err      error
+		redeemed bool
+	}{
+		{coupon: "TWICE", key: "order-1", redeemed: true},
+		{coupon: "TWICE", key: "order-1", redeemed: true},
+		{coupon: "TWICE", key: "order-2", redeemed: true},
+		{coupon: "TWICE", key: "order-3", redeemed: false},
+		{coupon: "TWICE", key: "order-2", redeemed: true},
+		{coupon: "ALWAYS", key: "order-1", redeemed: true},
+		{coupon: "ALWAYS", key: "order-2", redeemed: true},
+		{coupon: "NOPE", key: "order-1", err: service.ErrRecordNotFound},
+	}
+
+	for id, test := range tests {
+		redeemed, err := pr.Redeem(ctx, test.coupon, test.key)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %v, not error %v~", id, test.err, err)
+		assert.True(t, test.redeemed == redeemed, "~2|Test #%d expected redeemed: %t, not redeemed %t~", id, test.redeemed, redeemed)
+	}
+
+	for _, coupon := range []string{"TWICE", "ALWAYS"} {
+		uses, err := pr.Uses(ctx, coupon)
+		assert.True(t, err == nil, "~2|Test expected no error, not error %v~", err)
+		assert.True(t, uses == 2, "~2|Test expected 2 uses of %s, not %d~", coupon, uses)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = pr.Redeem(cancelled, "ALWAYS", "order-3")
+	assert.True(t, err == context.Canceled, "~2|Test expected error: %v, not error %v~", context.Canceled, err)
+}
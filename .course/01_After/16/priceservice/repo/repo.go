@@ -2,69 +2,126 @@ package repo
 
 import (
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"sync"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
 )
 
-type product struct {
-	code  string
-	price float64
+type catalog struct {
+	products map[string][]productVersion
+	partners map[string][]partnerVersion
+	terms    map[string]map[string][]termVersion
+	sources  map[string]fingerprint
+	version  int
+	loadedAt time.Time
 }
 
-type partner struct {
-	name     string
-	discount float64
+type productRepo struct {
+	productsPath   string
+	partnersPath   string
+	priceListsPath string
+
+	strict bool
+
+	mu      sync.RWMutex
+	catalog *catalog
 }
 
-type productRepo struct {
-	products map[string]*product
-	partners map[string]*partner
+// NewProductRepo loads the catalog from the products, partners and partner
+// price list CSV files. Rows that fail validation are reported together as
+// a *CatalogError, and the same policy applies at startup and on every
+// Reload: a strict repo refuses a catalog with any invalid row, while a
+// lenient one leaves the invalid rows out and serves every valid row. So
+// when strict, no repo is returned; otherwise the returned repo is usable.
+func NewProductRepo(productsPath string, partnersPath string, priceListsPath string, strict bool) (pr *productRepo, err error) {
+	c, err := loadCatalog(productsPath, partnersPath, priceListsPath)
+	if c == nil || (err != nil && strict) {
+		return nil, err
+	}
+	c.version = 1
+
+	pr = &productRepo{
+		productsPath:   productsPath,
+		partnersPath:   partnersPath,
+		priceListsPath: priceListsPath,
+		strict:         strict,
+		catalog:        c,
+	}
+
+	return pr, err
 }
 
-func NewProductRepo(productsPath string, partnersPath string) (pr *productRepo, err error) {
+func loadCatalog(productsPath string, partnersPath string, priceListsPath string) (c *catalog, err error) {
+	productsSource, err := fingerprintFile(productsPath)
+	if err != nil {
+		return nil, err
+	}
+
 	productRecords, err := readCSV(productsPath)
 	if err != nil {
 		return nil, err
 	}
 
-	products := make(map[string]*product, 0)
-	for _, record := range productRecords {
-		price, _ := strconv.ParseFloat(record[1], 64)
+	partnersSource, err := fingerprintFile(partnersPath)
+	if err != nil {
+		return nil, err
+	}
 
-		p := &product{
-			code:  record[0],
-			price: price,
-		}
+	partnerRecords, err := readCSV(partnersPath)
+	if err != nil {
+		return nil, err
+	}
 
-		products[p.code] = p
+	priceListsSource, err := fingerprintFile(priceListsPath)
+	if err != nil {
+		return nil, err
 	}
 
-	partnerRecords, err := readCSV(partnersPath)
+	priceListRecords, err := readCSV(priceListsPath)
 	if err != nil {
 		return nil, err
 	}
 
-	partners := make(map[string]*partner, 0)
-	for _, record := range partnerRecords {
-		discount, _ := strconv.ParseFloat(record[1], 64)
+	var problems []LoadError
 
-		p := &partner{
-			name:     record[0],
-			discount: discount,
-		}
+	products, productProblems := parseProducts(productsPath, productRecords)
+	problems = append(problems, productProblems...)
 
-		partners[p.name] = p
-	}
+	partners, partnerProblems := parsePartners(partnersPath, partnerRecords)
+	problems = append(problems, partnerProblems...)
 
-	pr = &productRepo{
+	terms, priceListProblems := parsePriceLists(priceListsPath, priceListRecords, products, partners)
+	problems = append(problems, priceListProblems...)
+
+	c = &catalog{
 		products: products,
 		partners: partners,
+		terms:    terms,
+		sources: map[string]fingerprint{
+			productsPath:   productsSource,
+			partnersPath:   partnersSource,
+			priceListsPath: priceListsSource,
+		},
+		loadedAt: time.Now(),
 	}
 
-	return pr, nil
+	if len(problems) > 0 {
+		return c, &CatalogError{Problems: problems}
+	}
+
+	return c, nil
+}
+
+type record struct {
+	line   int
+	fields []string
 }
 
-func readCSV(path string) (lines [][]string, err error) {
+func readCSV(path string) (records []record, err error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -72,28 +129,82 @@ func readCSV(path string) (lines [][]string, err error) {
 	defer f.Close()
 
 	csvReader := csv.NewReader(f)
-	records, err := csvReader.ReadAll()
-	if err != nil {
-		return nil, err
+	csvReader.FieldsPerRecord = -1
+	for {
+		fields, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		line, _ := csvReader.FieldPos(0)
+		records = append(records, record{line: line, fields: fields})
 	}
 
 	return records, nil
 }
 
-func (pr *productRepo) FetchPrice(code string) (price float64, found bool) {
-	p, ok := pr.products[code]
-	if !ok {
-		return 0.0, false
+// Reload parses the source files and swaps the fresh catalog in, following
+// the policy of NewProductRepo. When a file cannot be read, or when a strict
+// repo finds an invalid row, the current catalog is kept and the error is
+// returned. A lenient repo swaps in the valid rows and returns the
+// *CatalogError alongside.
+func (pr *productRepo) Reload() (reloaded bool, err error) {
+	c, err := loadCatalog(pr.productsPath, pr.partnersPath, pr.priceListsPath)
+	if c == nil || (err != nil && pr.strict) {
+		return false, err
 	}
 
-	return p.price, true
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	c.version = pr.catalog.version + 1
+	pr.catalog = c
+
+	return true, err
 }
 
-func (pr *productRepo) FetchDiscount(partner string) (discount float64, found bool) {
-	p, ok := pr.partners[partner]
-	if !ok {
-		return 0.0, false
+// Version returns the number of catalogs loaded so far, starting at 1.
+func (pr *productRepo) Version() int {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return pr.catalog.version
+}
+
+// LoadedAt returns the time the current catalog was loaded.
+func (pr *productRepo) LoadedAt() time.Time {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return pr.catalog.loadedAt
+}
+
+func (pr *productRepo) current() *catalog {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return pr.catalog
+}
+
+// FetchProduct returns the product as it was priced at asOf, stamped with
+// the version of the catalog it was read from.
+func (pr *productRepo) FetchProduct(code string, asOf time.Time) (product service.Product, found bool) {
+	c := pr.current()
+
+	product, found = resolveProduct(c.products[code], asOf)
+	if found {
+		product.CatalogVersion = c.version
 	}
 
-	return p.discount, true
+	return product, found
+}
+
+// FetchPriceList returns the partner's terms as they were at asOf.
+func (pr *productRepo) FetchPriceList(partner string, asOf time.Time) (priceList service.PriceList, found bool) {
+	c := pr.current()
+
+	return resolvePartner(c.partners[partner], c.terms[partner], asOf)
 }
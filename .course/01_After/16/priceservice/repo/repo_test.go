@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCatalog(t *testing.T, dir string, products string, partners string, priceLists string) (productsPath string, partnersPath string, priceListsPath string) {
+	productsPath = filepath.Join(dir, "products.csv")
+	partnersPath = filepath.Join(dir, "partners.csv")
+	priceListsPath = filepath.Join(dir, "pricelists.csv")
+
+	if err := os.WriteFile(productsPath, []byte(products), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(partnersPath, []byte(partners), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(priceListsPath, []byte(priceLists), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func Test_NewProductRepo(t *testing.T) {
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "aaa111,12.99\nbbb222,2.90\n", "superstore,0.15\n", "partner,code,discount,net\nsuperstore,aaa111,0.20,\nsuperstore,bbb222,,2.50\n")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	product, found := pr.FetchProduct("bbb222", time.Now())
+	assert.True(t, found && product.Tiers[0].Price == money.MustParse("2.90"), "~2|Test expected price: 2.90, not product %v~", product)
+
+	priceList, found := pr.FetchPriceList("superstore", time.Now())
+	assert.True(t, found && priceList.Discount == money.MustParseRate("0.15"), "~2|Test expected discount: 0.15, not discount %s~", priceList.Discount)
+	assert.True(t, priceList.DiscountFor("aaa111") == money.MustParseRate("0.20"), "~2|Test expected aaa111 discount: 0.20, not discount %s~", priceList.DiscountFor("aaa111"))
+
+	netPrice, found := priceList.NetPrice("bbb222")
+	assert.True(t, found && netPrice == money.MustParse("2.50"), "~2|Test expected bbb222 net price: 2.50, not net price %s~", netPrice)
+
+	_, found = pr.FetchPriceList("joesbakery", time.Now())
+	assert.True(t, !found, "~2|Test expected partner joesbakery to be missing~")
+
+	_, found = pr.FetchProduct("fff000", time.Now())
+	assert.True(t, !found, "~2|Test expected code fff000 to be missing~")
+
+	assert.True(t, pr.Version() == 1, "~2|Test expected version: 1, not version %d~", pr.Version())
+}
+
+func Test_Reload(t *testing.T) {
+	dir := t.TempDir()
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, dir, "aaa111,12.99\n", "superstore,0.15\n", "")
+
+	pr, _ := NewProductRepo(productsPath, partnersPath, priceListsPath, true)
+
+	writeCatalog(t, dir, "aaa111,13.49\n", "superstore,0.15\n", "")
+	reloaded, err := pr.Reload()
+	assert.True(t, reloaded && err == nil, "~2|Test expected a reload without error, not error %s~", err)
+
+	product, _ := pr.FetchProduct("aaa111", time.Now())
+	assert.True(t, product.Tiers[0].Price == money.MustParse("13.49"), "~2|Test expected price: 13.49, not product %v~", product)
+	assert.True(t, product.CatalogVersion == 2, "~2|Test expected catalog version: 2, not catalog version %d~", product.CatalogVersion)
+	assert.True(t, pr.Version() == 2, "~2|Test expected version: 2, not version %d~", pr.Version())
+
+	writeCatalog(t, dir, "aaa111,14.99,10:abc\n", "superstore,0.15\n", "")
+	reloaded, err = pr.Reload()
+	assert.True(t, !reloaded && err != nil, "~2|Test expected a parse error on reload~")
+
+	product, _ = pr.FetchProduct("aaa111", time.Now())
+	assert.True(t, product.Tiers[0].Price == money.MustParse("13.49"), "~2|Test expected old price: 13.49, not product %v~", product)
+	assert.True(t, pr.Version() == 2, "~2|Test expected version: 2, not version %d~", pr.Version())
+}
+
+func Test_Reload_Lenient(t *testing.T) {
+	dir := t.TempDir()
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, dir, "aaa111,12.99\nbbb222,2.90\n", "superstore,0.15\n", "")
+
+	pr, _ := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+
+	writeCatalog(t, dir, "aaa111,13.49\nbbb222,abc\n", "superstore,0.15\n", "")
+	reloaded, err := pr.Reload()
+
+	var catalogErr *CatalogError
+	assert.True(t, reloaded && errors.As(err, &catalogErr), "~2|Test expected a reload with a catalog error, not error %s~", err)
+
+	product, _ := pr.FetchProduct("aaa111", time.Now())
+	assert.True(t, product.Tiers[0].Price == money.MustParse("13.49"), "~2|Test expected price: 13.49, not product %v~", product)
+
+	_, found := pr.FetchProduct("bbb222", time.Now())
+	assert.True(t, !found, "~2|Test expected code bbb222 to be left out~")
+	assert.True(t, pr.Version() == 2, "~2|Test expected version: 2, not version %d~", pr.Version())
+}
+
+func Test_NewProductRepo_Tiers(t *testing.T) {
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "code,price\naaa111,12.99,10:11.50,100:10.00\n", "superstore,0.15\n", "")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	product, _ := pr.FetchProduct("aaa111", time.Now())
+	expected := []service.Tier{
+		{MinQty: 1, MaxQty: 9, Price: money.MustParse("12.99")},
+		{MinQty: 10, MaxQty: 99, Price: money.MustParse("11.50")},
+		{MinQty: 100, MaxQty: 0, Price: money.MustParse("10.00")},
+	}
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(product.Tiers), "~2|Test expected tiers: %v, not tiers %v~", expected, product.Tiers)
+}
+
+func Test_NewProductRepo_Currency(t *testing.T) {
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "aaa111,12.99\nbbb222,1500,currency=JPY,10:1400\n", "superstore,0.15\n", "")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	product, _ := pr.FetchProduct("aaa111", time.Now())
+	assert.True(t, product.Currency == "", "~2|Test expected the base currency, not currency %s~", product.Currency)
+
+	product, _ = pr.FetchProduct("bbb222", time.Now())
+	assert.True(t, product.Currency == "JPY" && len(product.Tiers) == 2, "~2|Test expected currency: JPY with 2 tiers, not product %+v~", product)
+}
+
+func Test_NewProductRepo_Tax(t *testing.T) {
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "aaa111,12.99\nbook01,20.00,tax=reduced\n", "superstore,0.15\ncharity,0.05,exempt=true\n", "")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+
+	product, _ := pr.FetchProduct("book01", time.Now())
+	assert.True(t, product.TaxClass == "reduced", "~2|Test expected tax class: reduced, not tax class %s~", product.TaxClass)
+
+	priceList, _ := pr.FetchPriceList("superstore", time.Now())
+	assert.True(t, !priceList.TaxExempt, "~2|Test expected superstore to pay tax~")
+
+	priceList, _ = pr.FetchPriceList("charity", time.Now())
+	assert.True(t, priceList.TaxExempt, "~2|Test expected charity to be tax-exempt~")
+}
@@ -0,0 +1,271 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+)
+
+// selectPrice and selectDiscount prefer the latest dated row in effect at $2
+// and fall back to the undated value. selectTiers and selectTerms take the
+// latest row in effect at $2 for each min_qty or code.
+const (
+	selectPrice = `SELECT price FROM (
+		SELECT price, valid_from FROM product_prices
+		WHERE code = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		UNION ALL
+		SELECT price, NULL FROM products WHERE code = $1 AND price IS NOT NULL
+	) AS p ORDER BY valid_from DESC NULLS LAST LIMIT 1`
+	selectAttributes = `SELECT currency, tax_class FROM products WHERE code = $1`
+	selectTiers      = `SELECT DISTINCT ON (min_qty) min_qty, price FROM product_tiers
+		WHERE code = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		ORDER BY min_qty, valid_from DESC`
+	selectDiscount = `SELECT discount FROM (
+		SELECT discount, valid_from FROM partner_discounts
+		WHERE partner = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		UNION ALL
+		SELECT discount, NULL FROM partners WHERE name = $1 AND discount IS NOT NULL
+	) AS d ORDER BY valid_from DESC NULLS LAST LIMIT 1`
+	selectTerms = `SELECT DISTINCT ON (code) code, discount, net_price FROM partner_prices
+		WHERE partner = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		ORDER BY code, valid_from DESC`
+	selectExempt = `SELECT tax_exempt FROM partners WHERE name = $1`
+)
+
+// insertRedemption records a redemption key once, and countUse counts a use
+// of the coupon only while it is below the limit in $2, 0 meaning no limit.
+// Redeem runs both in one transaction, so a use is never counted without its
+// key, and the conditional update keeps concurrent redemptions within the
+// limit.
+const (
+	selectUses       = `SELECT uses FROM coupon_uses WHERE coupon = $1`
+	insertRedemption = `INSERT INTO coupon_redemptions (coupon, redemption_key) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+	countUse = `INSERT INTO coupon_uses (coupon, uses) VALUES ($1, 1)
+		ON CONFLICT (coupon) DO UPDATE SET uses = coupon_uses.uses + 1
+		WHERE $2 = 0 OR coupon_uses.uses < $2`
+)
+
+type sqlRepo struct {
+	db *sql.DB
+
+	fetchPrice      *sql.Stmt
+	fetchAttributes *sql.Stmt
+	fetchTiers      *sql.Stmt
+	fetchDiscount   *sql.Stmt
+	fetchTerms      *sql.Stmt
+	fetchExempt     *sql.Stmt
+	fetchUses       *sql.Stmt
+	addRedemption   *sql.Stmt
+	addUse          *sql.Stmt
+}
+
+// NewSqlRepo migrates the schema and prepares the lookup statements. The
+// caller keeps ownership of db and must call Close before closing it. The
+// repo is also a CouponLedger that every instance of the service can share.
+func NewSqlRepo(ctx context.Context, db *sql.DB) (sr *sqlRepo, err error) {
+	if err = Migrate(ctx, db); err != nil {
+		return nil, err
+	}
+
+	sr = &sqlRepo{db: db}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&sr.fetchPrice, selectPrice},
+		{&sr.fetchAttributes, selectAttributes},
+		{&sr.fetchTiers, selectTiers},
+		{&sr.fetchDiscount, selectDiscount},
+		{&sr.fetchTerms, selectTerms},
+		{&sr.fetchExempt, selectExempt},
+		{&sr.fetchUses, selectUses},
+		{&sr.addRedemption, insertRedemption},
+		{&sr.addUse, countUse},
+	}
+	for _, s := range statements {
+		if *s.stmt, err = db.PrepareContext(ctx, s.query); err != nil {
+			sr.Close()
+			return nil, err
+		}
+	}
+
+	return sr, nil
+}
+
+// NUMERIC columns are scanned as strings and parsed exactly, so prices never
+// pass through float64 on the way out of the database. The base price and
+// each quantity break are dated separately, where the CSV repo dates a whole
+// product row: a dated price leaves the undated breaks in effect unless they
+// are ended or overridden by dated breaks of their own.
+func (sr *sqlRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product service.Product, err error) {
+	var value string
+	err = sr.fetchPrice.QueryRowContext(ctx, code, asOf).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.Product{}, service.ErrRecordNotFound
+	}
+	if err != nil {
+		return service.Product{}, err
+	}
+
+	price, err := money.Parse(value)
+	if err != nil {
+		return service.Product{}, err
+	}
+
+	var currency, taxClass sql.NullString
+	if err = sr.fetchAttributes.QueryRowContext(ctx, code).Scan(&currency, &taxClass); err != nil {
+		return service.Product{}, err
+	}
+
+	rows, err := sr.fetchTiers.QueryContext(ctx, code, asOf)
+	if err != nil {
+		return service.Product{}, err
+	}
+	defer rows.Close()
+
+	var breaks []service.Tier
+	for rows.Next() {
+		var tier service.Tier
+		if err = rows.Scan(&tier.MinQty, &value); err != nil {
+			return service.Product{}, err
+		}
+		if tier.Price, err = money.Parse(value); err != nil {
+			return service.Product{}, err
+		}
+
+		breaks = append(breaks, tier)
+	}
+	if err = rows.Err(); err != nil {
+		return service.Product{}, err
+	}
+
+	product = service.NewProduct(code, price, breaks...)
+	product.Currency = currency.String
+	product.TaxClass = taxClass.String
+
+	return product, nil
+}
+
+func (sr *sqlRepo) FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList service.PriceList, err error) {
+	var value string
+	err = sr.fetchDiscount.QueryRowContext(ctx, partner, asOf).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.PriceList{}, service.ErrRecordNotFound
+	}
+	if err != nil {
+		return service.PriceList{}, err
+	}
+
+	priceList = service.PriceList{
+		Partner:   partner,
+		Discounts: make(map[string]money.Rate, 0),
+		NetPrices: make(map[string]money.Amount, 0),
+	}
+	if priceList.Discount, err = money.ParseRate(value); err != nil {
+		return service.PriceList{}, err
+	}
+	if err = sr.fetchExempt.QueryRowContext(ctx, partner).Scan(&priceList.TaxExempt); err != nil {
+		return service.PriceList{}, err
+	}
+
+	rows, err := sr.fetchTerms.QueryContext(ctx, partner, asOf)
+	if err != nil {
+		return service.PriceList{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var discount, netPrice sql.NullString
+		if err = rows.Scan(&code, &discount, &netPrice); err != nil {
+			return service.PriceList{}, err
+		}
+
+		if netPrice.Valid {
+			if priceList.NetPrices[code], err = money.Parse(netPrice.String); err != nil {
+				return service.PriceList{}, err
+			}
+			continue
+		}
+		if discount.Valid {
+			if priceList.Discounts[code], err = money.ParseRate(discount.String); err != nil {
+				return service.PriceList{}, err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return service.PriceList{}, err
+	}
+
+	return priceList, nil
+}
+
+func (sr *sqlRepo) Uses(ctx context.Context, coupon string) (uses int, err error) {
+	err = sr.fetchUses.QueryRowContext(ctx, coupon).Scan(&uses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return uses, nil
+}
+
+func (sr *sqlRepo) Redeem(ctx context.Context, coupon string, key string, limit int) (redeemed bool, err error) {
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if !redeemed {
+			tx.Rollback()
+		}
+	}()
+
+	added, err := rowsAffected(tx.StmtContext(ctx, sr.addRedemption).ExecContext(ctx, coupon, key))
+	if err != nil {
+		return false, err
+	}
+
+	// A key that is already recorded already holds its use.
+	if added > 0 {
+		counted, err := rowsAffected(tx.StmtContext(ctx, sr.addUse).ExecContext(ctx, coupon, limit))
+		if err != nil || counted == 0 {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func rowsAffected(result sql.Result, execErr error) (rows int64, err error) {
+	if execErr != nil {
+		return 0, execErr
+	}
+
+	return result.RowsAffected()
+}
+
+func (sr *sqlRepo) Close() error {
+	var err error
+	for _, stmt := range []*sql.Stmt{sr.fetchPrice, sr.fetchAttributes, sr.fetchTiers, sr.fetchDiscount, sr.fetchTerms, sr.fetchExempt, sr.fetchUses, sr.addRedemption, sr.addUse} {
+		if stmt == nil {
+			continue
+		}
+		if closeErr := stmt.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
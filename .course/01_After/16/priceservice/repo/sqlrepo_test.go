@@ -0,0 +1,306 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	fdb := &fakeDB{}
+	db, _ := openFakeDB(t.Name(), fdb)
+	defer db.Close()
+
+	err := Migrate(ctx, db)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	assert.True(t, fdb.version == len(migrations), "~2|Test expected version: %d, not version %d~", len(migrations), fdb.version)
+
+	expected := []string{"schema_migrations", "products", "partners", "product_tiers", "partner_prices", "product_prices", "partner_discounts", "coupon_uses", "coupon_redemptions"}
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(fdb.tables), "~2|Test expected tables: %v, not tables %v~", expected, fdb.tables)
+
+	err = Migrate(ctx, db)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	assert.True(t, len(fdb.tables) == 10, "~2|Test expected migrations to be applied once, not tables %v~", fdb.tables)
+}
+
+func Test_SqlRepo(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	fdb := &fakeDB{
+		products: map[string]string{"aaa111": "12.99", "bbb222": "2.90"},
+		currency: map[string]string{"bbb222": "JPY"},
+		taxClass: map[string]string{"bbb222": "reduced"},
+		tiers: map[string][][]driver.Value{
+			"aaa111": {{int64(10), "11.50"}, {int64(100), "10.00"}},
+		},
+		partners: map[string]string{"superstore": "0.1500"},
+		exempt:   map[string]bool{"superstore": true},
+		terms: map[string][][]driver.Value{
+			"superstore": {{"aaa111", "0.2000", nil}, {"bbb222", nil, "2.50"}},
+		},
+	}
+	db, _ := openFakeDB(t.Name(), fdb)
+	defer db.Close()
+
+	sr, err := NewSqlRepo(ctx, db)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	defer sr.Close()
+
+	tests := []struct {
+		ctx   context.Context
+		code  string
+		err   error
+		tiers []service.Tier
+	}{
+		{
+			ctx:  ctx,
+			code: "aaa111",
+			err:  nil,
+			tiers: []service.Tier{
+				{MinQty: 1, MaxQty: 9, Price: money.MustParse("12.99")},
+				{MinQty: 10, MaxQty: 99, Price: money.MustParse("11.50")},
+				{MinQty: 100, MaxQty: 0, Price: money.MustParse("10.00")},
+			},
+		},
+		{
+			ctx:  ctx,
+			code: "bbb222",
+			err:  nil,
+			tiers: []service.Tier{
+				{MinQty: 1, MaxQty: 0, Price: money.MustParse("2.90")},
+			},
+		},
+		{
+			ctx:   ctx,
+			code:  "fff000",
+			err:   service.ErrRecordNotFound,
+			tiers: nil,
+		},
+		{
+			ctx:   cancelled,
+			code:  "aaa111",
+			err:   context.Canceled,
+			tiers: nil,
+		},
+	}
+
+	for id, test := range tests {
+		product, err := sr.FetchProduct(test.ctx, test.code, time.Now())
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, fmt.Sprint(test.tiers) == fmt.Sprint(product.Tiers), "~2|Test #%d expected tiers: %v, not tiers %v~", id, test.tiers, product.Tiers)
+	}
+
+	product, _ := sr.FetchProduct(ctx, "aaa111", time.Now())
+	assert.True(t, product.Currency == "", "~2|Test expected the base currency, not currency %s~", product.Currency)
+
+	product, _ = sr.FetchProduct(ctx, "bbb222", time.Now())
+	assert.True(t, product.Currency == "JPY", "~2|Test expected currency: JPY, not currency %s~", product.Currency)
+	assert.True(t, product.TaxClass == "reduced", "~2|Test expected tax class: reduced, not tax class %s~", product.TaxClass)
+
+	priceList, err := sr.FetchPriceList(ctx, "superstore", time.Now())
+	assert.True(t, err == nil && priceList.Discount == money.MustParseRate("0.15"), "~2|Test expected discount: 0.15, not discount %s~", priceList.Discount)
+	assert.True(t, priceList.TaxExempt, "~2|Test expected superstore to be tax-exempt~")
+	assert.True(t, priceList.DiscountFor("aaa111") == money.MustParseRate("0.20"), "~2|Test expected aaa111 discount: 0.20, not discount %s~", priceList.DiscountFor("aaa111"))
+	assert.True(t, priceList.DiscountFor("ccc333") == money.MustParseRate("0.15"), "~2|Test expected ccc333 discount: 0.15, not discount %s~", priceList.DiscountFor("ccc333"))
+
+	netPrice, found := priceList.NetPrice("bbb222")
+	assert.True(t, found && netPrice == money.MustParse("2.50"), "~2|Test expected bbb222 net price: 2.50, not net price %s~", netPrice)
+
+	_, err = sr.FetchPriceList(ctx, "joesbakery", time.Now())
+	assert.True(t, err == service.ErrRecordNotFound, "~2|Test expected error: %s, not error %s~", service.ErrRecordNotFound, err)
+
+	prepared := fdb.prepared
+	sr.FetchProduct(ctx, "aaa111", time.Now())
+	assert.True(t, fdb.prepared == prepared, "~2|Test expected prepared statements to be reused~")
+
+	fdb.err = errors.New("connection reset")
+	_, err = sr.FetchProduct(ctx, "aaa111", time.Now())
+	assert.True(t, err != nil && !errors.Is(err, service.ErrRecordNotFound), "~2|Test expected a backend error, not error %s~", err)
+}
+
+func Test_SqlRepo_AsOf(t *testing.T) {
+	ctx := context.Background()
+
+	date := func(value string) time.Time {
+		t, _ := time.Parse("2006-01-02", value)
+		return t
+	}
+
+	fdb := &fakeDB{
+		products: map[string]string{"aaa111": "12.99"},
+		prices: map[string][]fakeDated{
+			"aaa111": {{from: date("2025-01-01"), to: date("2025-02-01"), value: "11.99"}},
+			"bbb222": {{from: date("2025-01-01"), to: date("2025-02-01"), value: "2.90"}, {from: date("2025-03-01"), value: "3.10"}},
+		},
+		partners: map[string]string{"superstore": "0.1500"},
+		discounts: map[string][]fakeDated{
+			"superstore": {{from: date("2025-01-01"), to: date("2025-02-01"), value: "0.2000"}},
+		},
+	}
+	db, _ := openFakeDB(t.Name(), fdb)
+	defer db.Close()
+
+	sr, err := NewSqlRepo(ctx, db)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	defer sr.Close()
+
+	tests := []struct {
+		code  string
+		asOf  time.Time
+		err   error
+		price money.Amount
+	}{
+		{
+			code:  "aaa111",
+			asOf:  date("2025-01-15"),
+			err:   nil,
+			price: money.MustParse("11.99"),
+		},
+		{
+			code:  "aaa111",
+			asOf:  date("2025-02-01"),
+			err:   nil,
+			price: money.MustParse("12.99"),
+		},
+		{
+			code:  "bbb222",
+			asOf:  date("2025-02-15"),
+			err:   service.ErrRecordNotFound,
+			price: 0,
+		},
+		{
+			code:  "bbb222",
+			asOf:  date("2025-03-01"),
+			err:   nil,
+			price: money.MustParse("3.10"),
+		},
+	}
+
+	for id, test := range tests {
+		product, err := sr.FetchProduct(ctx, test.code, test.asOf)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		if err == nil {
+			assert.True(t, test.price == product.Tiers[0].Price, "~2|Test #%d expected price: %s, not price %s~", id, test.price, product.Tiers[0].Price)
+		}
+	}
+
+	priceList, err := sr.FetchPriceList(ctx, "superstore", date("2025-01-15"))
+	assert.True(t, err == nil && priceList.Discount == money.MustParseRate("0.20"), "~2|Test expected discount: 0.20, not discount %s~", priceList.Discount)
+
+	priceList, err = sr.FetchPriceList(ctx, "superstore", date("2025-02-15"))
+	assert.True(t, err == nil && priceList.Discount == money.MustParseRate("0.15"), "~2|Test expected discount: 0.15, not discount %s~", priceList.Discount)
+}
+
+func Test_SqlRepo_AsOfTerms(t *testing.T) {
+	ctx := context.Background()
+
+	date := func(value string) time.Time {
+		t, _ := time.Parse("2006-01-02", value)
+		return t
+	}
+
+	fdb := &fakeDB{
+		products: map[string]string{"aaa111": "12.99"},
+		tiers: map[string][][]driver.Value{
+			"aaa111": {{int64(10), "11.50"}},
+		},
+		datedTiers: map[string][]fakeDatedRow{
+			"aaa111": {
+				{from: date("2025-01-01"), to: date("2025-02-01"), values: []driver.Value{int64(10), "10.99"}},
+				{from: date("2025-01-01"), to: date("2025-02-01"), values: []driver.Value{int64(50), "9.99"}},
+			},
+		},
+		partners: map[string]string{"superstore": "0.1500"},
+		terms: map[string][][]driver.Value{
+			"superstore": {{"aaa111", "0.2500", nil}},
+		},
+		datedTerms: map[string][]fakeDatedRow{
+			"superstore": {{from: date("2025-01-01"), to: date("2025-02-01"), values: []driver.Value{"aaa111", nil, "9.50"}}},
+		},
+	}
+	db, _ := openFakeDB(t.Name(), fdb)
+	defer db.Close()
+
+	sr, err := NewSqlRepo(ctx, db)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	defer sr.Close()
+
+	tests := []struct {
+		asOf     time.Time
+		tiers    string
+		discount money.Rate
+		netPrice money.Amount
+	}{
+		{asOf: date("2024-12-31"), tiers: "[{1 9 12.99} {10 0 11.50}]", discount: money.MustParseRate("0.25")},
+		{asOf: date("2025-01-15"), tiers: "[{1 9 12.99} {10 49 10.99} {50 0 9.99}]", discount: money.MustParseRate("0.15"), netPrice: money.MustParse("9.50")},
+		{asOf: date("2025-02-01"), tiers: "[{1 9 12.99} {10 0 11.50}]", discount: money.MustParseRate("0.25")},
+	}
+
+	for id, test := range tests {
+		product, err := sr.FetchProduct(ctx, "aaa111", test.asOf)
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.tiers == fmt.Sprint(product.Tiers), "~2|Test #%d expected tiers: %s, not tiers %v~", id, test.tiers, product.Tiers)
+
+		priceList, err := sr.FetchPriceList(ctx, "superstore", test.asOf)
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+
+		netPrice, _ := priceList.NetPrice("aaa111")
+		assert.True(t, test.netPrice == netPrice, "~2|Test #%d expected net price: %s, not net price %s~", id, test.netPrice, netPrice)
+		assert.True(t, test.discount == priceList.DiscountFor("aaa111"), "~2|Test #%d expected discount: %s, not discount %s~", id, test.discount, priceList.DiscountFor("aaa111"))
+	}
+}
+
+func Test_SqlRepo_Redeem(t *testing.T) {
+	ctx := context.Background()
+
+	fdb := &fakeDB{}
+	db, _ := openFakeDB(t.Name(), fdb)
+	defer db.Close()
+
+	sr, err := NewSqlRepo(ctx, db)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	defer sr.Close()
+
+	tests := []struct {
+		coupon   string
+		key      string
+		limit    int
+		redeemed bool
+		uses     int
+	}{
+		{coupon: "TWICE", key: "order-1", limit: 2, redeemed: true, uses: 1},
+		{coupon: "TWICE", key: "order-1", limit: 2, redeemed: true, uses: 1},
+		{coupon: "TWICE", key: "order-2", limit: 2, redeemed: true, uses: 2},
+		{coupon: "TWICE", key: "order-3", limit: 2, redeemed: false, uses: 2},
+		{coupon: "TWICE", key: "order-2", limit: 2, redeemed: true, uses: 2},
+		{coupon: "ALWAYS", key: "order-1", limit: 0, redeemed: true, uses: 1},
+		{coupon: "ALWAYS", key: "order-2", limit: 0, redeemed: true, uses: 2},
+	}
+
+	for id, test := range tests {
+		redeemed, err := sr.Redeem(ctx, test.coupon, test.key, test.limit)
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.redeemed == redeemed, "~2|Test #%d expected redeemed: %t, not redeemed %t~", id, test.redeemed, redeemed)
+
+		uses, err := sr.Uses(ctx, test.coupon)
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.uses == uses, "~2|Test #%d expected %d uses, not %d~", id, test.uses, uses)
+	}
+
+	// The key of a refused use is rolled back with it, so it holds no use.
+	assert.True(t, !fdb.redemptions["TWICE/order-3"], "~2|Test expected the refused key to be rolled back~")
+
+	uses, err := sr.Uses(ctx, "NONE")
+	assert.True(t, err == nil && uses == 0, "~2|Test expected no uses of an unused coupon, not %d, error %s~", uses, err)
+}
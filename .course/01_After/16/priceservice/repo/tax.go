@@ -0,0 +1,26 @@
+package repo
+
+import "github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+
+// LoadTaxTable reads the tax rates by region and product tax class from the
+// CSV file at path. As with NewProductRepo, rows that fail validation are
+// left out and reported together as a *CatalogError alongside a usable
+// table.
+func LoadTaxTable(path string) (tt service.TaxTable, err error) {
+	records, err := readCSV(path)
+	if err != nil {
+		return service.TaxTable{}, err
+	}
+
+	rates, problems := parseTaxRates(path, records)
+
+	tt = service.TaxTable{
+		Rates: rates,
+	}
+
+	if len(problems) > 0 {
+		return tt, &CatalogError{Problems: problems}
+	}
+
+	return tt, nil
+}
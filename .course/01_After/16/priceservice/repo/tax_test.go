@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadTaxTable(t *testing.T) {
+	rates := "region,class,rate\n" +
+		"uk,standard,0.20\n" +
+		"uk,reduced,0.05\n" +
+		"uk\n" +
+		",standard,0.20\n" +
+		"de,,0.19\n" +
+		"de,standard,abc\n" +
+		"de,standard,1\n" +
+		"uk,standard,0.175\n" +
+		"de,standard,0.19\n"
+
+	path := filepath.Join(t.TempDir(), "taxrates.csv")
+	if err := os.WriteFile(path, []byte(rates), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tt, err := LoadTaxTable(path)
+
+	expected := []string{
+		"taxrates.csv:4: expected 3 fields (region,class,rate), found 1",
+		"taxrates.csv:5: blank region",
+		"taxrates.csv:6: blank tax class for region \"de\"",
+		"taxrates.csv:7: invalid rate \"abc\" for region \"de\" and class \"standard\"",
+		"taxrates.csv:8: rate 1 for region \"de\" and class \"standard\" is outside [0,1)",
+		"taxrates.csv:9: duplicate rate for region \"uk\" and class \"standard\", first defined on line 2",
+	}
+
+	var actual []string
+	var catalogErr *CatalogError
+	if errors.As(err, &catalogErr) {
+		for _, problem := range catalogErr.Problems {
+			actual = append(actual, fmt.Sprintf("%s:%d: %s", filepath.Base(problem.File), problem.Line, problem.Msg))
+		}
+	}
+	assert.True(t, fmt.Sprint(expected) == fmt.Sprint(actual), "~2|Test expected problems: %v, not problems %v~", expected, actual)
+
+	tests := []struct {
+		region string
+		class  string
+		rate   money.Rate
+	}{
+		{region: "uk", class: "standard", rate: money.MustParseRate("0.20")},
+		{region: "uk", class: "reduced", rate: money.MustParseRate("0.05")},
+		{region: "de", class: "standard", rate: money.MustParseRate("0.19")},
+	}
+
+	for id, test := range tests {
+		rate, err := tt.Rate(test.region, test.class)
+		assert.True(t, err == nil && test.rate == rate, "~2|Test #%d expected rate: %s, not rate %s (%v)~", id, test.rate, rate, err)
+	}
+}
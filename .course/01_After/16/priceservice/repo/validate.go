@@ -0,0 +1,665 @@
+package repo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+)
+
+var (
+	productsHeader   = []string{"code", "price"}
+	partnersHeader   = []string{"name", "discount"}
+	priceListsHeader = []string{"partner", "code", "discount", "net"}
+	fxRatesHeader    = []string{"currency", "minorUnits", "rate"}
+	taxRatesHeader   = []string{"region", "class", "rate"}
+	promotionsHeader = []string{"coupon", "kind", "value"}
+)
+
+// LoadError describes a single rejected row in a catalog file.
+type LoadError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e LoadError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// CatalogError collects every LoadError found while loading a catalog.
+type CatalogError struct {
+	Problems []LoadError
+}
+
+func (e *CatalogError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		msgs[i] = problem.Error()
+	}
+
+	return fmt.Sprintf("catalog has %d invalid rows: %s", len(e.Problems), strings.Join(msgs, "; "))
+}
+
+// parseProducts reads rows of code,price followed by optional quantity
+// breaks written as minQty:price, e.g. aaa111,12.99,10:11.50,100:10.00, an
+// optional currency=CODE, an optional tax=CLASS, and an optional validity
+// written as from=TIME and to=TIME. Products without a currency are priced
+// in the base currency and products without a tax class are in the
+// standard class.
+func parseProducts(path string, records []record) (products map[string][]productVersion, problems []LoadError) {
+	products = make(map[string][]productVersion, 0)
+
+	seen := make(map[string][]versionRef, 0)
+	for _, r := range skipHeader(records, productsHeader) {
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, LoadError{File: path, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if len(r.fields) < 2 {
+			fail("expected at least 2 fields (code,price), found %d", len(r.fields))
+			continue
+		}
+
+		code := strings.TrimSpace(r.fields[0])
+		if code == "" {
+			fail("blank product code")
+			continue
+		}
+
+		price, err := money.Parse(r.fields[1])
+		if err != nil {
+			fail("invalid price %q for product %q", r.fields[1], code)
+			continue
+		}
+		if price < 0 {
+			fail("negative price %v for product %q", price, code)
+			continue
+		}
+
+		currency, found, fields := takeField(r.fields[2:], "currency")
+		if found && !validCurrency(currency) {
+			fail("invalid currency %q for product %q", currency, code)
+			continue
+		}
+
+		taxClass, found, fields := takeField(fields, "tax")
+		if found && strings.TrimSpace(taxClass) == "" {
+			fail("blank tax class for product %q", code)
+			continue
+		}
+
+		valid, tierFields, err := parseValidity(fields)
+		if err != nil {
+			fail("%s for product %q", err, code)
+			continue
+		}
+
+		breaks, err := parseTiers(tierFields)
+		if err != nil {
+			fail("%s for product %q", err, code)
+			continue
+		}
+
+		if line, ok := conflicting(seen[code], valid); ok {
+			if valid.dated() {
+				fail("validity of product %q overlaps line %d", code, line)
+			} else {
+				fail("duplicate product code %q, first defined on line %d", code, line)
+			}
+			continue
+		}
+		seen[code] = append(seen[code], versionRef{validity: valid, line: r.line})
+
+		product := service.NewProduct(code, price, breaks...)
+		product.Currency = currency
+		product.TaxClass = strings.TrimSpace(taxClass)
+
+		products[code] = append(products[code], productVersion{
+			validity: valid,
+			product:  product,
+		})
+	}
+
+	return products, problems
+}
+
+func parseTiers(fields []string) (breaks []service.Tier, err error) {
+	minQty := 1
+	for _, field := range fields {
+		qtyField, priceField, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid tier %q, expected minQty:price", field)
+		}
+
+		qty, err := strconv.Atoi(qtyField)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier quantity %q", qtyField)
+		}
+		if qty <= minQty {
+			return nil, fmt.Errorf("tier quantity %d must be greater than %d", qty, minQty)
+		}
+		minQty = qty
+
+		price, err := money.Parse(priceField)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier price %q", priceField)
+		}
+		if price < 0 {
+			return nil, fmt.Errorf("negative tier price %v", price)
+		}
+
+		breaks = append(breaks, service.Tier{MinQty: qty, Price: price})
+	}
+
+	return breaks, nil
+}
+
+// parsePartners reads rows of name,discount followed by an optional
+// exempt=true for partners that pay no tax and an optional validity written
+// as from=TIME and to=TIME. The per-product terms from the price lists file
+// are dated on their own and resolved at the same instant as the row.
+func parsePartners(path string, records []record) (partners map[string][]partnerVersion, problems []LoadError) {
+	partners = make(map[string][]partnerVersion, 0)
+
+	seen := make(map[string][]versionRef, 0)
+	for _, r := range skipHeader(records, partnersHeader) {
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, LoadError{File: path, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if len(r.fields) < 2 {
+			fail("expected at least 2 fields (name,discount), found %d", len(r.fields))
+			continue
+		}
+
+		name := strings.TrimSpace(r.fields[0])
+		if name == "" {
+			fail("blank partner name")
+			continue
+		}
+
+		discount, err := money.ParseRate(r.fields[1])
+		if err != nil {
+			fail("invalid discount %q for partner %q", r.fields[1], name)
+			continue
+		}
+		if discount < 0 || discount >= money.RateScale {
+			fail("discount %v for partner %q is outside [0,1)", discount, name)
+			continue
+		}
+
+		exemptField, found, fields := takeField(r.fields[2:], "exempt")
+		exempt := false
+		if found {
+			if exempt, err = strconv.ParseBool(strings.TrimSpace(exemptField)); err != nil {
+				fail("invalid exempt %q for partner %q", exemptField, name)
+				continue
+			}
+		}
+
+		valid, extra, err := parseValidity(fields)
+		if err != nil {
+			fail("%s for partner %q", err, name)
+			continue
+		}
+		if len(extra) > 0 {
+			fail("unexpected field %q for partner %q", extra[0], name)
+			continue
+		}
+
+		if line, ok := conflicting(seen[name], valid); ok {
+			if valid.dated() {
+				fail("validity of partner %q overlaps line %d", name, line)
+			} else {
+				fail("duplicate partner %q, first defined on line %d", name, line)
+			}
+			continue
+		}
+		seen[name] = append(seen[name], versionRef{validity: valid, line: r.line})
+
+		priceList := service.PriceList{
+			Partner:   name,
+			Discount:  discount,
+			TaxExempt: exempt,
+		}
+
+		partners[name] = append(partners[name], partnerVersion{validity: valid, priceList: priceList})
+	}
+
+	return partners, problems
+}
+
+// parsePriceLists reads rows of partner,code,discount,net into the terms of
+// partners, keyed by partner and then by product code. Each row sets either
+// a discount override or a fixed net price for one product, must name a
+// known partner and product, and may end with a validity written as
+// from=TIME and to=TIME. As with products, a partner and product may have a
+// single undated row, which applies in the gaps between dated rows whose
+// validities do not overlap.
+func parsePriceLists(path string, records []record, products map[string][]productVersion, partners map[string][]partnerVersion) (terms map[string]map[string][]termVersion, problems []LoadError) {
+	terms = make(map[string]map[string][]termVersion, 0)
+
+	seen := make(map[[2]string][]versionRef, 0)
+	for _, r := range skipHeader(records, priceListsHeader) {
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, LoadError{File: path, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if len(r.fields) < 4 {
+			fail("expected at least 4 fields (partner,code,discount,net), found %d", len(r.fields))
+			continue
+		}
+
+		name := strings.TrimSpace(r.fields[0])
+		code := strings.TrimSpace(r.fields[1])
+		if name == "" {
+			fail("blank partner name")
+			continue
+		}
+		if code == "" {
+			fail("blank product code")
+			continue
+		}
+
+		if _, ok := partners[name]; !ok {
+			fail("unknown partner %q", name)
+			continue
+		}
+		if _, ok := products[code]; !ok {
+			fail("unknown product code %q for partner %q", code, name)
+			continue
+		}
+
+		valid, extra, err := parseValidity(r.fields[4:])
+		if err != nil {
+			fail("%s for partner %q and product %q", err, name, code)
+			continue
+		}
+		if len(extra) > 0 {
+			fail("unexpected field %q for partner %q and product %q", extra[0], name, code)
+			continue
+		}
+
+		key := [2]string{name, code}
+		if line, ok := conflicting(seen[key], valid); ok {
+			if valid.dated() {
+				fail("validity of terms for partner %q and product %q overlaps line %d", name, code, line)
+			} else {
+				fail("duplicate terms for partner %q and product %q, first defined on line %d", name, code, line)
+			}
+			continue
+		}
+		seen[key] = append(seen[key], versionRef{validity: valid, line: r.line})
+
+		discountField := strings.TrimSpace(r.fields[2])
+		netField := strings.TrimSpace(r.fields[3])
+		if (discountField == "") == (netField == "") {
+			fail("expected either a discount or a net price for partner %q and product %q", name, code)
+			continue
+		}
+
+		term := termVersion{validity: valid}
+		if netField != "" {
+			price, err := money.Parse(netField)
+			if err != nil {
+				fail("invalid net price %q for partner %q and product %q", netField, name, code)
+				continue
+			}
+			if price < 0 {
+				fail("negative net price %v for partner %q and product %q", price, name, code)
+				continue
+			}
+
+			term.netPrice, term.net = price, true
+		} else {
+			discount, err := money.ParseRate(discountField)
+			if err != nil {
+				fail("invalid discount %q for partner %q and product %q", discountField, name, code)
+				continue
+			}
+			if discount < 0 || discount >= money.RateScale {
+				fail("discount %v for partner %q and product %q is outside [0,1)", discount, name, code)
+				continue
+			}
+
+			term.discount = discount
+		}
+
+		if terms[name] == nil {
+			terms[name] = make(map[string][]termVersion, 0)
+		}
+		terms[name][code] = append(terms[name][code], term)
+	}
+
+	return terms, problems
+}
+
+// parseFxRates reads rows of currency,minorUnits,rate, where rate is the
+// number of units of the currency that one unit of base buys. The base
+// currency itself must have a rate of 1. Amounts are held in hundredths, so
+// minorUnits is at most money.MinorUnits: currencies with three decimal
+// places, such as KWD and BHD, cannot be priced and are rejected.
+func parseFxRates(path string, records []record, base string) (rates map[string]service.FxRate, problems []LoadError) {
+	rates = make(map[string]service.FxRate, 0)
+
+	seen := make(map[string]int, 0)
+	for _, r := range skipHeader(records, fxRatesHeader) {
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, LoadError{File: path, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if len(r.fields) != 3 {
+			fail("expected 3 fields (currency,minorUnits,rate), found %d", len(r.fields))
+			continue
+		}
+
+		currency := strings.TrimSpace(r.fields[0])
+		if !validCurrency(currency) {
+			fail("invalid currency %q", currency)
+			continue
+		}
+
+		minorUnits, err := strconv.Atoi(strings.TrimSpace(r.fields[1]))
+		if err != nil {
+			fail("invalid minor units %q for currency %q", r.fields[1], currency)
+			continue
+		}
+		if minorUnits < 0 || minorUnits > money.MinorUnits {
+			fail("minor units %d for currency %q is outside [0,%d], the decimal places amounts are held to", minorUnits, currency, money.MinorUnits)
+			continue
+		}
+
+		rate, err := money.ParseRate(r.fields[2])
+		if err != nil {
+			fail("invalid rate %q for currency %q", r.fields[2], currency)
+			continue
+		}
+		if rate <= 0 {
+			fail("rate %v for currency %q must be positive", rate, currency)
+			continue
+		}
+		if currency == base && rate != money.RateScale {
+			fail("base currency %q must have a rate of 1, found %v", currency, rate)
+			continue
+		}
+
+		if line, ok := seen[currency]; ok {
+			fail("duplicate currency %q, first defined on line %d", currency, line)
+			continue
+		}
+		seen[currency] = r.line
+
+		rates[currency] = service.FxRate{Currency: currency, MinorUnits: minorUnits, Rate: rate}
+	}
+
+	return rates, problems
+}
+
+// parseTaxRates reads rows of region,class,rate, the rate at which products
+// of a tax class are taxed in a region.
+func parseTaxRates(path string, records []record) (rates map[string]map[string]money.Rate, problems []LoadError) {
+	rates = make(map[string]map[string]money.Rate, 0)
+
+	seen := make(map[[2]string]int, 0)
+	for _, r := range skipHeader(records, taxRatesHeader) {
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, LoadError{File: path, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if len(r.fields) != 3 {
+			fail("expected 3 fields (region,class,rate), found %d", len(r.fields))
+			continue
+		}
+
+		region := strings.TrimSpace(r.fields[0])
+		class := strings.TrimSpace(r.fields[1])
+		if region == "" {
+			fail("blank region")
+			continue
+		}
+		if class == "" {
+			fail("blank tax class for region %q", region)
+			continue
+		}
+
+		rate, err := money.ParseRate(r.fields[2])
+		if err != nil {
+			fail("invalid rate %q for region %q and class %q", r.fields[2], region, class)
+			continue
+		}
+		if rate < 0 || rate >= money.RateScale {
+			fail("rate %v for region %q and class %q is outside [0,1)", rate, region, class)
+			continue
+		}
+
+		key := [2]string{region, class}
+		if line, ok := seen[key]; ok {
+			fail("duplicate rate for region %q and class %q, first defined on line %d", region, class, line)
+			continue
+		}
+		seen[key] = r.line
+
+		if rates[region] == nil {
+			rates[region] = make(map[string]money.Rate, 0)
+		}
+		rates[region][class] = rate
+	}
+
+	return rates, problems
+}
+
+// parsePromotions reads rows of coupon,kind,value followed by optional
+// product=CODE, limit=N, from=TIME and to=TIME fields. The value is a rate
+// for percent promotions, BUY:FREE quantities for buyxgety promotions and an
+// amount for amount promotions, which also take min=AMOUNT and
+// currency=CODE.
+func parsePromotions(path string, records []record) (promotions map[string]service.Promotion, problems []LoadError) {
+	promotions = make(map[string]service.Promotion, 0)
+
+	seen := make(map[string]int, 0)
+	for _, r := range skipHeader(records, promotionsHeader) {
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, LoadError{File: path, Line: r.line, Msg: fmt.Sprintf(format, args...)})
+		}
+
+		if len(r.fields) < 3 {
+			fail("expected at least 3 fields (coupon,kind,value), found %d", len(r.fields))
+			continue
+		}
+
+		coupon := strings.TrimSpace(r.fields[0])
+		if coupon == "" {
+			fail("blank coupon")
+			continue
+		}
+
+		promotion := service.Promotion{
+			Coupon: coupon,
+			Kind:   service.PromotionKind(strings.TrimSpace(r.fields[1])),
+		}
+
+		value := strings.TrimSpace(r.fields[2])
+		fields := r.fields[3:]
+		var err error
+
+		switch promotion.Kind {
+		case service.PercentOff:
+			promotion.Rate, err = money.ParseRate(value)
+			if err != nil {
+				fail("invalid rate %q for coupon %q", value, coupon)
+				continue
+			}
+			if promotion.Rate <= 0 || promotion.Rate > money.RateScale {
+				fail("rate %v for coupon %q is outside (0,1]", promotion.Rate, coupon)
+				continue
+			}
+
+		case service.BuyXGetY:
+			buy, free, _ := strings.Cut(value, ":")
+			promotion.BuyQty, err = strconv.Atoi(buy)
+			if err == nil {
+				promotion.FreeQty, err = strconv.Atoi(free)
+			}
+			if err != nil || promotion.BuyQty < 1 || promotion.FreeQty < 1 {
+				fail("invalid quantities %q for coupon %q, expected BUY:FREE", value, coupon)
+				continue
+			}
+
+		case service.AmountOff:
+			promotion.Amount, err = money.Parse(value)
+			if err != nil {
+				fail("invalid amount %q for coupon %q", value, coupon)
+				continue
+			}
+			if promotion.Amount <= 0 {
+				fail("amount %v for coupon %q must be positive", promotion.Amount, coupon)
+				continue
+			}
+
+			var threshold, currency string
+			var found bool
+			if threshold, found, fields = takeField(fields, "min"); found {
+				if promotion.Threshold, err = money.Parse(threshold); err != nil || promotion.Threshold < 0 {
+					fail("invalid min %q for coupon %q", threshold, coupon)
+					continue
+				}
+			}
+			if currency, found, fields = takeField(fields, "currency"); found {
+				if !validCurrency(currency) {
+					fail("invalid currency %q for coupon %q", currency, coupon)
+					continue
+				}
+				promotion.Currency = currency
+			}
+
+		default:
+			fail("unknown kind %q for coupon %q, expected percent, buyxgety or amount", promotion.Kind, coupon)
+			continue
+		}
+
+		product, found, fields := takeField(fields, "product")
+		if found && strings.TrimSpace(product) == "" {
+			fail("blank product for coupon %q", coupon)
+			continue
+		}
+		promotion.Product = strings.TrimSpace(product)
+
+		limit, found, fields := takeField(fields, "limit")
+		if found {
+			if promotion.Limit, err = strconv.Atoi(limit); err != nil || promotion.Limit < 1 {
+				fail("invalid limit %q for coupon %q", limit, coupon)
+				continue
+			}
+		}
+
+		valid, extra, err := parseValidity(fields)
+		if err != nil {
+			fail("%s for coupon %q", err, coupon)
+			continue
+		}
+		if len(extra) > 0 {
+			fail("unexpected field %q for coupon %q", extra[0], coupon)
+			continue
+		}
+		promotion.From, promotion.To = valid.from, valid.to
+
+		if line, ok := seen[coupon]; ok {
+			fail("duplicate coupon %q, first defined on line %d", coupon, line)
+			continue
+		}
+		seen[coupon] = r.line
+
+		promotions[coupon] = promotion
+	}
+
+	return promotions, problems
+}
+
+// validCurrency reports whether code looks like an ISO 4217 code: three
+// upper case letters.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseValidity takes the from=TIME and to=TIME fields out of fields and
+// returns the rest. Times are RFC 3339 timestamps or dates, read as UTC.
+func parseValidity(fields []string) (valid validity, rest []string, err error) {
+	for _, field := range fields {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			rest = append(rest, field)
+			continue
+		}
+		if key != "from" && key != "to" {
+			return validity{}, nil, fmt.Errorf("unknown field %q, expected from or to", key)
+		}
+
+		t, err := parseTime(value)
+		if err != nil {
+			return validity{}, nil, fmt.Errorf("invalid %s time %q", key, value)
+		}
+		if key == "from" {
+			valid.from = t
+		} else {
+			valid.to = t
+		}
+	}
+
+	if !valid.from.IsZero() && !valid.to.IsZero() && !valid.from.Before(valid.to) {
+		return validity{}, nil, fmt.Errorf("validity from %s is not before to %s", valid.from.Format(time.RFC3339), valid.to.Format(time.RFC3339))
+	}
+
+	return valid, rest, nil
+}
+
+func parseTime(value string) (t time.Time, err error) {
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
+
+// takeField takes the first key=value field for key out of fields and
+// returns its value and the remaining fields.
+func takeField(fields []string, key string) (value string, found bool, rest []string) {
+	for _, field := range fields {
+		k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if ok && k == key && !found {
+			value, found = v, true
+			continue
+		}
+
+		rest = append(rest, field)
+	}
+
+	return value, found, rest
+}
+
+// skipHeader drops the first record when it matches the optional header row.
+func skipHeader(records []record, header []string) []record {
+	if len(records) == 0 || len(records[0].fields) != len(header) {
+		return records
+	}
+
+	for i, field := range records[0].fields {
+		if !strings.EqualFold(strings.TrimSpace(field), header[i]) {
+			return records
+		}
+	}
+
+	return records[1:]
+}
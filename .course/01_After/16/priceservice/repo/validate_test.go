@@ -0,0 +1,209 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewProductRepo_Validation(t *testing.T) {
+	tests := []struct {
+		products   string
+		partners   string
+		priceLists string
+		problems   []string
+	}{
+		{
+			products: "code,price\naaa111,12.99\n",
+			partners: "name,discount\nsuperstore,0.15\n",
+			problems: nil,
+		},
+		{
+			products: "aaa111\nbbb222,2.90\n",
+			partners: "superstore,0.15\n",
+			problems: []string{"products.csv:1: expected at least 2 fields (code,price), found 1"},
+		},
+		{
+			products: "aaa111,12,99\nbbb222,2.90,10\nccc333,4.00,10:3.50,5:3.00\nddd444,1.00,1:0.90\neee555,1.00,x:0.90\nfff666,1.00,10:-1\n",
+			partners: "superstore,0.15\n",
+			problems: []string{
+				"products.csv:1: invalid tier \"99\", expected minQty:price for product \"aaa111\"",
+				"products.csv:2: invalid tier \"10\", expected minQty:price for product \"bbb222\"",
+				"products.csv:3: tier quantity 5 must be greater than 10 for product \"ccc333\"",
+				"products.csv:4: tier quantity 1 must be greater than 1 for product \"ddd444\"",
+				"products.csv:5: invalid tier quantity \"x\" for product \"eee555\"",
+				"products.csv:6: negative tier price -1.00 for product \"fff666\"",
+			},
+		},
+		{
+			products: "aaa111,12.99\nbbb222,abc\nccc333,-1\n,4.00\naaa111,13.00\n",
+			partners: "superstore,0.15\n",
+			problems: []string{
+				"products.csv:2: invalid price \"abc\" for product \"bbb222\"",
+				"products.csv:3: negative price -1.00 for product \"ccc333\"",
+				"products.csv:4: blank product code",
+				"products.csv:5: duplicate product code \"aaa111\", first defined on line 1",
+			},
+		},
+		{
+			products: "aaa111,12.99\n",
+			partners: "superstore,1\njoesbakery,-0.1\nsuperstore,0.1\nsuperstore,0.2\n,0.2\n",
+			problems: []string{
+				"partners.csv:1: discount 1 for partner \"superstore\" is outside [0,1)",
+				"partners.csv:2: discount -0.1 for partner \"joesbakery\" is outside [0,1)",
+				"partners.csv:4: duplicate partner \"superstore\", first defined on line 3",
+				"partners.csv:5: blank partner name",
+			},
+		},
+		{
+			products: "aaa111,12.99\n" +
+				"aaa111,11.99,from=2025-01-01,to=2025-02-01\n" +
+				"aaa111,10.99,from=2025-01-15,to=2025-03-01\n" +
+				"aaa111,9.99,from=2025-02-01\n" +
+				"aaa111,8.99,to=2024-06-01\n" +
+				"aaa111,7.99,to=2025-01-10\n" +
+				"bbb222,2.90,from=2025-03-01,to=2025-02-01\n" +
+				"bbb222,2.90,from=yesterday\n" +
+				"bbb222,2.90,until=2025-01-01\n" +
+				"bbb222,2.90,10:2.50,from=2025-01-01T00:00:00Z\n",
+			partners: "superstore,0.15\n" +
+				"superstore,0.20,from=2025-01-01,to=2025-02-01\n" +
+				"superstore,0.25,from=2025-01-31\n" +
+				"joesbakery,0.10,extra\n" +
+				"joesbakery,0.10,to=2025-01-01\n",
+			problems: []string{
+				"products.csv:3: validity of product \"aaa111\" overlaps line 2",
+				"products.csv:6: validity of product \"aaa111\" overlaps line 2",
+				"products.csv:7: validity from 2025-03-01T00:00:00Z is not before to 2025-02-01T00:00:00Z for product \"bbb222\"",
+				"products.csv:8: invalid from time \"yesterday\" for product \"bbb222\"",
+				"products.csv:9: unknown field \"until\", expected from or to for product \"bbb222\"",
+				"partners.csv:3: validity of partner \"superstore\" overlaps line 2",
+				"partners.csv:4: unexpected field \"extra\" for partner \"joesbakery\"",
+			},
+		},
+		{
+			products: "aaa111,12.99,currency=EUR,10:11.50\n" +
+				"bbb222,2.90,currency=eur\n" +
+				"ccc333,22.50,currency=\n" +
+				"ddd444,1500,from=2025-01-01,currency=JPY\n",
+			partners: "superstore,0.15\n",
+			problems: []string{
+				"products.csv:2: invalid currency \"eur\" for product \"bbb222\"",
+				"products.csv:3: invalid currency \"\" for product \"ccc333\"",
+			},
+		},
+		{
+			products: "aaa111,12.99,tax=reduced\nbbb222,2.90,tax=\nccc333,22.50,tax=zero,currency=EUR,10:20.00\n",
+			partners: "superstore,0.15,exempt=true\njoesbakery,0.10,exempt=maybe\ncharity,0.05,exempt=false,from=2025-01-01\n",
+			problems: []string{
+				"products.csv:2: blank tax class for product \"bbb222\"",
+				"partners.csv:2: invalid exempt \"maybe\" for partner \"joesbakery\"",
+			},
+		},
+		{
+			products:   "aaa111,12.99\nbbb222,2.90\n",
+			partners:   "superstore,0.15\n",
+			priceLists: "partner,code,discount,net\nsuperstore,aaa111,0.20,\nsuperstore,bbb222,,2.50\n",
+			problems:   nil,
+		},
+		{
+			products: "aaa111,12.99\nbbb222,2.90\nccc333,22.50\nddd444,1.00\neee555,1.00\nfff666,1.00\n",
+			partners: "superstore,0.15\njoesbakery,0.10\n",
+			priceLists: "superstore,aaa111,0.20\n" +
+				",aaa111,0.20,\n" +
+				"superstore,,0.20,\n" +
+				"jesscafe,aaa111,0.20,\n" +
+				"superstore,xyz123,0.20,\n" +
+				"superstore,aaa111,0.20,\n" +
+				"superstore,aaa111,,11.00\n" +
+				"superstore,bbb222,,\n" +
+				"superstore,ccc333,0.10,20.00\n" +
+				"superstore,ddd444,abc,\n" +
+				"superstore,eee555,1,\n" +
+				"superstore,fff666,,abc\n" +
+				"joesbakery,aaa111,,-2.50\n",
+			problems: []string{
+				"pricelists.csv:1: expected at least 4 fields (partner,code,discount,net), found 3",
+				"pricelists.csv:2: blank partner name",
+				"pricelists.csv:3: blank product code",
+				"pricelists.csv:4: unknown partner \"jesscafe\"",
+				"pricelists.csv:5: unknown product code \"xyz123\" for partner \"superstore\"",
+				"pricelists.csv:7: duplicate terms for partner \"superstore\" and product \"aaa111\", first defined on line 6",
+				"pricelists.csv:8: expected either a discount or a net price for partner \"superstore\" and product \"bbb222\"",
+				"pricelists.csv:9: expected either a discount or a net price for partner \"superstore\" and product \"ccc333\"",
+				"pricelists.csv:10: invalid discount \"abc\" for partner \"superstore\" and product \"ddd444\"",
+				"pricelists.csv:11: discount 1 for partner \"superstore\" and product \"eee555\" is outside [0,1)",
+				"pricelists.csv:12: invalid net price \"abc\" for partner \"superstore\" and product \"fff666\"",
+				"pricelists.csv:13: negative net price -2.50 for partner \"joesbakery\" and product \"aaa111\"",
+			},
+		},
+		{
+			products: "aaa111,12.99\nbbb222,2.90\n",
+			partners: "superstore,0.15\n",
+			priceLists: "superstore,aaa111,0.20,\n" +
+				"superstore,aaa111,,11.00,from=2025-01-01,to=2025-02-01\n" +
+				"superstore,aaa111,0.25,,from=2025-01-15\n" +
+				"superstore,aaa111,0.30,,from=2025-02-01\n" +
+				"superstore,bbb222,,2.50,from=2025-03-01,to=2025-02-01\n" +
+				"superstore,bbb222,,2.50,until=2025-01-01\n" +
+				"superstore,bbb222,,2.50,extra\n" +
+				"superstore,bbb222,,2.40,to=2025-01-01\n",
+			problems: []string{
+				"pricelists.csv:3: validity of terms for partner \"superstore\" and product \"aaa111\" overlaps line 2",
+				"pricelists.csv:5: validity from 2025-03-01T00:00:00Z is not before to 2025-02-01T00:00:00Z for partner \"superstore\" and product \"bbb222\"",
+				"pricelists.csv:6: unknown field \"until\", expected from or to for partner \"superstore\" and product \"bbb222\"",
+				"pricelists.csv:7: unexpected field \"extra\" for partner \"superstore\" and product \"bbb222\"",
+			},
+		},
+	}
+
+	for id, test := range tests {
+		productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), test.products, test.partners, test.priceLists)
+
+		pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+		assert.True(t, pr != nil, "~2|Test #%d expected a usable repo~", id)
+
+		var actual []string
+		var catalogErr *CatalogError
+		if errors.As(err, &catalogErr) {
+			for _, problem := range catalogErr.Problems {
+				actual = append(actual, fmt.Sprintf("%s:%d: %s", filepath.Base(problem.File), problem.Line, problem.Msg))
+			}
+		}
+
+		assert.True(t, len(test.problems) == len(actual), "~2|Test #%d expected %d problems, not %d: %v~", id, len(test.problems), len(actual), actual)
+		for i := range actual {
+			if i < len(test.problems) {
+				assert.True(t, test.problems[i] == actual[i], "~2|Test #%d expected problem: %s, not problem %s~", id, test.problems[i], actual[i])
+			}
+		}
+	}
+}
+
+func Test_NewProductRepo_SkipsInvalidRows(t *testing.T) {
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "aaa111,12.99\nbbb222,abc\n", "superstore,0.15\n", "")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, false)
+	assert.True(t, err != nil, "~2|Test expected a catalog error~")
+
+	product, found := pr.FetchProduct("aaa111", time.Now())
+	assert.True(t, found && product.Tiers[0].Price == money.MustParse("12.99"), "~2|Test expected price: 12.99, not product %v~", product)
+
+	_, found = pr.FetchProduct("bbb222", time.Now())
+	assert.True(t, !found, "~2|Test expected code bbb222 to be rejected~")
+}
+
+func Test_NewProductRepo_StrictRefusesInvalidRows(t *testing.T) {
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, t.TempDir(), "aaa111,12.99\nbbb222,abc\n", "superstore,0.15\n", "")
+
+	pr, err := NewProductRepo(productsPath, partnersPath, priceListsPath, true)
+
+	var catalogErr *CatalogError
+	assert.True(t, errors.As(err, &catalogErr), "~2|Test expected a catalog error, not error %s~", err)
+	assert.True(t, pr == nil, "~2|Test expected no repo~")
+}
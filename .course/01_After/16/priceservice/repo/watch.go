@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+)
+
+type fingerprint struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+func fingerprintFile(path string) (fp fingerprint, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fp, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fp, err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fp, err
+	}
+
+	fp.modTime = info.ModTime()
+	fp.size = info.Size()
+	copy(fp.sum[:], h.Sum(nil))
+
+	return fp, nil
+}
+
+type catalogWatcher struct {
+	logger     log.Logger
+	version    metrics.Gauge
+	lastReload metrics.Gauge
+	repo       *productRepo
+	rejected   map[string]fingerprint
+}
+
+func NewCatalogWatcher(logger log.Logger, version metrics.Gauge, lastReload metrics.Gauge, pr *productRepo) (cw *catalogWatcher) {
+	cw = &catalogWatcher{
+		logger:     logger,
+		version:    version,
+		lastReload: lastReload,
+		repo:       pr,
+	}
+
+	cw.observe()
+
+	return
+}
+
+// Run polls the catalog source files every interval until ctx is done.
+func (cw *catalogWatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cw.Check()
+		}
+	}
+}
+
+// Check reloads the catalog when a source file has changed since it was
+// last loaded, or since it was last rejected, so a bad file is tried and
+// logged once rather than on every tick. A file only counts as changed when
+// its checksum differs, so touching a file without editing it does not bump
+// the version.
+func (cw *catalogWatcher) Check() (reloaded bool, err error) {
+	if !cw.changed() {
+		return false, nil
+	}
+
+	defer func(begin time.Time) {
+		_ = cw.logger.Log(
+			"method", "ReloadCatalog",
+			"version", cw.repo.Version(),
+			"error", err,
+			"duration", time.Since(begin),
+		)
+	}(time.Now())
+
+	// The files are fingerprinted before they are read, so an edit made
+	// while reloading counts as a change on the next tick.
+	attempted := cw.fingerprints()
+
+	reloaded, err = cw.repo.Reload()
+	if !reloaded {
+		cw.rejected = attempted
+		return false, err
+	}
+
+	cw.rejected = nil
+	cw.observe()
+
+	return true, err
+}
+
+// changed reports whether a source file differs from the catalog loaded,
+// or from the files last rejected. A file missing when it was rejected is
+// unchanged while it stays missing.
+func (cw *catalogWatcher) changed() bool {
+	seen := cw.rejected
+	if seen == nil {
+		seen = cw.repo.current().sources
+	}
+
+	for path, last := range seen {
+		info, err := os.Stat(path)
+		if err != nil {
+			if last == (fingerprint{}) {
+				continue
+			}
+			return true
+		}
+		if info.ModTime().Equal(last.modTime) && info.Size() == last.size {
+			continue
+		}
+
+		fp, err := fingerprintFile(path)
+		if err != nil || fp.sum != last.sum {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fingerprints takes the fingerprint of every source file, leaving a zero
+// fingerprint for a file that cannot be read.
+func (cw *catalogWatcher) fingerprints() (fps map[string]fingerprint) {
+	sources := cw.repo.current().sources
+
+	fps = make(map[string]fingerprint, len(sources))
+	for path := range sources {
+		fps[path], _ = fingerprintFile(path)
+	}
+
+	return fps
+}
+
+func (cw *catalogWatcher) observe() {
+	c := cw.repo.current()
+
+	cw.version.Set(float64(c.version))
+	cw.lastReload.Set(float64(c.loadedAt.Unix()))
+}
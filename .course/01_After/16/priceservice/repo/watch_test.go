@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockLogger struct {
+	logged int
+}
+
+func (ml *MockLogger) Log(keyvals ...interface{}) error {
+	ml.logged++
+	return nil
+}
+
+type MockGauge struct {
+	result float64
+}
+
+func (mg *MockGauge) Set(val float64) {
+	mg.result = val
+}
+
+func (mg *MockGauge) Add(val float64) {
+	mg.result += val
+}
+
+func (mg *MockGauge) With(lvs ...string) metrics.Gauge {
+	return mg
+}
+
+func (mg *MockGauge) Result() float64 {
+	return mg.result
+}
+
+func Test_CatalogWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	productsPath, partnersPath, priceListsPath := writeCatalog(t, dir, "aaa111,12.99\n", "superstore,0.15\n", "")
+
+	pr, _ := NewProductRepo(productsPath, partnersPath, priceListsPath, true)
+
+	version := new(MockGauge)
+	lastReload := new(MockGauge)
+	logger := new(MockLogger)
+	cw := NewCatalogWatcher(logger, version, lastReload, pr)
+
+	assert.True(t, version.Result() == 1.0, "~2|Test version expected: 1, not: \"%.1f\"~", version.Result())
+	assert.True(t, lastReload.Result() > 0.0, "~2|Test last reload expected value greater than 0.0~")
+
+	tests := []struct {
+		products string
+		touch    bool
+		reloaded bool
+		failed   bool
+		logged   int
+		version  float64
+		price    money.Amount
+	}{
+		{
+			products: "",
+			reloaded: false,
+			logged:   0,
+			version:  1.0,
+			price:    money.MustParse("12.99"),
+		},
+		{
+			products: "aaa111,12.99\n",
+			touch:    true,
+			reloaded: false,
+			logged:   0,
+			version:  1.0,
+			price:    money.MustParse("12.99"),
+		},
+		{
+			products: "aaa111,11.50\n",
+			reloaded: true,
+			logged:   1,
+			version:  2.0,
+			price:    money.MustParse("11.50"),
+		},
+		{
+			products: "aaa111\n",
+			reloaded: false,
+			failed:   true,
+			logged:   2,
+			version:  2.0,
+			price:    money.MustParse("11.50"),
+		},
+		{
+			products: "",
+			reloaded: false,
+			logged:   2,
+			version:  2.0,
+			price:    money.MustParse("11.50"),
+		},
+		{
+			products: "aaa111,10.99\n",
+			reloaded: true,
+			logged:   3,
+			version:  3.0,
+			price:    money.MustParse("10.99"),
+		},
+	}
+
+	for id, test := range tests {
+		if test.products != "" {
+			writeCatalog(t, dir, test.products, "superstore,0.15\n", "")
+		}
+		if test.touch {
+			later := time.Now().Add(time.Duration(id+1) * time.Minute)
+			os.Chtimes(productsPath, later, later)
+		}
+
+		reloaded, err := cw.Check()
+		product, _ := pr.FetchProduct("aaa111", time.Now())
+
+		assert.True(t, test.reloaded == reloaded, "~2|Test #%d expected reloaded: %t, not reloaded %t~", id, test.reloaded, reloaded)
+		assert.True(t, test.failed == (err != nil), "~2|Test #%d expected failure: %t, not error %s~", id, test.failed, fmt.Sprint(err))
+		assert.True(t, test.logged == logger.logged, "~2|Test #%d expected logged reloads: %d, not: %d~", id, test.logged, logger.logged)
+		assert.True(t, test.version == version.Result(), "~2|Test #%d version expected: %.1f, not: \"%.1f\"~", id, test.version, version.Result())
+		assert.True(t, test.price == product.Tiers[0].Price, "~2|Test #%d expected price: %s, not price %s~", id, test.price, product.Tiers[0].Price)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// CatalogRepo is the lookup API of the in-memory CSV repo, which can only
+// report whether a code or partner exists.
+type CatalogRepo interface {
+	FetchProduct(code string, asOf time.Time) (product Product, found bool)
+	FetchPriceList(partner string, asOf time.Time) (priceList PriceList, found bool)
+}
+
+type catalogRepoAdapter struct {
+	repo CatalogRepo
+}
+
+// NewCatalogRepoAdapter lets a CatalogRepo be used as a ProductRepo.
+func NewCatalogRepoAdapter(cr CatalogRepo) (cra *catalogRepoAdapter) {
+	cra = &catalogRepoAdapter{
+		repo: cr,
+	}
+
+	return
+}
+
+func (cra *catalogRepoAdapter) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
+
+	product, found := cra.repo.FetchProduct(code, asOf)
+	if !found {
+		return Product{}, ErrRecordNotFound
+	}
+
+	return product, nil
+}
+
+func (cra *catalogRepoAdapter) FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList PriceList, err error) {
+	if err := ctx.Err(); err != nil {
+		return PriceList{}, err
+	}
+
+	priceList, found := cra.repo.FetchPriceList(partner, asOf)
+	if !found {
+		return PriceList{}, ErrRecordNotFound
+	}
+
+	return priceList, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockCatalogRepo struct{}
+
+func (MockCatalogRepo) FetchProduct(code string, asOf time.Time) (product Product, found bool) {
+	if code != "aaa111" {
+		return Product{}, false
+	}
+
+	return NewProduct(code, money.MustParse("12.99")), true
+}
+
+func (MockCatalogRepo) FetchPriceList(partner string, asOf time.Time) (priceList PriceList, found bool) {
+	if partner != "superstore" {
+		return PriceList{}, false
+	}
+
+	return PriceList{Partner: partner, Discount: money.MustParseRate("0.15")}, true
+}
+
+func Test_CatalogRepoAdapter(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx   context.Context
+		code  string
+		err   error
+		price money.Amount
+	}{
+		{
+			ctx:   context.Background(),
+			code:  "aaa111",
+			err:   nil,
+			price: money.MustParse("12.99"),
+		},
+		{
+			ctx:   context.Background(),
+			code:  "fff000",
+			err:   ErrRecordNotFound,
+			price: 0,
+		},
+		{
+			ctx:   cancelled,
+			code:  "aaa111",
+			err:   context.Canceled,
+			price: 0,
+		},
+	}
+
+	repo := NewCatalogRepoAdapter(new(MockCatalogRepo))
+
+	for id, test := range tests {
+		product, err := repo.FetchProduct(test.ctx, test.code, time.Now())
+		price, _ := product.TierFor(1)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.price == price.Price, "~2|Test #%d expected price: %s, not price %s~", id, test.price, price.Price)
+	}
+
+	priceList, err := repo.FetchPriceList(context.Background(), "joesbakery", time.Now())
+	assert.True(t, err == ErrRecordNotFound, "~2|Test expected error: %s, not error %s~", ErrRecordNotFound, err)
+	assert.True(t, priceList.Discount == 0, "~2|Test expected discount: 0, not discount %s~", priceList.Discount)
+}
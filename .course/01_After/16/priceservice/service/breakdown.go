@@ -0,0 +1,52 @@
+package service
+
+import "github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+
+// Breakdown shows how the total of a price was worked out, so that it can
+// be checked by hand. UnitPrice is the tier or net unit price in the
+// product's currency, and UnitSaving is what Discount takes off it.
+// ExactTotal is UnitPrice * Qty * (1 - Discount) * FxRate before rounding;
+// Adjustment is what Rounding added to it to give LineTotal. CouponSaving is
+// what Coupon, if any, took off LineTotal to give Total, the total of the
+// price before tax. CatalogVersion is the version of the catalog the
+// product was read from, or 0 when the repo does not version its catalog.
+type Breakdown struct {
+	UnitPrice      money.Amount
+	Discount       money.Rate
+	UnitSaving     money.Decimal
+	Qty            int
+	FxRate         money.Rate
+	ExactTotal     money.Decimal
+	Rounding       money.Rounding
+	Adjustment     money.Decimal
+	LineTotal      money.Amount
+	Coupon         string
+	CouponSaving   money.Amount
+	Total          money.Amount
+	CatalogVersion int
+}
+
+// breakdown explains a line total of qty units at unitPrice, less discount,
+// converted at fxRate and rounded to lineTotal, less the saving of
+// promotion.
+func (ps *pricingService) breakdown(product Product, unitPrice money.Amount, qty int, discount money.Rate, fxRate money.Rate, lineTotal money.Amount, promotion AppliedPromotion) (b *Breakdown) {
+	exact := money.Exact(unitPrice, qty, discount, fxRate)
+
+	b = &Breakdown{
+		UnitPrice:      unitPrice,
+		Discount:       discount,
+		UnitSaving:     unitPrice.Mul(discount),
+		Qty:            qty,
+		FxRate:         fxRate,
+		ExactTotal:     exact,
+		Rounding:       ps.rounding,
+		Adjustment:     lineTotal.Decimal().Sub(exact),
+		LineTotal:      lineTotal,
+		Coupon:         promotion.Coupon,
+		CouponSaving:   promotion.Saving,
+		Total:          lineTotal.Sub(promotion.Saving),
+		CatalogVersion: product.CatalogVersion,
+	}
+
+	return b
+}
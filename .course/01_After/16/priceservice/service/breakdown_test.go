@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockVersionedProductRepo reads the mock catalog as version 7.
+type MockVersionedProductRepo struct {
+	MockProductRepo
+}
+
+func (m MockVersionedProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	product, err = m.MockProductRepo.FetchProduct(ctx, code, asOf)
+	product.CatalogVersion = 7
+
+	return product, err
+}
+
+func Test_GetTotal_Explain(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner    string
+		code       string
+		qty        int
+		currency   string
+		coupon     string
+		explain    bool
+		total      money.Amount
+		unitPrice  money.Amount
+		discount   money.Rate
+		unitSaving string
+		fxRate     money.Rate
+		exactTotal string
+		adjustment string
+		lineTotal  money.Amount
+		saving     money.Amount
+	}{
+		{code: "aaa111", qty: 15, total: money.MustParse("194.85")},
+		{code: "aaa111", qty: 15, explain: true, total: money.MustParse("194.85"), unitPrice: money.MustParse("12.99"), unitSaving: "0.00", fxRate: money.RateScale, exactTotal: "194.85", adjustment: "0.00", lineTotal: money.MustParse("194.85")},
+		{partner: "superstore", code: "aaa111", qty: 15, explain: true, total: money.MustParse("175.37"), unitPrice: money.MustParse("12.99"), discount: money.MustParseRate("0.10"), unitSaving: "1.299", fxRate: money.RateScale, exactTotal: "175.365", adjustment: "0.005", lineTotal: money.MustParse("175.37")},
+		{partner: "superstore", code: "aaa111", qty: 15, currency: "EUR", explain: true, total: money.MustParse("161.34"), unitPrice: money.MustParse("12.99"), discount: money.MustParseRate("0.10"), unitSaving: "1.299", fxRate: money.MustParseRate("0.92"), exactTotal: "161.3358", adjustment: "0.0042", lineTotal: money.MustParse("161.34")},
+		{partner: "cornershop", code: "bbb222", qty: 15, explain: true, total: money.MustParse("37.50"), unitPrice: money.MustParse("2.50"), unitSaving: "0.00", fxRate: money.RateScale, exactTotal: "37.50", adjustment: "0.00", lineTotal: money.MustParse("37.50")},
+		{code: "aaa111", qty: 15, coupon: "SAVE10", explain: true, total: money.MustParse("175.36"), unitPrice: money.MustParse("12.99"), unitSaving: "0.00", fxRate: money.RateScale, exactTotal: "194.85", adjustment: "0.00", lineTotal: money.MustParse("194.85"), saving: money.MustParse("19.49")},
+	}
+
+	priceService := NewPricingService(new(MockVersionedProductRepo), new(MockPromotionRepo), mockFxTable, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		var price Price
+		var err error
+		if test.partner == "" {
+			price, err = priceService.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{PriceOptions: PriceOptions{Currency: test.currency, Explain: test.explain}, Coupon: test.coupon})
+		} else {
+			price, err = priceService.GetWholesaleTotal(ctx, test.partner, test.code, test.qty, PriceOptions{Currency: test.currency, Explain: test.explain})
+		}
+
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+		assert.True(t, test.explain == (price.Breakdown != nil), "~2|Test #%d expected breakdown: %t, not breakdown %+v~", id, test.explain, price.Breakdown)
+		if price.Breakdown == nil {
+			continue
+		}
+
+		b := price.Breakdown
+		assert.True(t, test.unitPrice == b.UnitPrice, "~2|Test #%d expected unit price: %s, not unit price %s~", id, test.unitPrice, b.UnitPrice)
+		assert.True(t, test.discount == b.Discount, "~2|Test #%d expected discount: %s, not discount %s~", id, test.discount, b.Discount)
+		assert.True(t, test.unitSaving == b.UnitSaving.String(), "~2|Test #%d expected unit saving: %s, not unit saving %s~", id, test.unitSaving, b.UnitSaving)
+		assert.True(t, test.qty == b.Qty, "~2|Test #%d expected qty: %d, not qty %d~", id, test.qty, b.Qty)
+		assert.True(t, test.fxRate == b.FxRate, "~2|Test #%d expected fx rate: %s, not fx rate %s~", id, test.fxRate, b.FxRate)
+		assert.True(t, test.exactTotal == b.ExactTotal.String(), "~2|Test #%d expected exact total: %s, not exact total %s~", id, test.exactTotal, b.ExactTotal)
+		assert.True(t, money.HalfUp == b.Rounding, "~2|Test #%d expected rounding: %s, not rounding %s~", id, money.HalfUp, b.Rounding)
+		assert.True(t, test.adjustment == b.Adjustment.String(), "~2|Test #%d expected adjustment: %s, not adjustment %s~", id, test.adjustment, b.Adjustment)
+		assert.True(t, test.lineTotal == b.LineTotal, "~2|Test #%d expected line total: %s, not line total %s~", id, test.lineTotal, b.LineTotal)
+		assert.True(t, test.coupon == b.Coupon, "~2|Test #%d expected coupon: %s, not coupon %s~", id, test.coupon, b.Coupon)
+		assert.True(t, test.saving == b.CouponSaving, "~2|Test #%d expected coupon saving: %s, not coupon saving %s~", id, test.saving, b.CouponSaving)
+		assert.True(t, price.Total == b.Total, "~2|Test #%d expected breakdown total: %s, not breakdown total %s~", id, price.Total, b.Total)
+		assert.True(t, b.CatalogVersion == 7, "~2|Test #%d expected catalog version: 7, not catalog version %d~", id, b.CatalogVersion)
+	}
+}
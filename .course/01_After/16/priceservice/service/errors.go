@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// Code is a stable, machine-readable name for a class of pricing error.
+// Clients should match on the code rather than on the message, which is
+// meant for people and may change.
+type Code string
+
+const (
+	INVALID_REQUEST       Code = "INVALID_REQUEST"
+	INVALID_PARTNER       Code = "INVALID_PARTNER"
+	INVALID_CODE          Code = "INVALID_CODE"
+	INVALID_QTY           Code = "INVALID_QTY"
+	EMPTY_QUOTE           Code = "EMPTY_QUOTE"
+	PARTNER_NOT_FOUND     Code = "PARTNER_NOT_FOUND"
+	CODE_NOT_FOUND        Code = "CODE_NOT_FOUND"
+	NO_PRICE_TIER         Code = "NO_PRICE_TIER"
+	UNKNOWN_CURRENCY      Code = "UNKNOWN_CURRENCY"
+	UNKNOWN_REGION        Code = "UNKNOWN_REGION"
+	NO_TAX_RATE           Code = "NO_TAX_RATE"
+	UNKNOWN_COUPON        Code = "UNKNOWN_COUPON"
+	COUPON_EXPIRED        Code = "COUPON_EXPIRED"
+	COUPON_NOT_STARTED    Code = "COUPON_NOT_STARTED"
+	COUPON_USED_UP        Code = "COUPON_USED_UP"
+	COUPON_NOT_APPLICABLE Code = "COUPON_NOT_APPLICABLE"
+	REPO_UNAVAILABLE      Code = "REPO_UNAVAILABLE"
+	CANCELED              Code = "CANCELED"
+	TIMEOUT               Code = "TIMEOUT"
+	INTERNAL              Code = "INTERNAL"
+)
+
+// Error is a pricing error with a Code. The service errors are all of this
+// type, so they can be told apart by errors.Is, or by their code once they
+// have crossed the wire.
+type Error struct {
+	Code Code
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// ErrorCode returns the code of the first *Error in the chain of err,
+// CANCELED or TIMEOUT when the request's context ended first, and INTERNAL
+// otherwise.
+func ErrorCode(err error) (code Code) {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return CANCELED
+	case errors.Is(err, context.DeadlineExceeded):
+		return TIMEOUT
+	}
+
+	return INTERNAL
+}
+
+// Lookup returns the service error with code, so that an error that has
+// crossed the wire as a code can be matched by errors.Is again. It reports
+// false for CANCELED, TIMEOUT and INTERNAL and for codes it does not know.
+func Lookup(code Code) (err *Error, found bool) {
+	for _, err := range []*Error{
+		ErrInvalidPartner, ErrPartnerNotFound, ErrInvalidCode, ErrCodeNotFound, ErrInvalidQty,
+		ErrRepoUnavailable, ErrEmptyQuote, ErrNoPriceTier, ErrUnknownCurrency, ErrUnknownRegion, ErrNoTaxRate,
+		ErrUnknownCoupon, ErrExpiredCoupon, ErrCouponNotStarted, ErrCouponUsedUp, ErrCouponNotApplicable,
+	} {
+		if err.Code == code {
+			return err, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ErrorCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected Code
+	}{
+		{err: ErrInvalidQty, expected: INVALID_QTY},
+		{err: ErrPartnerNotFound, expected: PARTNER_NOT_FOUND},
+		{err: &CouponError{Coupon: "OLD", Err: ErrExpiredCoupon}, expected: COUPON_EXPIRED},
+		{err: &CurrencyError{Currency: "GBP"}, expected: UNKNOWN_CURRENCY},
+		{err: fmt.Errorf("line 2: %w", ErrNoPriceTier), expected: NO_PRICE_TIER},
+		{err: repoError(errors.New("connection refused"), ErrCodeNotFound), expected: REPO_UNAVAILABLE},
+		{err: repoError(ErrRecordNotFound, ErrCodeNotFound), expected: CODE_NOT_FOUND},
+		{err: repoError(context.Canceled, ErrCodeNotFound), expected: CANCELED},
+		{err: repoError(fmt.Errorf("query: %w", context.DeadlineExceeded), ErrCodeNotFound), expected: TIMEOUT},
+		{err: errors.New("boom"), expected: INTERNAL},
+	}
+
+	for id, test := range tests {
+		actual := ErrorCode(test.err)
+		assert.True(t, test.expected == actual, "~2|Test #%d expected code: %s, not code %s~", id, test.expected, actual)
+	}
+}
+
+func Test_Lookup(t *testing.T) {
+	tests := []struct {
+		code     Code
+		expected *Error
+	}{
+		{code: INVALID_QTY, expected: ErrInvalidQty},
+		{code: CODE_NOT_FOUND, expected: ErrCodeNotFound},
+		{code: COUPON_USED_UP, expected: ErrCouponUsedUp},
+		{code: REPO_UNAVAILABLE, expected: ErrRepoUnavailable},
+		{code: CANCELED},
+		{code: INTERNAL},
+		{code: "SOMETHING_NEW"},
+	}
+
+	for id, test := range tests {
+		actual, found := Lookup(test.code)
+		assert.True(t, test.expected == actual, "~2|Test #%d expected error: %v, not error %v~", id, test.expected, actual)
+		assert.True(t, (test.expected != nil) == found, "~2|Test #%d expected found: %t, not found %t~", id, test.expected != nil, found)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+)
+
+// FxRate is the number of units of Currency that one unit of the base
+// currency buys. Amounts in Currency are rounded to MinorUnits decimal
+// places, which is at most money.MinorUnits.
+type FxRate struct {
+	Currency   string
+	MinorUnits int
+	Rate       money.Rate
+}
+
+// FxTable holds exchange rates against Base, whose own rate is 1. Products
+// without a currency are priced in Base.
+//
+// The zero FxTable knows no currencies and leaves every price in the
+// currency of its product.
+type FxTable struct {
+	Base  string
+	Rates map[string]FxRate
+}
+
+// Conversion is how an amount is brought into Currency: multiplied by Rate
+// and rounded to MinorUnits decimal places.
+type Conversion struct {
+	Currency   string
+	MinorUnits int
+	Rate       money.Rate
+}
+
+// Conversion returns the conversion from currency from into currency to. A
+// blank from is the base currency and a blank to keeps the amount in from.
+// Either currency missing from the table fails with a *CurrencyError,
+// unless no conversion is needed.
+func (fx FxTable) Conversion(from string, to string) (conv Conversion, err error) {
+	if from == "" {
+		from = fx.Base
+	}
+	if to == "" {
+		to = from
+	}
+
+	if from == to {
+		conv = Conversion{Currency: to, MinorUnits: money.MinorUnits, Rate: money.RateScale}
+		if rate, found := fx.Rates[to]; found {
+			conv.MinorUnits = rate.MinorUnits
+		}
+
+		return conv, nil
+	}
+
+	fromRate, found := fx.Rates[from]
+	if !found {
+		return Conversion{}, &CurrencyError{Currency: from}
+	}
+	toRate, found := fx.Rates[to]
+	if !found {
+		return Conversion{}, &CurrencyError{Currency: to}
+	}
+
+	conv = Conversion{
+		Currency:   to,
+		MinorUnits: toRate.MinorUnits,
+		Rate:       money.CrossRate(fromRate.Rate, toRate.Rate),
+	}
+
+	return conv, nil
+}
+
+// CurrencyError reports a currency that has no rate in the FX table. It
+// wraps ErrUnknownCurrency, which gives it its code.
+type CurrencyError struct {
+	Currency string
+}
+
+func (e *CurrencyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnknownCurrency, e.Currency)
+}
+
+// Details names the currency, for error responses.
+func (e *CurrencyError) Details() map[string]string {
+	return map[string]string{"currency": e.Currency}
+}
+
+func (e *CurrencyError) Unwrap() error {
+	return ErrUnknownCurrency
+}
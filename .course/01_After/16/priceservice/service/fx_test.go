@@ -0,0 +1,35 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FxTable_Conversion(t *testing.T) {
+	tests := []struct {
+		fx   FxTable
+		from string
+		to   string
+		err  error
+		conv Conversion
+	}{
+		{fx: FxTable{}, from: "", to: "", conv: Conversion{Currency: "", MinorUnits: 2, Rate: money.RateScale}},
+		{fx: FxTable{}, from: "", to: "EUR", err: ErrUnknownCurrency},
+		{fx: mockFxTable, from: "", to: "", conv: Conversion{Currency: "USD", MinorUnits: 2, Rate: money.RateScale}},
+		{fx: mockFxTable, from: "JPY", to: "", conv: Conversion{Currency: "JPY", MinorUnits: 0, Rate: money.RateScale}},
+		{fx: mockFxTable, from: "", to: "EUR", conv: Conversion{Currency: "EUR", MinorUnits: 2, Rate: money.MustParseRate("0.92")}},
+		{fx: mockFxTable, from: "EUR", to: "JPY", conv: Conversion{Currency: "JPY", MinorUnits: 0, Rate: money.MustParseRate("164.456522")}},
+		{fx: mockFxTable, from: "GBP", to: "GBP", conv: Conversion{Currency: "GBP", MinorUnits: 2, Rate: money.RateScale}},
+		{fx: mockFxTable, from: "GBP", to: "USD", err: ErrUnknownCurrency},
+		{fx: mockFxTable, from: "USD", to: "GBP", err: ErrUnknownCurrency},
+	}
+
+	for id, test := range tests {
+		conv, err := test.fx.Conversion(test.from, test.to)
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.conv == conv, "~2|Test #%d expected conversion: %+v, not conversion %+v~", id, test.conv, conv)
+	}
+}
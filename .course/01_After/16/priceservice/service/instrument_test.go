@@ -50,9 +50,9 @@ func Test_Instrumenting_GetRetailTotal(t *testing.T) {
 	svc = new(MockPricingService)
 	svc = NewInstrumentingMiddleware(counter, latency, svc)
 
-	svc.GetRetailTotal(ctx, "aaa111", 5)
-	svc.GetRetailTotal(ctx, "bbb222", 10)
-	svc.GetRetailTotal(ctx, "ccc333", 15)
+	svc.GetRetailTotal(ctx, "aaa111", 5, RetailOptions{})
+	svc.GetRetailTotal(ctx, "bbb222", 10, RetailOptions{})
+	svc.GetRetailTotal(ctx, "ccc333", 15, RetailOptions{})
 
 	counterActual := counter.Result()
 	latencyActual := latency.Result()
@@ -71,9 +71,30 @@ func Test_Instrumenting_GetWholesaleTotal(t *testing.T) {
 	svc = new(MockPricingService)
 	svc = NewInstrumentingMiddleware(counter, latency, svc)
 
-	svc.GetWholesaleTotal(ctx, "superstore", "aaa111", 5)
-	svc.GetWholesaleTotal(ctx, "superstore", "bbb222", 10)
-	svc.GetWholesaleTotal(ctx, "superstore", "ccc333", 15)
+	svc.GetWholesaleTotal(ctx, "superstore", "aaa111", 5, PriceOptions{})
+	svc.GetWholesaleTotal(ctx, "superstore", "bbb222", 10, PriceOptions{})
+	svc.GetWholesaleTotal(ctx, "superstore", "ccc333", 15, PriceOptions{})
+
+	counterActual := counter.Result()
+	latencyActual := latency.Result()
+
+	assert.True(t, counterActual == 3.0, "~2|Test counter expected: 3, not: \"%.1f\"~", counterActual)
+	assert.True(t, latencyActual > 0.0, "~2|Test latency expected value greater than 0.0~")
+}
+
+func Test_Instrumenting_GetQuote(t *testing.T) {
+	ctx := context.Background()
+
+	counter := new(MockCounter)
+	latency := new(MockLatency)
+
+	var svc PricingService
+	svc = new(MockPricingService)
+	svc = NewInstrumentingMiddleware(counter, latency, svc)
+
+	svc.GetQuote(ctx, "superstore", "", "", []QuoteLine{{Code: "aaa111", Qty: 5}})
+	svc.GetQuote(ctx, "", "", "", []QuoteLine{{Code: "bbb222", Qty: 10}})
+	svc.GetQuote(ctx, "", "", "", nil)
 
 	counterActual := counter.Result()
 	latencyActual := latency.Result()
@@ -3,11 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
-	"math"
-	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,12 +31,12 @@ func (ml *MockLogger) Result() string {
 
 type MockPricingService struct{}
 
-func (MockPricingService) GetRetailTotal(ctx context.Context, code string, qty int) (total float64, err error) {
+func (MockPricingService) GetRetailTotal(ctx context.Context, code string, qty int, opts RetailOptions) (price Price, err error) {
 	if code == "" {
-		return 0.0, ErrInvalidCode
+		return Price{}, ErrInvalidCode
 	}
 	if qty <= 0 {
-		return 0.0, ErrInvalidQty
+		return Price{}, ErrInvalidQty
 	}
 
 	data := []string{
@@ -49,24 +48,25 @@ func (MockPricingService) GetRetailTotal(ctx context.Context, code string, qty i
 	for _, line := range data {
 		parts := strings.Split(line, ",")
 		if parts[0] == code {
-			price, _ := strconv.ParseFloat(parts[1], 64)
+			unitPrice := money.MustParse(parts[1])
+			total := money.HalfUp.Discounted(unitPrice, qty, 0)
 
-			return (price * float64(qty)), nil
+			return Price{UnitPrice: unitPrice, Currency: "USD", Total: total, Gross: total}, nil
 		}
 	}
 
-	return 0.0, ErrCodeNotFound
+	return Price{}, ErrCodeNotFound
 }
 
-func (MockPricingService) GetWholesaleTotal(ctx context.Context, partner string, code string, qty int) (total float64, err error) {
+func (MockPricingService) GetWholesaleTotal(ctx context.Context, partner string, code string, qty int, opts PriceOptions) (price Price, err error) {
 	if partner == "" {
-		return 0.0, ErrInvalidPartner
+		return Price{}, ErrInvalidPartner
 	}
 	if code == "" {
-		return 0.0, ErrInvalidCode
+		return Price{}, ErrInvalidCode
 	}
 	if qty <= 0 {
-		return 0.0, ErrInvalidQty
+		return Price{}, ErrInvalidQty
 	}
 
 	prices := []string{
@@ -75,18 +75,18 @@ func (MockPricingService) GetWholesaleTotal(ctx context.Context, partner string,
 		"ccc333,22.50",
 	}
 
-	price := 0.0
+	var unitPrice money.Amount
 	priceFound := false
 	for _, line := range prices {
 		parts := strings.Split(line, ",")
 		if parts[0] == code {
 			priceFound = true
-			price, _ = strconv.ParseFloat(parts[1], 64)
+			unitPrice = money.MustParse(parts[1])
 		}
 	}
 
 	if !priceFound {
-		return 0.0, ErrCodeNotFound
+		return Price{}, ErrCodeNotFound
 	}
 
 	partners := []string{
@@ -94,24 +94,46 @@ func (MockPricingService) GetWholesaleTotal(ctx context.Context, partner string,
 		"joesdiscount,0.05",
 	}
 
-	discount := 0.0
+	var discount money.Rate
 	discountFound := false
 	for _, line := range partners {
 		parts := strings.Split(line, ",")
 		if parts[0] == partner {
 			discountFound = true
-			discount, _ = strconv.ParseFloat(parts[1], 64)
+			discount = money.MustParseRate(parts[1])
 		}
 	}
 
 	if !discountFound {
-		return 0.0, ErrPartnerNotFound
+		return Price{}, ErrPartnerNotFound
 	}
 
-	saved := (price * discount)
-	total = (price - saved) * float64(qty)
+	total := money.HalfUp.Discounted(unitPrice, qty, discount)
 
-	return math.Round(total*100) / 100, nil
+	return Price{UnitPrice: unitPrice, Discount: discount, Currency: "USD", Total: total, Gross: total}, nil
+}
+
+func (mps MockPricingService) GetQuote(ctx context.Context, partner string, currency string, region string, lines []QuoteLine) (quote Quote, err error) {
+	if len(lines) == 0 {
+		return Quote{}, ErrEmptyQuote
+	}
+
+	quote.Partner = partner
+	quote.Currency = "USD"
+	for _, line := range lines {
+		quoted := QuotedLine{Code: line.Code, Qty: line.Qty}
+		if partner == "" {
+			quoted.Price, quoted.Err = mps.GetRetailTotal(ctx, line.Code, line.Qty, RetailOptions{})
+		} else {
+			quoted.Price, quoted.Err = mps.GetWholesaleTotal(ctx, partner, line.Code, line.Qty, PriceOptions{})
+		}
+
+		quote.Total = quote.Total.Add(quoted.Total)
+		quote.Gross = quote.Gross.Add(quoted.Gross)
+		quote.Lines = append(quote.Lines, quoted)
+	}
+
+	return quote, nil
 }
 
 func Test_Logging_GetRetailTotal(t *testing.T) {
@@ -125,12 +147,12 @@ func Test_Logging_GetRetailTotal(t *testing.T) {
 		{
 			code: "aaa111",
 			qty:  15,
-			msg:  "method,GetRetailTotal,code,aaa111,quantity,15,total,194.85,error,<nil>,duration",
+			msg:  "method,GetRetailTotal,code,aaa111,quantity,15,coupon,,saving,0.00,total,194.85,currency,USD,region,,gross,194.85,error,<nil>,duration",
 		},
 		{
 			code: "fff000",
 			qty:  10,
-			msg:  "method,GetRetailTotal,code,fff000,quantity,10,total,0,error,Code Not Found,duration",
+			msg:  "method,GetRetailTotal,code,fff000,quantity,10,coupon,,saving,0.00,total,0.00,currency,,region,,gross,0.00,error,Code Not Found,duration",
 		},
 	}
 
@@ -140,7 +162,7 @@ func Test_Logging_GetRetailTotal(t *testing.T) {
 	svc = NewLoggingMiddleware(logger, svc)
 
 	for id, test := range tests {
-		svc.GetRetailTotal(ctx, test.code, test.qty)
+		svc.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{})
 
 		actual := logger.Result()
 
@@ -161,13 +183,13 @@ func Test_Logging_GetWholesaleTotal(t *testing.T) {
 			partner: "superstore",
 			code:    "aaa111",
 			qty:     15,
-			msg:     "method,GetWholesaleTotal,partner,superstore,code,aaa111,quantity,15,total,165.62,error,<nil>,duration",
+			msg:     "method,GetWholesaleTotal,partner,superstore,code,aaa111,quantity,15,total,165.62,currency,USD,region,,gross,165.62,error,<nil>,duration",
 		},
 		{
 			partner: "smiles",
 			code:    "fff000",
 			qty:     10,
-			msg:     "method,GetWholesaleTotal,partner,smiles,code,fff000,quantity,10,total,0,error,Code Not Found,duration",
+			msg:     "method,GetWholesaleTotal,partner,smiles,code,fff000,quantity,10,total,0.00,currency,,region,,gross,0.00,error,Code Not Found,duration",
 		},
 	}
 
@@ -177,7 +199,7 @@ func Test_Logging_GetWholesaleTotal(t *testing.T) {
 	svc = NewLoggingMiddleware(logger, svc)
 
 	for id, test := range tests {
-		svc.GetWholesaleTotal(ctx, test.partner, test.code, test.qty)
+		svc.GetWholesaleTotal(ctx, test.partner, test.code, test.qty, PriceOptions{})
 
 		actual := logger.Result()
 
@@ -185,3 +207,37 @@ func Test_Logging_GetWholesaleTotal(t *testing.T) {
 
 	}
 }
+
+func Test_Logging_GetQuote(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner string
+		lines   []QuoteLine
+		msg     string
+	}{
+		{
+			partner: "superstore",
+			lines:   []QuoteLine{{Code: "aaa111", Qty: 15}, {Code: "fff000", Qty: 1}},
+			msg:     "method,GetQuote,partner,superstore,lines,2,total,165.62,currency,USD,region,,gross,165.62,error,<nil>,duration",
+		},
+		{
+			partner: "",
+			lines:   nil,
+			msg:     "method,GetQuote,partner,,lines,0,total,0.00,currency,,region,,gross,0.00,error,Empty Quote Requested,duration",
+		},
+	}
+
+	logger := new(MockLogger)
+	var svc PricingService
+	svc = new(MockPricingService)
+	svc = NewLoggingMiddleware(logger, svc)
+
+	for id, test := range tests {
+		svc.GetQuote(ctx, test.partner, "", "", test.lines)
+
+		actual := logger.Result()
+
+		assert.True(t, strings.HasPrefix(actual, test.msg), "~2|Test #%d logging expected: \"%s\", not: \"%s\"~", id, test.msg, actual)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import "github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+
+// PriceList holds a partner's commercial terms: a default discount, discount
+// overrides per product code and fixed net prices per product code.
+//
+// The terms for a code are resolved in this order:
+//  1. a fixed net price for the code, which replaces the tier price and
+//     is not discounted any further;
+//  2. a discount override for the code;
+//  3. the partner's default discount.
+//
+// A TaxExempt partner is charged no tax in any region.
+//
+// The zero PriceList prices everything at retail.
+type PriceList struct {
+	Partner   string
+	Discount  money.Rate
+	Discounts map[string]money.Rate
+	NetPrices map[string]money.Amount
+	TaxExempt bool
+}
+
+// NetPrice returns the fixed net unit price agreed for code, if any.
+func (pl PriceList) NetPrice(code string) (price money.Amount, found bool) {
+	price, found = pl.NetPrices[code]
+
+	return price, found
+}
+
+// DiscountFor returns the discount that applies to code, falling back to the
+// partner's default discount when the code has no override.
+func (pl PriceList) DiscountFor(code string) (discount money.Rate) {
+	if discount, found := pl.Discounts[code]; found {
+		return discount
+	}
+
+	return pl.Discount
+}
@@ -0,0 +1,80 @@
+package service
+
+import "github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+
+// Product is a catalog entry. Tiers holds its unit prices by quantity,
+// ordered by MinQty, with the first tier starting at a quantity of 1. The
+// prices are in Currency, or in the base currency of the FX table when
+// Currency is blank. TaxClass picks the product's tax rate in each region;
+// a blank TaxClass is StandardTaxClass. CatalogVersion is set by repos that
+// version their catalog.
+type Product struct {
+	Code           string
+	Currency       string
+	TaxClass       string
+	Tiers          []Tier
+	CatalogVersion int
+}
+
+// Tier is the unit price that applies from MinQty up to and including
+// MaxQty. A MaxQty of 0 means the tier has no upper bound.
+type Tier struct {
+	MinQty int
+	MaxQty int
+	Price  money.Amount
+}
+
+// NewProduct builds a Product from its base unit price and optional
+// quantity breaks. Breaks must be ordered by MinQty, all above 1; each one
+// applies up to the next.
+func NewProduct(code string, price money.Amount, breaks ...Tier) (p Product) {
+	p = Product{
+		Code:  code,
+		Tiers: append([]Tier{{MinQty: 1, Price: price}}, breaks...),
+	}
+
+	for i := range p.Tiers {
+		p.Tiers[i].MaxQty = 0
+		if i+1 < len(p.Tiers) {
+			p.Tiers[i].MaxQty = p.Tiers[i+1].MinQty - 1
+		}
+	}
+
+	return p
+}
+
+// TierFor returns the tier that applies to qty.
+func (p Product) TierFor(qty int) (tier Tier, found bool) {
+	for _, t := range p.Tiers {
+		if qty >= t.MinQty && (t.MaxQty == 0 || qty <= t.MaxQty) {
+			return t, true
+		}
+	}
+
+	return Tier{}, false
+}
+
+// Price is a priced quantity of one product: the tier picked for the
+// quantity, the discount applied to its unit price and the rounded total.
+// NetPriced is set when a partner's fixed net price was used instead; Tier and
+// Discount are then left empty. Amounts are in Currency, converted from the
+// product's currency at FxRate. Total is after any Promotion and before
+// tax; Net is the same amount as taxed, and Tax is charged on it at TaxRate
+// for Region, giving Gross. Breakdown is only set when the price was asked
+// to be explained.
+type Price struct {
+	UnitPrice money.Amount
+	Tier      Tier
+	Discount  money.Rate
+	NetPriced bool
+	Currency  string
+	FxRate    money.Rate
+	Promotion AppliedPromotion
+	Total     money.Amount
+	Net       money.Amount
+	Region    string
+	TaxRate   money.Rate
+	Tax       money.Amount
+	Gross     money.Amount
+	Breakdown *Breakdown
+}
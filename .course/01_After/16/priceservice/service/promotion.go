@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+)
+
+// PromotionKind says how a Promotion works out its saving.
+type PromotionKind string
+
+const (
+	// PercentOff takes Rate off the line total.
+	PercentOff PromotionKind = "percent"
+	// BuyXGetY gives FreeQty units free for every BuyQty units paid for.
+	BuyXGetY PromotionKind = "buyxgety"
+	// AmountOff takes Amount off a line total of at least Threshold.
+	AmountOff PromotionKind = "amount"
+)
+
+// Promotion is a marketing offer redeemed with a coupon. It applies to
+// Product only, or to every product when Product is blank, from From up to
+// but not including To; a zero From or To leaves that end open. Amount and
+// Threshold are in Currency, or in the base currency of the FX table when
+// Currency is blank. A Limit of 0 means the coupon can be used any number
+// of times.
+type Promotion struct {
+	Coupon    string
+	Kind      PromotionKind
+	Product   string
+	Rate      money.Rate
+	BuyQty    int
+	FreeQty   int
+	Amount    money.Amount
+	Threshold money.Amount
+	Currency  string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// runsAt reports whether the promotion has started and not yet ended at t.
+func (p Promotion) runsAt(t time.Time) error {
+	if !p.From.IsZero() && t.Before(p.From) {
+		return ErrCouponNotStarted
+	}
+	if !p.To.IsZero() && !t.Before(p.To) {
+		return ErrExpiredCoupon
+	}
+
+	return nil
+}
+
+// PromotionRepo returns the promotion behind a coupon, and ErrRecordNotFound
+// when there is none. Uses returns how many times a coupon has been
+// redeemed. Redeem records one use of a coupon under key; it reports false,
+// without recording the use, once the promotion's Limit has been reached. A
+// key that already holds a use of the coupon reports it redeemed without
+// counting it again, so that a redemption can safely be retried.
+type PromotionRepo interface {
+	FetchPromotion(ctx context.Context, coupon string) (promotion Promotion, err error)
+	Uses(ctx context.Context, coupon string) (uses int, err error)
+	Redeem(ctx context.Context, coupon string, key string) (redeemed bool, err error)
+}
+
+// AppliedPromotion is the saving a coupon took off a price, and why.
+type AppliedPromotion struct {
+	Coupon string
+	Saving money.Amount
+	Reason string
+}
+
+// CouponError reports a coupon that could not be applied. Err is one of
+// ErrUnknownCoupon, ErrExpiredCoupon, ErrCouponNotStarted, ErrCouponUsedUp or
+// ErrCouponNotApplicable, and is matched by errors.Is.
+type CouponError struct {
+	Coupon string
+	Err    error
+}
+
+func (e *CouponError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Coupon)
+}
+
+// Details names the coupon, for error responses.
+func (e *CouponError) Details() map[string]string {
+	return map[string]string{"coupon": e.Coupon}
+}
+
+func (e *CouponError) Unwrap() error {
+	return e.Err
+}
+
+// promotion fetches the promotion behind coupon and checks that it runs at
+// asOf.
+func (ps *pricingService) promotion(ctx context.Context, coupon string, asOf time.Time) (promotion Promotion, err error) {
+	if ps.promotions == nil {
+		return Promotion{}, &CouponError{Coupon: coupon, Err: ErrUnknownCoupon}
+	}
+
+	promotion, err = ps.promotions.FetchPromotion(ctx, coupon)
+	if err != nil {
+		return Promotion{}, repoError(err, &CouponError{Coupon: coupon, Err: ErrUnknownCoupon})
+	}
+
+	if err := promotion.runsAt(asOf); err != nil {
+		return Promotion{}, &CouponError{Coupon: coupon, Err: err}
+	}
+
+	return promotion, nil
+}
+
+// available checks that promotion has uses left, without using it.
+func (ps *pricingService) available(ctx context.Context, promotion Promotion) (err error) {
+	if promotion.Limit == 0 {
+		return nil
+	}
+
+	uses, err := ps.promotions.Uses(ctx, promotion.Coupon)
+	if err != nil {
+		return repoError(err, &CouponError{Coupon: promotion.Coupon, Err: ErrUnknownCoupon})
+	}
+	if uses >= promotion.Limit {
+		return &CouponError{Coupon: promotion.Coupon, Err: ErrCouponUsedUp}
+	}
+
+	return nil
+}
+
+// redeem records a use of coupon under key once it has been applied to a
+// price.
+func (ps *pricingService) redeem(ctx context.Context, coupon string, key string) (err error) {
+	redeemed, err := ps.promotions.Redeem(ctx, coupon, key)
+	if err != nil {
+		return repoError(err, &CouponError{Coupon: coupon, Err: ErrUnknownCoupon})
+	}
+	if !redeemed {
+		return &CouponError{Coupon: coupon, Err: ErrCouponUsedUp}
+	}
+
+	return nil
+}
+
+// promote takes the saving of promotion off the total of price, before tax.
+// Savings are worked out in the currency of the price, rounded to
+// minorUnits decimal places, and never take the total below zero.
+func (ps *pricingService) promote(price Price, product Product, qty int, promotion Promotion, minorUnits int) (promoted Price, err error) {
+	notApplicable := &CouponError{Coupon: promotion.Coupon, Err: ErrCouponNotApplicable}
+	if promotion.Product != "" && promotion.Product != product.Code {
+		return Price{}, notApplicable
+	}
+
+	var (
+		saving money.Amount
+		reason string
+	)
+
+	switch promotion.Kind {
+	case PercentOff:
+		saving = ps.rounding.Converted(price.Total, 1, 0, promotion.Rate, minorUnits)
+		reason = fmt.Sprintf("%s%% off", promotion.Rate*100)
+
+	case BuyXGetY:
+		free := qty / (promotion.BuyQty + promotion.FreeQty) * promotion.FreeQty
+		if free == 0 {
+			return Price{}, notApplicable
+		}
+
+		saving = ps.rounding.Converted(price.UnitPrice, free, price.Discount, money.RateScale, minorUnits)
+		reason = fmt.Sprintf("buy %d get %d free: %d free", promotion.BuyQty, promotion.FreeQty, free)
+
+	case AmountOff:
+		conv, err := ps.fx.Conversion(promotion.Currency, price.Currency)
+		if err != nil {
+			return Price{}, err
+		}
+
+		amount := ps.rounding.Converted(promotion.Amount, 1, 0, conv.Rate, minorUnits)
+		threshold := ps.rounding.Converted(promotion.Threshold, 1, 0, conv.Rate, minorUnits)
+		if price.Total < threshold {
+			return Price{}, notApplicable
+		}
+
+		saving = amount
+		reason = fmt.Sprintf("%s %s off", amount, price.Currency)
+		if threshold > 0 {
+			reason = fmt.Sprintf("%s totals of %s or more", reason, threshold)
+		}
+
+	default:
+		return Price{}, notApplicable
+	}
+
+	if saving > price.Total {
+		saving = price.Total
+	}
+
+	promoted = price
+	promoted.Promotion = AppliedPromotion{Coupon: promotion.Coupon, Saving: saving, Reason: reason}
+	promoted.Total = price.Total.Sub(saving)
+
+	return promoted, nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockPromotionRepo holds a fixed set of promotions and counts the uses of
+// each coupon, and the redemption keys each was used under.
+type MockPromotionRepo struct {
+	uses map[string]int
+	keys map[string]bool
+}
+
+var mockPromotions = map[string]Promotion{
+	"SAVE10": {Coupon: "SAVE10", Kind: PercentOff, Rate: money.MustParseRate("0.10")},
+	"BOGOF":  {Coupon: "BOGOF", Kind: BuyXGetY, Product: "bbb222", BuyQty: 1, FreeQty: 1},
+	"TENOFF": {Coupon: "TENOFF", Kind: AmountOff, Amount: money.MustParse("10.00"), Threshold: money.MustParse("100.00")},
+	"FREE":   {Coupon: "FREE", Kind: AmountOff, Amount: money.MustParse("50.00")},
+	"OLD":    {Coupon: "OLD", Kind: PercentOff, Rate: money.MustParseRate("0.50"), To: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	"SOON":   {Coupon: "SOON", Kind: PercentOff, Rate: money.MustParseRate("0.50"), From: time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	"ONCE":   {Coupon: "ONCE", Kind: PercentOff, Rate: money.MustParseRate("0.05"), Limit: 1},
+}
+
+func (mr *MockPromotionRepo) FetchPromotion(ctx context.Context, coupon string) (promotion Promotion, err error) {
+	promotion, found := mockPromotions[coupon]
+	if !found {
+		return Promotion{}, ErrRecordNotFound
+	}
+
+	return promotion, nil
+}
+
+func (mr *MockPromotionRepo) Uses(ctx context.Context, coupon string) (uses int, err error) {
+	return mr.uses[coupon], nil
+}
+
+func (mr *MockPromotionRepo) Redeem(ctx context.Context, coupon string, key string) (redeemed bool, err error) {
+	if mr.uses == nil {
+		mr.uses = make(map[string]int)
+		mr.keys = make(map[string]bool)
+	}
+
+	if mr.keys[coupon+"/"+key] {
+		return true, nil
+	}
+	if limit := mockPromotions[coupon].Limit; limit > 0 && mr.uses[coupon] >= limit {
+		return false, nil
+	}
+	mr.uses[coupon]++
+	mr.keys[coupon+"/"+key] = true
+
+	return true, nil
+}
+
+func Test_GetRetailTotal_Coupon(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		code     string
+		qty      int
+		asOf     time.Time
+		currency string
+		region   string
+		coupon   string
+		err      error
+		saving   money.Amount
+		reason   string
+		total    money.Amount
+		gross    money.Amount
+	}{
+		{code: "aaa111", qty: 15, coupon: "SAVE10", saving: money.MustParse("19.49"), reason: "10% off", total: money.MustParse("175.36"), gross: money.MustParse("175.36")},
+		{code: "aaa111", qty: 15, region: "uk", coupon: "SAVE10", saving: money.MustParse("19.49"), reason: "10% off", total: money.MustParse("175.36"), gross: money.MustParse("210.43")},
+		{code: "bbb222", qty: 5, coupon: "BOGOF", saving: money.MustParse("5.80"), reason: "buy 1 get 1 free: 2 free", total: money.MustParse("8.70"), gross: money.MustParse("8.70")},
+		{code: "bbb222", qty: 1, coupon: "BOGOF", err: ErrCouponNotApplicable},
+		{code: "aaa111", qty: 4, coupon: "BOGOF", err: ErrCouponNotApplicable},
+		{code: "aaa111", qty: 15, coupon: "TENOFF", saving: money.MustParse("10.00"), reason: "10.00 USD off totals of 100.00 or more", total: money.MustParse("184.85"), gross: money.MustParse("184.85")},
+		{code: "aaa111", qty: 15, currency: "EUR", coupon: "TENOFF", saving: money.MustParse("9.20"), reason: "9.20 EUR off totals of 92.00 or more", total: money.MustParse("170.06"), gross: money.MustParse("170.06")},
+		{code: "aaa111", qty: 5, coupon: "TENOFF", err: ErrCouponNotApplicable},
+		{code: "bbb222", qty: 1, coupon: "FREE", saving: money.MustParse("2.90"), reason: "50.00 USD off", total: money.MustParse("0.00"), gross: money.MustParse("0.00")},
+		{code: "aaa111", qty: 1, coupon: "OLD", err: ErrExpiredCoupon},
+		{code: "aaa111", qty: 1, asOf: time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC), coupon: "OLD", saving: money.MustParse("6.50"), reason: "50% off", total: money.MustParse("6.49"), gross: money.MustParse("6.49")},
+		{code: "aaa111", qty: 1, coupon: "SOON", err: ErrCouponNotStarted},
+		{code: "aaa111", qty: 1, coupon: "NOPE", err: ErrUnknownCoupon},
+		{code: "fff000", qty: 1, coupon: "SAVE10", err: ErrCodeNotFound},
+	}
+
+	priceService := NewPricingService(new(MockProductRepo), new(MockPromotionRepo), mockFxTable, mockTaxTable, money.HalfUp)
+
+	for id, test := range tests {
+		price, err := priceService.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{PriceOptions: PriceOptions{AsOf: test.asOf, Currency: test.currency, Region: test.region}, Coupon: test.coupon})
+
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		if test.err != nil {
+			continue
+		}
+
+		assert.True(t, test.coupon == price.Promotion.Coupon, "~2|Test #%d expected coupon: %s, not coupon %s~", id, test.coupon, price.Promotion.Coupon)
+		assert.True(t, test.saving == price.Promotion.Saving, "~2|Test #%d expected saving: %s, not saving %s~", id, test.saving, price.Promotion.Saving)
+		assert.True(t, test.reason == price.Promotion.Reason, "~2|Test #%d expected reason: %s, not reason %s~", id, test.reason, price.Promotion.Reason)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+		assert.True(t, test.gross == price.Gross, "~2|Test #%d expected gross: %s, not gross %s~", id, test.gross, price.Gross)
+	}
+}
+
+func Test_GetRetailTotal_CouponError(t *testing.T) {
+	ctx := context.Background()
+
+	priceService := NewPricingService(new(MockProductRepo), new(MockPromotionRepo), FxTable{}, TaxTable{}, money.HalfUp)
+
+	_, err := priceService.GetRetailTotal(ctx, "aaa111", 1, RetailOptions{Coupon: "OLD"})
+
+	var couponErr *CouponError
+	assert.True(t, errors.As(err, &couponErr), "~2|Test expected a *CouponError, not error %s~", err)
+	assert.True(t, couponErr.Coupon == "OLD", "~2|Test expected coupon: OLD, not coupon %s~", couponErr.Coupon)
+	assert.True(t, err.Error() == "Expired Coupon: OLD", "~2|Test expected message: Expired Coupon: OLD, not message %s~", err)
+
+	priceService = NewPricingService(new(MockProductRepo), nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	_, err = priceService.GetRetailTotal(ctx, "aaa111", 1, RetailOptions{Coupon: "SAVE10"})
+	assert.True(t, errors.Is(err, ErrUnknownCoupon), "~2|Test expected error: %s, not error %s~", ErrUnknownCoupon, err)
+}
+
+func Test_GetRetailTotal_Redemption(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		coupon string
+		key    string
+		err    error
+		uses   int
+	}{
+		{coupon: "ONCE", uses: 0},
+		{coupon: "ONCE", uses: 0},
+		{coupon: "ONCE", key: "order-1", uses: 1},
+		{coupon: "ONCE", key: "order-1", uses: 1},
+		{coupon: "ONCE", key: "order-2", err: ErrCouponUsedUp, uses: 1},
+		{coupon: "ONCE", err: ErrCouponUsedUp, uses: 1},
+		{coupon: "SAVE10", key: "order-1", uses: 1},
+		{coupon: "SAVE10", key: "order-2", uses: 2},
+	}
+
+	promotions := new(MockPromotionRepo)
+	priceService := NewPricingService(new(MockProductRepo), promotions, FxTable{}, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		price, err := priceService.GetRetailTotal(ctx, "aaa111", 1, RetailOptions{Coupon: test.coupon, RedemptionKey: test.key})
+
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		if test.err == nil {
+			assert.True(t, test.coupon == price.Promotion.Coupon, "~2|Test #%d expected coupon: %s, not coupon %s~", id, test.coupon, price.Promotion.Coupon)
+		}
+
+		uses, _ := promotions.Uses(ctx, test.coupon)
+		assert.True(t, test.uses == uses, "~2|Test #%d expected %d uses, not %d~", id, test.uses, uses)
+	}
+}
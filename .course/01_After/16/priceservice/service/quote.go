@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+)
+
+type QuoteLine struct {
+	Code string
+	Qty  int
+}
+
+// QuotedLine is a priced QuoteLine. Err is set instead of the Price when
+// the line could not be priced; such lines are left out of the quote total.
+type QuotedLine struct {
+	Code string
+	Qty  int
+	Price
+	Err error
+}
+
+// Quote totals add up the lines that could be priced: Total before tax,
+// the same amount as Net, Tax and Gross.
+type Quote struct {
+	Partner  string
+	Currency string
+	Region   string
+	Lines    []QuotedLine
+	Total    money.Amount
+	Net      money.Amount
+	Tax      money.Amount
+	Gross    money.Amount
+}
+
+// GetQuote prices every line of a basket. Problems with a single line are
+// reported on that line, while a missing or unknown partner fails the whole
+// quote. Without a partner the lines are priced at retail. Quotes are always
+// priced as of now. Every line is converted into currency, or into the base
+// currency when currency is blank, so that the lines add up, and taxed for
+// region.
+func (ps *pricingService) GetQuote(ctx context.Context, partner string, currency string, region string, lines []QuoteLine) (quote Quote, err error) {
+	if len(lines) == 0 {
+		return Quote{}, ErrEmptyQuote
+	}
+
+	if currency == "" {
+		currency = ps.fx.Base
+	} else if _, found := ps.fx.Rates[currency]; !found {
+		return Quote{}, &CurrencyError{Currency: currency}
+	}
+	if _, found := ps.taxes.Rates[region]; region != "" && !found {
+		return Quote{}, ErrUnknownRegion
+	}
+
+	asOf := time.Now()
+
+	var priceList PriceList
+	if partner != "" {
+		priceList, err = ps.repo.FetchPriceList(ctx, partner, asOf)
+		if err != nil {
+			return Quote{}, repoError(err, ErrPartnerNotFound)
+		}
+	}
+
+	quote = Quote{
+		Partner:  partner,
+		Currency: currency,
+		Region:   region,
+		Lines:    make([]QuotedLine, len(lines)),
+	}
+
+	for i, line := range lines {
+		quoted := ps.quoteLine(ctx, line, priceList, asOf, currency, region)
+		if quoted.Err == nil {
+			quote.Total = quote.Total.Add(quoted.Total)
+			quote.Net = quote.Net.Add(quoted.Net)
+			quote.Tax = quote.Tax.Add(quoted.Tax)
+			quote.Gross = quote.Gross.Add(quoted.Gross)
+		}
+
+		quote.Lines[i] = quoted
+	}
+
+	return quote, nil
+}
+
+func (ps *pricingService) quoteLine(ctx context.Context, line QuoteLine, priceList PriceList, asOf time.Time, currency string, region string) (quoted QuotedLine) {
+	quoted = QuotedLine{
+		Code: line.Code,
+		Qty:  line.Qty,
+	}
+
+	if line.Code == "" {
+		quoted.Err = ErrInvalidCode
+		return
+	}
+	if line.Qty <= 0 {
+		quoted.Err = ErrInvalidQty
+		return
+	}
+
+	product, err := ps.repo.FetchProduct(ctx, line.Code, asOf)
+	if err != nil {
+		quoted.Err = repoError(err, ErrCodeNotFound)
+		return
+	}
+
+	quoted.Price, quoted.Err = ps.price(product, line.Qty, priceList, Promotion{}, PriceOptions{Currency: currency, Region: region})
+
+	return
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetQuote(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner string
+		lines   []QuoteLine
+		err     error
+		quoted  []QuotedLine
+		total   money.Amount
+	}{
+		{
+			partner: "",
+			lines:   nil,
+			err:     ErrEmptyQuote,
+		},
+		{
+			partner: "jesscafe",
+			lines:   []QuoteLine{{Code: "aaa111", Qty: 1}},
+			err:     ErrPartnerNotFound,
+		},
+		{
+			partner: "",
+			lines:   []QuoteLine{{Code: "aaa111", Qty: 15}, {Code: "bbb222", Qty: 2}},
+			quoted: []QuotedLine{
+				{Code: "aaa111", Qty: 15, Price: Price{UnitPrice: money.MustParse("12.99"), Tier: Tier{MinQty: 1, Price: money.MustParse("12.99")}, Discount: 0, FxRate: money.RateScale, Total: money.MustParse("194.85"), Net: money.MustParse("194.85"), Gross: money.MustParse("194.85")}},
+				{Code: "bbb222", Qty: 2, Price: Price{UnitPrice: money.MustParse("2.90"), Tier: Tier{MinQty: 1, Price: money.MustParse("2.90")}, Discount: 0, FxRate: money.RateScale, Total: money.MustParse("5.80"), Net: money.MustParse("5.80"), Gross: money.MustParse("5.80")}},
+			},
+			total: money.MustParse("200.65"),
+		},
+		{
+			partner: "superstore",
+			lines: []QuoteLine{
+				{Code: "bbb222", Qty: 15},
+				{Code: "", Qty: 1},
+				{Code: "ccc333", Qty: 0},
+				{Code: "xyz123", Qty: 3},
+				{Code: "ccc333", Qty: 2},
+				{Code: "ddd444", Qty: 10},
+			},
+			quoted: []QuotedLine{
+				{Code: "bbb222", Qty: 15, Price: Price{UnitPrice: money.MustParse("2.90"), Tier: Tier{MinQty: 1, Price: money.MustParse("2.90")}, Discount: money.MustParseRate("0.10"), FxRate: money.RateScale, Total: money.MustParse("39.15"), Net: money.MustParse("39.15"), Gross: money.MustParse("39.15")}},
+				{Code: "", Qty: 1, Err: ErrInvalidCode},
+				{Code: "ccc333", Qty: 0, Err: ErrInvalidQty},
+				{Code: "xyz123", Qty: 3, Err: ErrCodeNotFound},
+				{Code: "ccc333", Qty: 2, Price: Price{UnitPrice: money.MustParse("22.50"), Tier: Tier{MinQty: 1, Price: money.MustParse("22.50")}, Discount: money.MustParseRate("0.10"), FxRate: money.RateScale, Total: money.MustParse("40.50"), Net: money.MustParse("40.50"), Gross: money.MustParse("40.50")}},
+				{Code: "ddd444", Qty: 10, Price: Price{UnitPrice: money.MustParse("11.50"), Tier: Tier{MinQty: 10, MaxQty: 99, Price: money.MustParse("11.50")}, Discount: money.MustParseRate("0.10"), FxRate: money.RateScale, Total: money.MustParse("103.50"), Net: money.MustParse("103.50"), Gross: money.MustParse("103.50")}},
+			},
+			total: money.MustParse("183.15"),
+		},
+	}
+
+	mockProductRepo := new(MockProductRepo)
+
+	priceService := NewPricingService(mockProductRepo, nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		quote, err := priceService.GetQuote(ctx, test.partner, "", "", test.lines)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.total == quote.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, quote.Total)
+		assert.True(t, test.total == quote.Net, "~2|Test #%d expected net: %s, not net %s~", id, test.total, quote.Net)
+		assert.True(t, len(test.quoted) == len(quote.Lines), "~2|Test #%d expected %d lines, not %d~", id, len(test.quoted), len(quote.Lines))
+
+		for i := range quote.Lines {
+			if i >= len(test.quoted) {
+				break
+			}
+
+			expected, actual := test.quoted[i], quote.Lines[i]
+			assert.True(t, expected == actual, "~2|Test #%d line #%d expected: %+v, not: %+v~", id, i, expected, actual)
+		}
+	}
+}
+
+func Test_GetQuote_RepoUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	priceService := NewPricingService(new(MockFailingProductRepo), nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	quote, err := priceService.GetQuote(ctx, "", "", "", []QuoteLine{{Code: "aaa111", Qty: 1}})
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	assert.True(t, errors.Is(quote.Lines[0].Err, ErrRepoUnavailable), "~2|Test expected line error: %s, not error %s~", ErrRepoUnavailable, quote.Lines[0].Err)
+
+	_, err = priceService.GetQuote(ctx, "superstore", "", "", []QuoteLine{{Code: "aaa111", Qty: 1}})
+	assert.True(t, errors.Is(err, ErrRepoUnavailable), "~2|Test expected error: %s, not error %s~", ErrRepoUnavailable, err)
+}
+
+func Test_GetQuote_Currency(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		currency  string
+		err       error
+		converted string
+		totals    []money.Amount
+		total     money.Amount
+	}{
+		{currency: "", converted: "USD", totals: []money.Amount{money.MustParse("12.99"), money.MustParse("19.83")}, total: money.MustParse("32.82")},
+		{currency: "EUR", converted: "EUR", totals: []money.Amount{money.MustParse("11.95"), money.MustParse("18.24")}, total: money.MustParse("30.19")},
+		{currency: "GBP", err: ErrUnknownCurrency},
+	}
+
+	priceService := NewPricingService(new(MockCurrencyProductRepo), nil, mockFxTable, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		quote, err := priceService.GetQuote(ctx, "", test.currency, "", []QuoteLine{{Code: "aaa111", Qty: 1}, {Code: "fff555", Qty: 2}})
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.converted == quote.Currency, "~2|Test #%d expected currency: %s, not currency %s~", id, test.converted, quote.Currency)
+		assert.True(t, test.total == quote.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, quote.Total)
+
+		for i, line := range quote.Lines {
+			assert.True(t, test.totals[i] == line.Total, "~2|Test #%d line #%d expected total: %s, not total %s~", id, i, test.totals[i], line.Total)
+			assert.True(t, test.converted == line.Currency, "~2|Test #%d line #%d expected currency: %s, not currency %s~", id, i, test.converted, line.Currency)
+		}
+	}
+}
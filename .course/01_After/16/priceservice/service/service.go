@@ -3,88 +3,294 @@ package service
 import (
 	"context"
 	"errors"
-	"math"
+	"fmt"
+	"time"
 
-	"go.opentelemetry.io/otel"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
 )
 
+// PricingService prices quantities of a product at retail or for a
+// partner, and whole quotes, as its options say. The pricing API traces its
+// proxy of the service with the same tracing middleware.
+//
+//go:generate go run ../cmd/kitgen -type PricingService -kind logging -out logging_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -kind instrumenting -out instrument_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -kind tracing -prefix pricing. -errcode ErrorCode -out tracing_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -kind tracing -prefix pricing. -errcode ErrorCode -out ../../priceapi/service/tracing_gen.go
 type PricingService interface {
-	GetRetailTotal(ctx context.Context, code string, qty int) (total float64, err error)
-	GetWholesaleTotal(ctx context.Context, partner, code string, qty int) (total float64, err error)
+	//kit:log code qty:quantity opts.Coupon price.Promotion.Saving price.Total price.Currency opts.Region price.Gross
+	//kit:trace code qty opts.Coupon opts.Region price.Currency price.Total price.Gross
+	//kit:http /retail TotalRetailPrice
+	GetRetailTotal(ctx context.Context, code string, qty int, opts RetailOptions) (price Price, err error)
+
+	//kit:log partner code qty:quantity price.Total price.Currency opts.Region price.Gross
+	//kit:trace partner code qty opts.Region price.Currency price.Total price.Gross
+	//kit:http /wholesale TotalWholesalePrice
+	GetWholesaleTotal(ctx context.Context, partner string, code string, qty int, opts PriceOptions) (price Price, err error)
+
+	//kit:log partner len(lines):lines quote.Total quote.Currency region quote.Gross
+	//kit:trace partner len(lines):lines region quote.Currency quote.Total quote.Gross
+	//kit:http /quote Quote
+	GetQuote(ctx context.Context, partner string, currency string, region string, lines []QuoteLine) (quote Quote, err error)
+}
+
+// PriceOptions say how a price is worked out. It is priced at the instant
+// AsOf; a zero AsOf means now. It is converted into Currency, or left in
+// the product's own currency when Currency is blank, and taxed at the rates
+// of Region, or left untaxed when Region is blank. With Explain set the
+// price carries a Breakdown of its total.
+type PriceOptions struct {
+	AsOf     time.Time
+	Currency string
+	Region   string
+	Explain  bool
 }
 
+// RetailOptions are the PriceOptions of a retail price and the Coupon, if
+// any, whose promotion is taken off its total before tax. A RedemptionKey
+// uses the coupon up once under that key; without one the coupon is only
+// checked.
+type RetailOptions struct {
+	PriceOptions
+	Coupon        string
+	RedemptionKey string
+}
+
+// ProductRepo returns the product or price list that applied at asOf, and
+// ErrRecordNotFound when the code or partner does not exist or had nothing
+// in effect at that instant. Any other error is treated as a backend failure.
 type ProductRepo interface {
-	FetchPrice(code string) (price float64, found bool)
-	FetchDiscount(partner string) (discount float64, found bool)
+	FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error)
+	FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList PriceList, err error)
 }
 
 var (
-	ErrInvalidPartner  = errors.New("Invalid Partner Requested")
-	ErrPartnerNotFound = errors.New("Partner Not Found")
-	ErrInvalidCode     = errors.New("Invalid Code Requested")
-	ErrCodeNotFound    = errors.New("Code Not Found")
-	ErrInvalidQty      = errors.New("Invalid Quantity Requested")
+	ErrInvalidPartner  = &Error{Code: INVALID_PARTNER, Msg: "Invalid Partner Requested"}
+	ErrPartnerNotFound = &Error{Code: PARTNER_NOT_FOUND, Msg: "Partner Not Found"}
+	ErrInvalidCode     = &Error{Code: INVALID_CODE, Msg: "Invalid Code Requested"}
+	ErrCodeNotFound    = &Error{Code: CODE_NOT_FOUND, Msg: "Code Not Found"}
+	ErrInvalidQty      = &Error{Code: INVALID_QTY, Msg: "Invalid Quantity Requested"}
+	ErrRepoUnavailable = &Error{Code: REPO_UNAVAILABLE, Msg: "Repository Unavailable"}
+	ErrEmptyQuote      = &Error{Code: EMPTY_QUOTE, Msg: "Empty Quote Requested"}
+	ErrNoPriceTier     = &Error{Code: NO_PRICE_TIER, Msg: "No Price Tier For Quantity"}
+	ErrUnknownCurrency = &Error{Code: UNKNOWN_CURRENCY, Msg: "Unknown Currency"}
+	ErrUnknownRegion   = &Error{Code: UNKNOWN_REGION, Msg: "Unknown Tax Region"}
+	ErrNoTaxRate       = &Error{Code: NO_TAX_RATE, Msg: "No Tax Rate For Product"}
+
+	ErrUnknownCoupon       = &Error{Code: UNKNOWN_COUPON, Msg: "Unknown Coupon"}
+	ErrExpiredCoupon       = &Error{Code: COUPON_EXPIRED, Msg: "Expired Coupon"}
+	ErrCouponNotStarted    = &Error{Code: COUPON_NOT_STARTED, Msg: "Coupon Not Yet Valid"}
+	ErrCouponUsedUp        = &Error{Code: COUPON_USED_UP, Msg: "Coupon Usage Limit Reached"}
+	ErrCouponNotApplicable = &Error{Code: COUPON_NOT_APPLICABLE, Msg: "Coupon Not Applicable"}
+
+	ErrRecordNotFound = errors.New("Record Not Found")
 )
 
 type pricingService struct {
-	repo ProductRepo
+	repo       ProductRepo
+	promotions PromotionRepo
+	fx         FxTable
+	taxes      TaxTable
+	rounding   money.Rounding
 }
 
-func NewPricingService(pr ProductRepo) (ps *pricingService) {
+// NewPricingService prices with exact decimal arithmetic. Totals are rounded
+// to whole minor units of their currency once per line, using rounding,
+// after the quantity tier has been picked, the partner's price list applied
+// and the amount converted at the rates in fx. Coupons are looked up in
+// promotions, which may be nil when there are none, and tax is then added at
+// the rates in taxes.
+func NewPricingService(pr ProductRepo, promotions PromotionRepo, fx FxTable, taxes TaxTable, rounding money.Rounding) (ps *pricingService) {
 	ps = &pricingService{
-		repo: pr,
+		repo:       pr,
+		promotions: promotions,
+		fx:         fx,
+		taxes:      taxes,
+		rounding:   rounding,
 	}
 
 	return ps
 }
 
-func (ps *pricingService) GetRetailTotal(ctx context.Context, code string, qty int) (total float64, err error) {
-	ctx, span := otel.Tracer("Service.Service").Start(ctx, "GetRetailTotal")
-	defer span.End()
-
+// GetRetailTotal applies the coupon of opts, when given, as of its AsOf. The
+// coupon is only checked, and the price is a preview, unless opts carries a
+// RedemptionKey; one use of the coupon is then recorded under that key.
+func (ps *pricingService) GetRetailTotal(ctx context.Context, code string, qty int, opts RetailOptions) (price Price, err error) {
 	if code == "" {
-		return 0.0, ErrInvalidCode
+		return Price{}, ErrInvalidCode
 	}
 	if qty <= 0 {
-		return 0.0, ErrInvalidQty
+		return Price{}, ErrInvalidQty
+	}
+	asOf := opts.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
 	}
 
-	price, found := ps.repo.FetchPrice(code)
-	if !found {
-		return 0.0, ErrCodeNotFound
+	product, err := ps.repo.FetchProduct(ctx, code, asOf)
+	if err != nil {
+		return Price{}, repoError(err, ErrCodeNotFound)
 	}
 
-	total = price * float64(qty)
+	if opts.Coupon == "" {
+		return ps.price(product, qty, PriceList{}, Promotion{}, opts.PriceOptions)
+	}
 
-	return math.Round(total*100) / 100, nil
-}
+	promotion, err := ps.promotion(ctx, opts.Coupon, asOf)
+	if err != nil {
+		return Price{}, err
+	}
 
-func (ps *pricingService) GetWholesaleTotal(ctx context.Context, partner string, code string, qty int) (total float64, err error) {
-	ctx, span := otel.Tracer("Service.Service").Start(ctx, "GetWholesaleTotal")
-	defer span.End()
+	price, err = ps.price(product, qty, PriceList{}, promotion, opts.PriceOptions)
+	if err != nil {
+		return Price{}, err
+	}
+
+	if opts.RedemptionKey == "" {
+		err = ps.available(ctx, promotion)
+	} else {
+		err = ps.redeem(ctx, opts.Coupon, opts.RedemptionKey)
+	}
+	if err != nil {
+		return Price{}, err
+	}
 
+	return price, nil
+}
+
+func (ps *pricingService) GetWholesaleTotal(ctx context.Context, partner string, code string, qty int, opts PriceOptions) (price Price, err error) {
 	if partner == "" {
-		return 0.0, ErrInvalidPartner
+		return Price{}, ErrInvalidPartner
 	}
 	if code == "" {
-		return 0.0, ErrInvalidCode
+		return Price{}, ErrInvalidCode
 	}
 	if qty <= 0 {
-		return 0.0, ErrInvalidQty
+		return Price{}, ErrInvalidQty
+	}
+	asOf := opts.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
 	}
 
-	price, found := ps.repo.FetchPrice(code)
-	if !found {
-		return 0.0, ErrCodeNotFound
+	product, err := ps.repo.FetchProduct(ctx, code, asOf)
+	if err != nil {
+		return Price{}, repoError(err, ErrCodeNotFound)
+	}
+
+	priceList, err := ps.repo.FetchPriceList(ctx, partner, asOf)
+	if err != nil {
+		return Price{}, repoError(err, ErrPartnerNotFound)
+	}
+
+	return ps.price(product, qty, priceList, Promotion{}, opts)
+}
+
+// price resolves the partner's terms for product in PriceList order: a net
+// price is used as is, otherwise the quantity tier is picked and the
+// applicable discount applied to its unit price. Net prices are in the
+// product's currency. The result is converted into the currency of opts,
+// with the total worked out from the unconverted unit price so that it is
+// rounded once. The promotion, if any, is then taken off and the remaining
+// total taxed for the region of opts. With Explain set the price carries a
+// Breakdown of its total, from the line total through the promotion. The
+// AsOf of opts has already been applied by the caller.
+func (ps *pricingService) price(product Product, qty int, priceList PriceList, promotion Promotion, opts PriceOptions) (price Price, err error) {
+	conv, err := ps.fx.Conversion(product.Currency, opts.Currency)
+	if err != nil {
+		return Price{}, err
 	}
 
-	discount, found := ps.repo.FetchDiscount(partner)
-	if !found {
-		return 0.0, ErrPartnerNotFound
+	convert := func(amount money.Amount) money.Amount {
+		return ps.rounding.Converted(amount, 1, 0, conv.Rate, conv.MinorUnits)
 	}
 
-	saved := (price * discount)
-	total = (price - saved) * float64(qty)
+	var (
+		unitPrice money.Amount
+		discount  money.Rate
+	)
+
+	if netPrice, found := priceList.NetPrice(product.Code); found {
+		unitPrice = netPrice
+		price = Price{
+			UnitPrice: convert(netPrice),
+			NetPriced: true,
+			Currency:  conv.Currency,
+			FxRate:    conv.Rate,
+			Total:     ps.rounding.Converted(netPrice, qty, 0, conv.Rate, conv.MinorUnits),
+		}
+	} else {
+		tier, found := product.TierFor(qty)
+		if !found {
+			return Price{}, ErrNoPriceTier
+		}
+
+		unitPrice, discount = tier.Price, priceList.DiscountFor(product.Code)
+		price = Price{
+			UnitPrice: convert(tier.Price),
+			Tier:      Tier{MinQty: tier.MinQty, MaxQty: tier.MaxQty, Price: convert(tier.Price)},
+			Discount:  discount,
+			Currency:  conv.Currency,
+			FxRate:    conv.Rate,
+			Total:     ps.rounding.Converted(tier.Price, qty, discount, conv.Rate, conv.MinorUnits),
+		}
+	}
+
+	lineTotal := price.Total
+
+	if promotion.Coupon != "" {
+		if price, err = ps.promote(price, product, qty, promotion, conv.MinorUnits); err != nil {
+			return Price{}, err
+		}
+	}
+
+	if opts.Explain {
+		price.Breakdown = ps.breakdown(product, unitPrice, qty, discount, conv.Rate, lineTotal, price.Promotion)
+	}
+
+	return ps.tax(price, product, priceList, opts.Region, conv.MinorUnits)
+}
+
+// repoError maps a repo error onto the service errors. Missing records become
+// notFound, and everything else becomes ErrRepoUnavailable, wrapping the
+// cause, except the errors of a context that was canceled or timed out,
+// which are the caller's doing rather than the repo's and are returned
+// unchanged.
+func repoError(err error, notFound error) error {
+	if errors.Is(err, ErrRecordNotFound) {
+		return notFound
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return repoFailure{cause: err}
+}
+
+// repoFailure matches ErrRepoUnavailable while keeping the backend error
+// reachable through errors.Is and errors.As.
+type repoFailure struct {
+	cause error
+}
+
+func (e repoFailure) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRepoUnavailable, e.cause)
+}
+
+func (e repoFailure) Is(target error) bool {
+	return target == ErrRepoUnavailable
+}
+
+// As lets the service error code be found as well as the backend error.
+func (e repoFailure) As(target interface{}) bool {
+	if coded, ok := target.(**Error); ok {
+		*coded = ErrRepoUnavailable
+		return true
+	}
+
+	return false
+}
 
-	return math.Round(total*100) / 100, nil
+func (e repoFailure) Unwrap() error {
+	return e.cause
 }
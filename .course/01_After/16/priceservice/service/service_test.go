@@ -2,20 +2,24 @@ package service
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
 	"github.com/stretchr/testify/assert"
 )
 
 type MockProductRepo struct{}
 
-func (MockProductRepo) FetchPrice(code string) (price float64, found bool) {
+func (MockProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
 	data := []string{
 		"aaa111,12.99",
 		"bbb222,2.90",
 		"ccc333,22.50",
+		"ddd444,12.99,10:11.50,100:10.00",
 	}
 
 	for _, line := range data {
@@ -24,18 +28,25 @@ func (MockProductRepo) FetchPrice(code string) (price float64, found bool) {
 			continue
 		}
 
-		price, _ = strconv.ParseFloat(parts[1], 64)
+		var breaks []Tier
+		for _, part := range parts[2:] {
+			qty, price, _ := strings.Cut(part, ":")
+			minQty, _ := strconv.Atoi(qty)
+			breaks = append(breaks, Tier{MinQty: minQty, Price: money.MustParse(price)})
+		}
 
-		return price, true
+		return NewProduct(code, money.MustParse(parts[1]), breaks...), nil
 	}
 
-	return 0, false
+	return Product{}, ErrRecordNotFound
 }
 
-func (MockProductRepo) FetchDiscount(partner string) (discount float64, found bool) {
+func (MockProductRepo) FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList PriceList, err error) {
 	data := []string{
 		"superstore,0.10",
 		"joesbakery,0.05",
+		"cornershop,0.05,aaa111:0.20,bbb222=2.50,ddd444=9.00",
+		"charity,0.05,exempt",
 	}
 
 	for _, line := range data {
@@ -44,12 +55,54 @@ func (MockProductRepo) FetchDiscount(partner string) (discount float64, found bo
 			continue
 		}
 
-		discount, _ = strconv.ParseFloat(parts[1], 64)
+		priceList = PriceList{
+			Partner:   partner,
+			Discount:  money.MustParseRate(parts[1]),
+			Discounts: map[string]money.Rate{},
+			NetPrices: map[string]money.Amount{},
+		}
+		for _, part := range parts[2:] {
+			if part == "exempt" {
+				priceList.TaxExempt = true
+			}
+			if code, discount, ok := strings.Cut(part, ":"); ok {
+				priceList.Discounts[code] = money.MustParseRate(discount)
+			}
+			if code, price, ok := strings.Cut(part, "="); ok {
+				priceList.NetPrices[code] = money.MustParse(price)
+			}
+		}
 
-		return discount, true
+		return priceList, nil
 	}
 
-	return 0, false
+	return PriceList{}, ErrRecordNotFound
+}
+
+var errConnectionRefused = errors.New("connection refused")
+
+type MockFailingProductRepo struct{}
+
+func (MockFailingProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	return Product{}, errConnectionRefused
+}
+
+func (MockFailingProductRepo) FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList PriceList, err error) {
+	return PriceList{}, errConnectionRefused
+}
+
+// MockContextProductRepo fails with the error of ctx, as a database driver
+// does when its caller gives up.
+type MockContextProductRepo struct{}
+
+func (MockContextProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	<-ctx.Done()
+	return Product{}, ctx.Err()
+}
+
+func (MockContextProductRepo) FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList PriceList, err error) {
+	<-ctx.Done()
+	return PriceList{}, ctx.Err()
 }
 
 func Test_GetRetailTotal(t *testing.T) {
@@ -59,42 +112,42 @@ func Test_GetRetailTotal(t *testing.T) {
 		code  string
 		qty   int
 		err   error
-		total float64
+		total money.Amount
 	}{
 		{
 			code:  "",
 			qty:   0,
 			err:   ErrInvalidCode,
-			total: 0.0,
+			total: 0,
 		},
 		{
 			code:  "aaa111",
 			qty:   0,
 			err:   ErrInvalidQty,
-			total: 0.0,
+			total: 0,
 		},
 		{
 			code:  "aaa111",
 			qty:   15,
 			err:   nil,
-			total: 194.85,
+			total: money.MustParse("194.85"),
 		},
 		{
 			code:  "fff000",
 			qty:   10,
 			err:   ErrCodeNotFound,
-			total: 0.0,
+			total: 0,
 		},
 	}
 
 	mockProductRepo := new(MockProductRepo)
 
-	priceService := NewPricingService(mockProductRepo)
+	priceService := NewPricingService(mockProductRepo, nil, FxTable{}, TaxTable{}, money.HalfUp)
 
 	for id, test := range tests {
-		total, err := priceService.GetRetailTotal(ctx, test.code, test.qty)
+		price, err := priceService.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{})
 		assert.True(t, test.err == err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
-		assert.True(t, test.total == total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, test.total, total)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
 	}
 }
 
@@ -106,66 +159,404 @@ func Test_GetWholesaleTotal(t *testing.T) {
 		code    string
 		qty     int
 		err     error
-		total   float64
+		total   money.Amount
 	}{
 		{
 			partner: "",
 			code:    "",
 			qty:     0,
 			err:     ErrInvalidPartner,
-			total:   0.0,
+			total:   0,
 		},
 		{
 			partner: "superstore",
 			code:    "",
 			qty:     0,
 			err:     ErrInvalidCode,
-			total:   0.0,
+			total:   0,
 		},
 		{
 			partner: "superstore",
 			code:    "bbb222",
 			qty:     0,
 			err:     ErrInvalidQty,
-			total:   0.0,
+			total:   0,
 		},
 		{
 			partner: "superstore",
 			code:    "bbb222",
 			qty:     15,
 			err:     nil,
-			total:   39.15,
+			total:   money.MustParse("39.15"),
 		},
 		{
 			partner: "joesbakery",
 			code:    "bbb222",
 			qty:     15,
 			err:     nil,
-			total:   41.33,
+			total:   money.MustParse("41.33"),
 		},
 		{
 			partner: "jesscafe",
 			code:    "bbb222",
 			qty:     10,
 			err:     ErrPartnerNotFound,
-			total:   0.0,
+			total:   0,
 		},
 		{
 			partner: "superstore",
 			code:    "xyz123",
 			qty:     10,
 			err:     ErrCodeNotFound,
-			total:   0.0,
+			total:   0,
 		},
 	}
 
 	mockProductRepo := new(MockProductRepo)
 
-	priceService := NewPricingService(mockProductRepo)
+	priceService := NewPricingService(mockProductRepo, nil, FxTable{}, TaxTable{}, money.HalfUp)
 
 	for id, test := range tests {
-		total, err := priceService.GetWholesaleTotal(ctx, test.partner, test.code, test.qty)
+		price, err := priceService.GetWholesaleTotal(ctx, test.partner, test.code, test.qty, PriceOptions{})
 		assert.True(t, test.err == err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
-		assert.True(t, test.total == total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, test.total, total)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+	}
+}
+
+func Test_RepoUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	mockProductRepo := new(MockFailingProductRepo)
+
+	priceService := NewPricingService(mockProductRepo, nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	_, err := priceService.GetRetailTotal(ctx, "aaa111", 10, RetailOptions{})
+	assert.True(t, errors.Is(err, ErrRepoUnavailable), "~2|Test retail expected error: %s, not error %s~", ErrRepoUnavailable, err)
+	assert.True(t, errors.Is(err, errConnectionRefused), "~2|Test retail expected cause: %s, not error %s~", errConnectionRefused, err)
+	assert.True(t, !errors.Is(err, ErrCodeNotFound), "~2|Test retail expected error other than: %s~", ErrCodeNotFound)
+
+	_, err = priceService.GetWholesaleTotal(ctx, "superstore", "aaa111", 10, PriceOptions{})
+	assert.True(t, errors.Is(err, ErrRepoUnavailable), "~2|Test wholesale expected error: %s, not error %s~", ErrRepoUnavailable, err)
+	assert.True(t, !errors.Is(err, ErrPartnerNotFound), "~2|Test wholesale expected error other than: %s~", ErrPartnerNotFound)
+}
+
+func Test_ContextEnded(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		ctx  context.Context
+		err  error
+		code Code
+	}{
+		{ctx: canceled, err: context.Canceled, code: CANCELED},
+		{ctx: expired, err: context.DeadlineExceeded, code: TIMEOUT},
+	}
+
+	priceService := NewPricingService(new(MockContextProductRepo), nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		_, err := priceService.GetRetailTotal(test.ctx, "aaa111", 10, RetailOptions{})
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d retail expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, !errors.Is(err, ErrRepoUnavailable), "~2|Test #%d retail expected error other than: %s~", id, ErrRepoUnavailable)
+		assert.True(t, test.code == ErrorCode(err), "~2|Test #%d retail expected code: %s, not code %s~", id, test.code, ErrorCode(err))
+
+		_, err = priceService.GetWholesaleTotal(test.ctx, "superstore", "aaa111", 10, PriceOptions{})
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d wholesale expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, !errors.Is(err, ErrRepoUnavailable), "~2|Test #%d wholesale expected error other than: %s~", id, ErrRepoUnavailable)
+	}
+}
+
+func Test_GetWholesaleTotal_HalfEven(t *testing.T) {
+	ctx := context.Background()
+
+	priceService := NewPricingService(new(MockProductRepo), nil, FxTable{}, TaxTable{}, money.HalfEven)
+
+	price, err := priceService.GetWholesaleTotal(ctx, "joesbakery", "bbb222", 15, PriceOptions{})
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	assert.True(t, price.Total == money.MustParse("41.32"), "~2|Test expected total: 41.32, not total %s~", price.Total)
+}
+
+func Test_GetTotal_Tiers(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner string
+		qty     int
+		tier    Tier
+		total   money.Amount
+	}{
+		{
+			partner: "",
+			qty:     9,
+			tier:    Tier{MinQty: 1, MaxQty: 9, Price: money.MustParse("12.99")},
+			total:   money.MustParse("116.91"),
+		},
+		{
+			partner: "",
+			qty:     10,
+			tier:    Tier{MinQty: 10, MaxQty: 99, Price: money.MustParse("11.50")},
+			total:   money.MustParse("115.00"),
+		},
+		{
+			partner: "",
+			qty:     100,
+			tier:    Tier{MinQty: 100, MaxQty: 0, Price: money.MustParse("10.00")},
+			total:   money.MustParse("1000.00"),
+		},
+		{
+			partner: "superstore",
+			qty:     10,
+			tier:    Tier{MinQty: 10, MaxQty: 99, Price: money.MustParse("11.50")},
+			total:   money.MustParse("103.50"),
+		},
+		{
+			partner: "superstore",
+			qty:     250,
+			tier:    Tier{MinQty: 100, MaxQty: 0, Price: money.MustParse("10.00")},
+			total:   money.MustParse("2250.00"),
+		},
+	}
+
+	priceService := NewPricingService(new(MockProductRepo), nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		var price Price
+		var err error
+		if test.partner == "" {
+			price, err = priceService.GetRetailTotal(ctx, "ddd444", test.qty, RetailOptions{})
+		} else {
+			price, err = priceService.GetWholesaleTotal(ctx, test.partner, "ddd444", test.qty, PriceOptions{})
+		}
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.tier == price.Tier, "~2|Test #%d expected tier: %v, not tier %v~", id, test.tier, price.Tier)
+		assert.True(t, test.tier.Price == price.UnitPrice, "~2|Test #%d expected unit price: %s, not unit price %s~", id, test.tier.Price, price.UnitPrice)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+	}
+}
+
+func Test_GetWholesaleTotal_PriceList(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		code  string
+		qty   int
+		price Price
+	}{
+		{
+			code: "ccc333",
+			qty:  2,
+			price: Price{
+				UnitPrice: money.MustParse("22.50"),
+				Tier:      Tier{MinQty: 1, MaxQty: 0, Price: money.MustParse("22.50")},
+				Discount:  money.MustParseRate("0.05"),
+				FxRate:    money.RateScale,
+				Total:     money.MustParse("42.75"),
+				Net:       money.MustParse("42.75"),
+				Gross:     money.MustParse("42.75"),
+			},
+		},
+		{
+			code: "aaa111",
+			qty:  10,
+			price: Price{
+				UnitPrice: money.MustParse("12.99"),
+				Tier:      Tier{MinQty: 1, MaxQty: 0, Price: money.MustParse("12.99")},
+				Discount:  money.MustParseRate("0.20"),
+				FxRate:    money.RateScale,
+				Total:     money.MustParse("103.92"),
+				Net:       money.MustParse("103.92"),
+				Gross:     money.MustParse("103.92"),
+			},
+		},
+		{
+			code: "bbb222",
+			qty:  15,
+			price: Price{
+				UnitPrice: money.MustParse("2.50"),
+				NetPriced: true,
+				FxRate:    money.RateScale,
+				Total:     money.MustParse("37.50"),
+				Net:       money.MustParse("37.50"),
+				Gross:     money.MustParse("37.50"),
+			},
+		},
+		{
+			code: "ddd444",
+			qty:  100,
+			price: Price{
+				UnitPrice: money.MustParse("9.00"),
+				NetPriced: true,
+				FxRate:    money.RateScale,
+				Total:     money.MustParse("900.00"),
+				Net:       money.MustParse("900.00"),
+				Gross:     money.MustParse("900.00"),
+			},
+		},
+	}
+
+	priceService := NewPricingService(new(MockProductRepo), nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		price, err := priceService.GetWholesaleTotal(ctx, "cornershop", test.code, test.qty, PriceOptions{})
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.price == price, "~2|Test #%d expected price: %+v, not price %+v~", id, test.price, price)
+	}
+}
+
+// MockDatedProductRepo changes every price on priceChange and records the
+// instant each lookup was made for.
+type MockDatedProductRepo struct {
+	MockProductRepo
+	asOf []time.Time
+}
+
+var priceChange = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func (mr *MockDatedProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	mr.asOf = append(mr.asOf, asOf)
+
+	if asOf.Before(priceChange) {
+		return NewProduct(code, money.MustParse("5.00")), nil
+	}
+
+	return NewProduct(code, money.MustParse("6.00")), nil
+}
+
+func (mr *MockDatedProductRepo) FetchPriceList(ctx context.Context, partner string, asOf time.Time) (priceList PriceList, err error) {
+	mr.asOf = append(mr.asOf, asOf)
+
+	return mr.MockProductRepo.FetchPriceList(ctx, partner, asOf)
+}
+
+func Test_GetTotal_AsOf(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner string
+		asOf    time.Time
+		total   money.Amount
+	}{
+		{
+			partner: "",
+			asOf:    priceChange.Add(-time.Second),
+			total:   money.MustParse("50.00"),
+		},
+		{
+			partner: "",
+			asOf:    priceChange,
+			total:   money.MustParse("60.00"),
+		},
+		{
+			partner: "superstore",
+			asOf:    priceChange.Add(-time.Second),
+			total:   money.MustParse("45.00"),
+		},
+		{
+			partner: "superstore",
+			asOf:    time.Time{},
+			total:   money.MustParse("54.00"),
+		},
+	}
+
+	for id, test := range tests {
+		mockProductRepo := new(MockDatedProductRepo)
+		priceService := NewPricingService(mockProductRepo, nil, FxTable{}, TaxTable{}, money.HalfUp)
+
+		var price Price
+		var err error
+		before := time.Now()
+		if test.partner == "" {
+			price, err = priceService.GetRetailTotal(ctx, "aaa111", 10, RetailOptions{PriceOptions: PriceOptions{AsOf: test.asOf}})
+		} else {
+			price, err = priceService.GetWholesaleTotal(ctx, test.partner, "aaa111", 10, PriceOptions{AsOf: test.asOf})
+		}
+		assert.True(t, err == nil, "~2|Test #%d expected no error, not error %s~", id, err)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+
+		for _, asOf := range mockProductRepo.asOf {
+			if test.asOf.IsZero() {
+				assert.True(t, !asOf.Before(before), "~2|Test #%d expected lookup as of now, not as of %s~", id, asOf)
+			} else {
+				assert.True(t, asOf.Equal(test.asOf), "~2|Test #%d expected lookup as of %s, not as of %s~", id, test.asOf, asOf)
+			}
+		}
+	}
+}
+
+// MockCurrencyProductRepo prices fff555 in JPY and every other product in
+// the base currency.
+type MockCurrencyProductRepo struct {
+	MockProductRepo
+}
+
+func (m MockCurrencyProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	if code == "fff555" {
+		product = NewProduct(code, money.MustParse("1500"))
+		product.Currency = "JPY"
+
+		return product, nil
 	}
+
+	return m.MockProductRepo.FetchProduct(ctx, code, asOf)
+}
+
+var mockFxTable = FxTable{
+	Base: "USD",
+	Rates: map[string]FxRate{
+		"USD": {Currency: "USD", MinorUnits: 2, Rate: money.MustParseRate("1")},
+		"EUR": {Currency: "EUR", MinorUnits: 2, Rate: money.MustParseRate("0.92")},
+		"JPY": {Currency: "JPY", MinorUnits: 0, Rate: money.MustParseRate("151.3")},
+	},
+}
+
+func Test_GetTotal_Currency(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner   string
+		code      string
+		qty       int
+		currency  string
+		err       error
+		unitPrice money.Amount
+		fxRate    money.Rate
+		total     money.Amount
+		converted string
+	}{
+		{partner: "", code: "aaa111", qty: 15, currency: "", unitPrice: money.MustParse("12.99"), fxRate: money.MustParseRate("1"), total: money.MustParse("194.85"), converted: "USD"},
+		{partner: "", code: "aaa111", qty: 15, currency: "EUR", unitPrice: money.MustParse("11.95"), fxRate: money.MustParseRate("0.92"), total: money.MustParse("179.26"), converted: "EUR"},
+		{partner: "", code: "aaa111", qty: 15, currency: "JPY", unitPrice: money.MustParse("1965"), fxRate: money.MustParseRate("151.3"), total: money.MustParse("29481"), converted: "JPY"},
+		{partner: "", code: "fff555", qty: 2, currency: "", unitPrice: money.MustParse("1500"), fxRate: money.MustParseRate("1"), total: money.MustParse("3000"), converted: "JPY"},
+		{partner: "", code: "fff555", qty: 2, currency: "USD", unitPrice: money.MustParse("9.91"), fxRate: money.MustParseRate("0.006609"), total: money.MustParse("19.83"), converted: "USD"},
+		{partner: "superstore", code: "aaa111", qty: 15, currency: "EUR", unitPrice: money.MustParse("11.95"), fxRate: money.MustParseRate("0.92"), total: money.MustParse("161.34"), converted: "EUR"},
+		{partner: "", code: "aaa111", qty: 15, currency: "GBP", err: ErrUnknownCurrency},
+		{partner: "superstore", code: "fff555", qty: 1, currency: "GBP", err: ErrUnknownCurrency},
+	}
+
+	priceService := NewPricingService(new(MockCurrencyProductRepo), nil, mockFxTable, TaxTable{}, money.HalfUp)
+
+	for id, test := range tests {
+		var price Price
+		var err error
+		if test.partner == "" {
+			price, err = priceService.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{PriceOptions: PriceOptions{Currency: test.currency}})
+		} else {
+			price, err = priceService.GetWholesaleTotal(ctx, test.partner, test.code, test.qty, PriceOptions{Currency: test.currency})
+		}
+
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.unitPrice == price.UnitPrice, "~2|Test #%d expected unit price: %s, not unit price %s~", id, test.unitPrice, price.UnitPrice)
+		assert.True(t, test.fxRate == price.FxRate, "~2|Test #%d expected fx rate: %s, not fx rate %s~", id, test.fxRate, price.FxRate)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+		assert.True(t, test.converted == price.Currency, "~2|Test #%d expected currency: %s, not currency %s~", id, test.converted, price.Currency)
+	}
+
+	_, err := priceService.GetRetailTotal(ctx, "aaa111", 1, RetailOptions{PriceOptions: PriceOptions{Currency: "GBP"}})
+
+	var currencyErr *CurrencyError
+	assert.True(t, errors.As(err, &currencyErr) && currencyErr.Currency == "GBP", "~2|Test expected a currency error for GBP, not error %s~", err)
 }
@@ -0,0 +1,65 @@
+package service
+
+import "github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+
+// StandardTaxClass is the tax class of products that do not name one.
+const StandardTaxClass = "standard"
+
+// TaxTable holds the tax rates of each region by product tax class.
+//
+// The zero TaxTable knows no regions, so only untaxed prices can be asked
+// for.
+type TaxTable struct {
+	Rates map[string]map[string]money.Rate
+}
+
+// Rate returns the rate at which products of class are taxed in region. A
+// blank class is StandardTaxClass. It fails with ErrUnknownRegion when the
+// region has no rates at all and with ErrNoTaxRate when it has none for
+// class.
+func (tt TaxTable) Rate(region string, class string) (rate money.Rate, err error) {
+	if class == "" {
+		class = StandardTaxClass
+	}
+
+	classes, found := tt.Rates[region]
+	if !found {
+		return 0, ErrUnknownRegion
+	}
+
+	rate, found = classes[class]
+	if !found {
+		return 0, ErrNoTaxRate
+	}
+
+	return rate, nil
+}
+
+// tax adds the tax owed in region to price. Tax is worked out on the rounded
+// net total of the line and rounded once, to minorUnits decimal places.
+// Without a region, or for a tax-exempt partner, the gross total equals the
+// net total.
+func (ps *pricingService) tax(price Price, product Product, priceList PriceList, region string, minorUnits int) (taxed Price, err error) {
+	taxed = price
+	taxed.Region = region
+	taxed.Net = price.Total
+	taxed.Gross = price.Total
+
+	if region == "" {
+		return taxed, nil
+	}
+
+	rate, err := ps.taxes.Rate(region, product.TaxClass)
+	if err != nil {
+		return Price{}, err
+	}
+	if priceList.TaxExempt {
+		return taxed, nil
+	}
+
+	taxed.TaxRate = rate
+	taxed.Tax = ps.rounding.Converted(price.Total, 1, 0, rate, minorUnits)
+	taxed.Gross = price.Total.Add(taxed.Tax)
+
+	return taxed, nil
+}
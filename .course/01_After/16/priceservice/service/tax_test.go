@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockTaxedProductRepo adds book01 in the reduced tax class to the mock
+// catalog.
+type MockTaxedProductRepo struct {
+	MockProductRepo
+}
+
+func (m MockTaxedProductRepo) FetchProduct(ctx context.Context, code string, asOf time.Time) (product Product, err error) {
+	if code == "book01" {
+		product = NewProduct(code, money.MustParse("20.00"))
+		product.TaxClass = "reduced"
+
+		return product, nil
+	}
+
+	return m.MockProductRepo.FetchProduct(ctx, code, asOf)
+}
+
+var mockTaxTable = TaxTable{
+	Rates: map[string]map[string]money.Rate{
+		"uk": {"standard": money.MustParseRate("0.20"), "reduced": money.MustParseRate("0.05")},
+		"de": {"standard": money.MustParseRate("0.19")},
+	},
+}
+
+func Test_TaxTable_Rate(t *testing.T) {
+	tests := []struct {
+		region string
+		class  string
+		err    error
+		rate   money.Rate
+	}{
+		{region: "uk", class: "", rate: money.MustParseRate("0.20")},
+		{region: "uk", class: "standard", rate: money.MustParseRate("0.20")},
+		{region: "uk", class: "reduced", rate: money.MustParseRate("0.05")},
+		{region: "de", class: "reduced", err: ErrNoTaxRate},
+		{region: "fr", class: "", err: ErrUnknownRegion},
+	}
+
+	for id, test := range tests {
+		rate, err := mockTaxTable.Rate(test.region, test.class)
+		assert.True(t, test.err == err, "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.rate == rate, "~2|Test #%d expected rate: %s, not rate %s~", id, test.rate, rate)
+	}
+}
+
+func Test_GetTotal_Tax(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner  string
+		code     string
+		qty      int
+		currency string
+		region   string
+		err      error
+		total    money.Amount
+		taxRate  money.Rate
+		tax      money.Amount
+		gross    money.Amount
+	}{
+		{code: "aaa111", qty: 15, region: "", total: money.MustParse("194.85"), gross: money.MustParse("194.85")},
+		{code: "aaa111", qty: 15, region: "uk", total: money.MustParse("194.85"), taxRate: money.MustParseRate("0.20"), tax: money.MustParse("38.97"), gross: money.MustParse("233.82")},
+		{code: "book01", qty: 3, region: "uk", total: money.MustParse("60.00"), taxRate: money.MustParseRate("0.05"), tax: money.MustParse("3.00"), gross: money.MustParse("63.00")},
+		{code: "aaa111", qty: 15, currency: "JPY", region: "uk", total: money.MustParse("29481"), taxRate: money.MustParseRate("0.20"), tax: money.MustParse("5896"), gross: money.MustParse("35377")},
+		{code: "book01", qty: 3, region: "de", err: ErrNoTaxRate},
+		{code: "aaa111", qty: 1, region: "fr", err: ErrUnknownRegion},
+		{partner: "superstore", code: "aaa111", qty: 15, region: "uk", total: money.MustParse("175.37"), taxRate: money.MustParseRate("0.20"), tax: money.MustParse("35.07"), gross: money.MustParse("210.44")},
+		{partner: "charity", code: "aaa111", qty: 15, region: "uk", total: money.MustParse("185.11"), gross: money.MustParse("185.11")},
+		{partner: "charity", code: "aaa111", qty: 15, region: "fr", err: ErrUnknownRegion},
+	}
+
+	priceService := NewPricingService(new(MockTaxedProductRepo), nil, mockFxTable, mockTaxTable, money.HalfUp)
+
+	for id, test := range tests {
+		var price Price
+		var err error
+		if test.partner == "" {
+			price, err = priceService.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{PriceOptions: PriceOptions{Currency: test.currency, Region: test.region}})
+		} else {
+			price, err = priceService.GetWholesaleTotal(ctx, test.partner, test.code, test.qty, PriceOptions{Currency: test.currency, Region: test.region})
+		}
+
+		assert.True(t, errors.Is(err, test.err), "~2|Test #%d expected error: %s, not error %s~", id, test.err, err)
+		assert.True(t, test.total == price.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.total, price.Total)
+		assert.True(t, test.taxRate == price.TaxRate, "~2|Test #%d expected tax rate: %s, not tax rate %s~", id, test.taxRate, price.TaxRate)
+		assert.True(t, test.tax == price.Tax, "~2|Test #%d expected tax: %s, not tax %s~", id, test.tax, price.Tax)
+		assert.True(t, test.gross == price.Gross, "~2|Test #%d expected gross: %s, not gross %s~", id, test.gross, price.Gross)
+	}
+}
+
+func Test_GetQuote_Tax(t *testing.T) {
+	ctx := context.Background()
+
+	priceService := NewPricingService(new(MockTaxedProductRepo), nil, FxTable{}, mockTaxTable, money.HalfUp)
+
+	lines := []QuoteLine{{Code: "aaa111", Qty: 1}, {Code: "book01", Qty: 2}, {Code: "xyz123", Qty: 1}}
+	quote, err := priceService.GetQuote(ctx, "", "", "uk", lines)
+	assert.True(t, err == nil, "~2|Test expected no error, not error %s~", err)
+	assert.True(t, quote.Total == money.MustParse("52.99"), "~2|Test expected total: 52.99, not total %s~", quote.Total)
+	assert.True(t, quote.Tax == money.MustParse("4.60"), "~2|Test expected tax: 4.60, not tax %s~", quote.Tax)
+	assert.True(t, quote.Gross == money.MustParse("57.59"), "~2|Test expected gross: 57.59, not gross %s~", quote.Gross)
+
+	_, err = priceService.GetQuote(ctx, "", "", "fr", lines)
+	assert.True(t, err == ErrUnknownRegion, "~2|Test expected error: %s, not error %s~", ErrUnknownRegion, err)
+}
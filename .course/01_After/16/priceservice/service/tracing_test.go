@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newRecordingTracer() (provider *sdktrace.TracerProvider, recorder *tracetest.SpanRecorder) {
+	recorder = tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) (attrs map[attribute.Key]string) {
+	attrs = make(map[attribute.Key]string)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value.Emit()
+	}
+
+	return attrs
+}
+
+func Test_Tracing_GetRetailTotal(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		code      string
+		qty       int
+		status    codes.Code
+		total     string
+		errorCode string
+	}{
+		{code: "aaa111", qty: 15, status: codes.Unset, total: "194.85"},
+		{code: "aaa111", qty: 0, status: codes.Error, errorCode: string(INVALID_QTY)},
+		{code: "fff000", qty: 1, status: codes.Error, errorCode: string(CODE_NOT_FOUND)},
+	}
+
+	provider, recorder := newRecordingTracer()
+
+	var svc PricingService
+	svc = new(MockPricingService)
+	svc = NewTracingMiddleware(provider.Tracer("Service.Service"), svc)
+
+	for id, test := range tests {
+		svc.GetRetailTotal(ctx, test.code, test.qty, RetailOptions{})
+
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+		attrs := spanAttributes(span)
+
+		assert.True(t, span.Name() == "GetRetailTotal", "~2|Test #%d expected name: GetRetailTotal, not name %s~", id, span.Name())
+		assert.True(t, test.status == span.Status().Code, "~2|Test #%d expected status: %s, not status %s~", id, test.status, span.Status().Code)
+		assert.True(t, test.code == attrs[pricingCode], "~2|Test #%d expected code: %s, not code %s~", id, test.code, attrs[pricingCode])
+		assert.True(t, test.total == attrs[pricingTotal], "~2|Test #%d expected total: %s, not total %s~", id, test.total, attrs[pricingTotal])
+		assert.True(t, test.errorCode == attrs[pricingErrorCode], "~2|Test #%d expected error code: %s, not error code %s~", id, test.errorCode, attrs[pricingErrorCode])
+	}
+}
+
+func Test_Tracing_GetWholesaleTotal(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner   string
+		code      string
+		qty       int
+		status    codes.Code
+		total     string
+		errorCode string
+	}{
+		{partner: "superstore", code: "aaa111", qty: 10, status: codes.Unset, total: "110.42"},
+		{partner: "", code: "aaa111", qty: 10, status: codes.Error, errorCode: string(INVALID_PARTNER)},
+		{partner: "nobody", code: "aaa111", qty: 10, status: codes.Error, errorCode: string(PARTNER_NOT_FOUND)},
+	}
+
+	provider, recorder := newRecordingTracer()
+
+	var svc PricingService
+	svc = new(MockPricingService)
+	svc = NewTracingMiddleware(provider.Tracer("Service.Service"), svc)
+
+	for id, test := range tests {
+		svc.GetWholesaleTotal(ctx, test.partner, test.code, test.qty, PriceOptions{})
+
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+		attrs := spanAttributes(span)
+
+		assert.True(t, span.Name() == "GetWholesaleTotal", "~2|Test #%d expected name: GetWholesaleTotal, not name %s~", id, span.Name())
+		assert.True(t, test.status == span.Status().Code, "~2|Test #%d expected status: %s, not status %s~", id, test.status, span.Status().Code)
+		assert.True(t, test.partner == attrs[pricingPartner], "~2|Test #%d expected partner: %s, not partner %s~", id, test.partner, attrs[pricingPartner])
+		assert.True(t, test.total == attrs[pricingTotal], "~2|Test #%d expected total: %s, not total %s~", id, test.total, attrs[pricingTotal])
+		assert.True(t, test.errorCode == attrs[pricingErrorCode], "~2|Test #%d expected error code: %s, not error code %s~", id, test.errorCode, attrs[pricingErrorCode])
+	}
+}
+
+func Test_Tracing_GetQuote(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		partner   string
+		lines     []QuoteLine
+		status    codes.Code
+		total     string
+		errorCode string
+	}{
+		{partner: "superstore", lines: []QuoteLine{{Code: "aaa111", Qty: 15}, {Code: "fff000", Qty: 1}}, status: codes.Unset, total: "165.62"},
+		{partner: "", lines: nil, status: codes.Error, errorCode: string(EMPTY_QUOTE)},
+	}
+
+	provider, recorder := newRecordingTracer()
+
+	var svc PricingService
+	svc = new(MockPricingService)
+	svc = NewTracingMiddleware(provider.Tracer("Service.Service"), svc)
+
+	for id, test := range tests {
+		svc.GetQuote(ctx, test.partner, "", "", test.lines)
+
+		spans := recorder.Ended()
+		span := spans[len(spans)-1]
+		attrs := spanAttributes(span)
+
+		assert.True(t, span.Name() == "GetQuote", "~2|Test #%d expected name: GetQuote, not name %s~", id, span.Name())
+		assert.True(t, test.status == span.Status().Code, "~2|Test #%d expected status: %s, not status %s~", id, test.status, span.Status().Code)
+		assert.True(t, test.total == attrs[pricingTotal], "~2|Test #%d expected total: %s, not total %s~", id, test.total, attrs[pricingTotal])
+		assert.True(t, test.errorCode == attrs[pricingErrorCode], "~2|Test #%d expected error code: %s, not error code %s~", id, test.errorCode, attrs[pricingErrorCode])
+	}
+}
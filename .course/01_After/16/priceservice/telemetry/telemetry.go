@@ -0,0 +1,241 @@
+// Package telemetry sets up the tracer provider of a service: where its
+// spans are exported to, which traces are sampled and the resource the
+// spans are attributed to.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strconv"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+const (
+	EXPORTER_NONE      = "none"
+	EXPORTER_FILE      = "file"
+	EXPORTER_STDOUT    = "stdout"
+	EXPORTER_OTLP_HTTP = "otlp-http"
+
+	SAMPLER_ALWAYS       = "always"
+	SAMPLER_RATIO        = "ratio"
+	SAMPLER_PARENT_BASED = "parent-based"
+)
+
+// Config chooses how spans are exported and sampled. File is the file the
+// file exporter appends to, and Endpoint the host:port of the collector
+// the otlp-http exporter sends to, with Insecure for plain HTTP. Ratio is
+// the fraction of traces the ratio sampler keeps; the parent-based sampler
+// follows the sampling decision of the caller and keeps that fraction of
+// the traces it starts.
+type Config struct {
+	ServiceName string
+	Environment string
+	Exporter    string
+	File        string
+	Endpoint    string
+	Insecure    bool
+	Sampler     string
+	Ratio       float64
+}
+
+// DefaultConfig appends every trace to traces.txt, or takes its settings
+// from the environment where they are set there.
+var DefaultConfig = Config{
+	Environment: Getenv("DEPLOYMENT_ENVIRONMENT", "development"),
+	Exporter:    Getenv("TRACES_EXPORTER", EXPORTER_FILE),
+	File:        Getenv("TRACES_FILE", "traces.txt"),
+	Endpoint:    Getenv("TRACES_OTLP_ENDPOINT", "localhost:4318"),
+	Insecure:    Getenv("TRACES_OTLP_INSECURE", "true") == "true",
+	Sampler:     Getenv("TRACES_SAMPLER", SAMPLER_PARENT_BASED),
+	Ratio:       getenvFloat("TRACES_SAMPLER_RATIO", 1),
+}
+
+// Getenv returns the environment variable key, or fallback when it is not
+// set.
+func Getenv(key string, fallback string) string {
+	if value, found := os.LookupEnv(key); found {
+		return value
+	}
+
+	return fallback
+}
+
+func getenvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(Getenv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// Validate reports an exporter or sampler that is not known, and a ratio
+// outside 0 to 1.
+func (c Config) Validate() (err error) {
+	switch c.Exporter {
+	case EXPORTER_NONE, EXPORTER_FILE, EXPORTER_STDOUT, EXPORTER_OTLP_HTTP:
+	default:
+		return fmt.Errorf("unknown trace exporter %q, expected %s, %s, %s or %s", c.Exporter,
+			EXPORTER_NONE, EXPORTER_FILE, EXPORTER_STDOUT, EXPORTER_OTLP_HTTP)
+	}
+
+	switch c.Sampler {
+	case SAMPLER_ALWAYS, SAMPLER_RATIO, SAMPLER_PARENT_BASED:
+	default:
+		return fmt.Errorf("unknown trace sampler %q, expected %s, %s or %s", c.Sampler,
+			SAMPLER_ALWAYS, SAMPLER_RATIO, SAMPLER_PARENT_BASED)
+	}
+
+	if c.Ratio < 0 || c.Ratio > 1 {
+		return fmt.Errorf("trace sample ratio %v is not between 0 and 1", c.Ratio)
+	}
+
+	return nil
+}
+
+// NewTracerProvider returns a tracer provider that exports and samples as
+// config sets. Its spans are batched, and the batch still pending is sent
+// by shutdown, which must be called before the service exits.
+func NewTracerProvider(ctx context.Context, config Config) (tp *sdktrace.TracerProvider, shutdown func(context.Context) error, err error) {
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	r, err := newResource(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(newSampler(config)),
+		sdktrace.WithResource(r),
+	}
+
+	exp, closer, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exp != nil {
+		options = append(options, sdktrace.WithBatcher(exp))
+	}
+
+	tp = sdktrace.NewTracerProvider(options...)
+
+	shutdown = func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+
+		return err
+	}
+
+	return tp, shutdown, nil
+}
+
+// newExporter returns the exporter of config, and the file it writes to
+// when there is one. The none exporter is nil: spans are still made, so
+// that trace context is passed on, but are not sent anywhere.
+func newExporter(ctx context.Context, config Config) (exp sdktrace.SpanExporter, closer io.Closer, err error) {
+	switch config.Exporter {
+	case EXPORTER_FILE:
+		// Traces are appended, so that those of earlier runs are kept.
+		f, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+
+		return exp, f, nil
+	case EXPORTER_STDOUT:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		return exp, nil, err
+	case EXPORTER_OTLP_HTTP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+
+		exp, err = otlptracehttp.New(ctx, options...)
+		return exp, nil, err
+	}
+
+	return nil, nil, nil
+}
+
+func newSampler(config Config) sdktrace.Sampler {
+	switch config.Sampler {
+	case SAMPLER_ALWAYS:
+		return sdktrace.AlwaysSample()
+	case SAMPLER_RATIO:
+		return sdktrace.TraceIDRatioBased(config.Ratio)
+	default:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.Ratio))
+	}
+}
+
+// newResource describes the service on top of the default resource. The
+// semantic conventions must be those of the SDK, as resources of different
+// schemas do not merge.
+func newResource(config Config) (r *resource.Resource, err error) {
+	return resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(config.ServiceName),
+			semconv.ServiceVersionKey.String(Version()),
+			semconv.DeploymentEnvironmentKey.String(config.Environment),
+		),
+	)
+}
+
+// Version returns the version the service was built at: the module
+// version when it was installed from a tagged release, otherwise the VCS
+// revision it was built from, marked when the tree had changes.
+func Version() (version string) {
+	info, found := debug.ReadBuildInfo()
+	if !found {
+		return "unknown"
+	}
+
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+
+	if revision == "" {
+		return "(devel)"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+
+	return revision
+}
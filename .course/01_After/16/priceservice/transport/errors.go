@@ -0,0 +1,68 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// detailer is implemented by errors that can say which request values they
+// are about, such as *service.CouponError.
+type detailer interface {
+	Details() map[string]string
+}
+
+// encodeError is the ServerErrorEncoder of every handler. It writes err as
+// an ErrorResponse with the HTTP status of its code.
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	httptransport.DefaultErrorEncoder(ctx, makeErrorResponse(err), w)
+}
+
+// makeErrorResponse wraps err in the error envelope. An *ErrorResponse, such
+// as one relayed from an upstream pricing service, is passed on unchanged.
+func makeErrorResponse(err error) *ErrorResponse {
+	var resp *ErrorResponse
+	if errors.As(err, &resp) {
+		return resp
+	}
+
+	resp = &ErrorResponse{Code: service.ErrorCode(err), Message: err.Error()}
+
+	var d detailer
+	if errors.As(err, &d) {
+		resp.Details = d.Details()
+	}
+
+	return resp
+}
+
+// statusClientClosedRequest is the non-standard status, first used by
+// nginx, of a request whose client went away before it was answered.
+const statusClientClosedRequest = 499
+
+// errorStatus maps an error code onto its HTTP status: 400 for requests that
+// are malformed, 404 for products and partners that do not exist, 422 for
+// well-formed requests that cannot be priced, 499 and 408 for requests
+// canceled or timed out by their caller and 500 for everything else. As
+// 4xx statuses the last two are neither retried nor counted against the
+// instance by the proxy.
+func errorStatus(code service.Code) int {
+	switch code {
+	case service.INVALID_REQUEST, service.INVALID_PARTNER, service.INVALID_CODE, service.INVALID_QTY, service.EMPTY_QUOTE:
+		return http.StatusBadRequest
+	case service.PARTNER_NOT_FOUND, service.CODE_NOT_FOUND:
+		return http.StatusNotFound
+	case service.NO_PRICE_TIER, service.UNKNOWN_CURRENCY, service.UNKNOWN_REGION, service.NO_TAX_RATE,
+		service.UNKNOWN_COUPON, service.COUPON_EXPIRED, service.COUPON_NOT_STARTED, service.COUPON_USED_UP, service.COUPON_NOT_APPLICABLE:
+		return http.StatusUnprocessableEntity
+	case service.CANCELED:
+		return statusClientClosedRequest
+	case service.TIMEOUT:
+		return http.StatusRequestTimeout
+	}
+
+	return http.StatusInternalServerError
+}
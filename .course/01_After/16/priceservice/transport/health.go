@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+)
+
+const (
+	HEALTH_OK          = "ok"
+	HEALTH_UNAVAILABLE = "unavailable"
+)
+
+// HealthCheck reports whether a dependency of the service can be used.
+type HealthCheck func(ctx context.Context) error
+
+// HealthResponse is the result of the health checks. Checks holds the error
+// of each check that failed, by name.
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func MakeHealthEndpoint(checks map[string]HealthCheck) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp := HealthResponse{Status: HEALTH_OK}
+
+		for name, check := range checks {
+			if err := check(ctx); err != nil {
+				if resp.Checks == nil {
+					resp.Checks = make(map[string]string)
+				}
+
+				resp.Status = HEALTH_UNAVAILABLE
+				resp.Checks[name] = err.Error()
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+func decodeHealthRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// encodeHealthResponse answers 503 Service Unavailable when a check failed,
+// so that load balancers can act on the status alone.
+func encodeHealthResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if resp, ok := response.(HealthResponse); ok && resp.Status != HEALTH_OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+// MakeHealthHttpHandler serves the health of the service, which is healthy
+// while every one of checks passes.
+func MakeHealthHttpHandler(logger log.Logger, checks map[string]HealthCheck) *httptransport.Server {
+	return httptransport.NewServer(
+		MakeHealthEndpoint(checks),
+		decodeHealthRequest,
+		encodeHealthResponse,
+		httptransport.ServerErrorEncoder(encodeError),
+	)
+}
@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MakeHealthHttpHandler(t *testing.T) {
+	passing := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		checks   map[string]HealthCheck
+		status   int
+		response HealthResponse
+	}{
+		{
+			checks:   nil,
+			status:   http.StatusOK,
+			response: HealthResponse{Status: HEALTH_OK},
+		},
+		{
+			checks:   map[string]HealthCheck{"db": passing},
+			status:   http.StatusOK,
+			response: HealthResponse{Status: HEALTH_OK},
+		},
+		{
+			checks:   map[string]HealthCheck{"db": failing, "catalog": passing},
+			status:   http.StatusServiceUnavailable,
+			response: HealthResponse{Status: HEALTH_UNAVAILABLE, Checks: map[string]string{"db": "connection refused"}},
+		},
+	}
+
+	for id, test := range tests {
+		server := httptest.NewServer(MakeHealthHttpHandler(&MockLogger{}, test.checks))
+
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+
+		var actual HealthResponse
+		json.NewDecoder(resp.Body).Decode(&actual)
+		server.Close()
+
+		assert.True(t, test.status == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, test.status, resp.StatusCode)
+		assert.True(t, test.response.Status == actual.Status, "~2|Test #%d expected health: %s, not health %s~", id, test.response.Status, actual.Status)
+		assert.True(t, fmt.Sprint(test.response.Checks) == fmt.Sprint(actual.Checks), "~2|Test #%d expected checks: %v, not checks %v~", id, test.response.Checks, actual.Checks)
+	}
+}
@@ -37,12 +37,12 @@ func Test_LogTotalRetailPriceEndpoint(t *testing.T) {
 	}{
 		{
 			service:  "endpointRetailTest",
-			request:  TotalRetailPriceRequest{"aaa11", 10},
+			request:  TotalRetailPriceRequest{Code: "aaa11", Qty: 10},
 			expected: "service,endpointRetailTest,endpoint,TotalRetailPriceEndpoint,msg,Called endpoint",
 		},
 		{
 			service:  "endpointRetailTest",
-			request:  TotalRetailPriceRequest{"bbb11", 20},
+			request:  TotalRetailPriceRequest{Code: "bbb11", Qty: 20},
 			expected: "service,endpointRetailTest,endpoint,TotalRetailPriceEndpoint,msg,Called endpoint",
 		},
 	}
@@ -72,12 +72,12 @@ func Test_LogTotalWholesalePriceEndpoint(t *testing.T) {
 	}{
 		{
 			service:  "endpointWholesaleTest",
-			request:  TotalWholesalePriceRequest{"testpartner", "aaa11", 10},
+			request:  TotalWholesalePriceRequest{Partner: "testpartner", Code: "aaa11", Qty: 10},
 			expected: "service,endpointWholesaleTest,endpoint,TotalWholesalePriceEndpoint,msg,Called endpoint",
 		},
 		{
 			service:  "endpointWholesaleTest",
-			request:  TotalWholesalePriceRequest{"testpartner", "bbb11", 20},
+			request:  TotalWholesalePriceRequest{Partner: "testpartner", Code: "bbb11", Qty: 20},
 			expected: "service,endpointWholesaleTest,endpoint,TotalWholesalePriceEndpoint,msg,Called endpoint",
 		},
 	}
@@ -98,3 +98,38 @@ func Test_LogTotalWholesalePriceEndpoint(t *testing.T) {
 		assert.True(t, test.expected == actual, "~2|Test #%d logger expected: \"%s\", not: \"%s\"~", id, test.expected, actual)
 	}
 }
+
+func Test_LogQuoteEndpoint(t *testing.T) {
+	tests := []struct {
+		service  string
+		request  QuoteRequest
+		expected string
+	}{
+		{
+			service:  "endpointQuoteTest",
+			request:  QuoteRequest{Lines: []QuoteLineRequest{{"aaa11", 10}}},
+			expected: "service,endpointQuoteTest,endpoint,QuoteEndpoint,msg,Called endpoint",
+		},
+		{
+			service:  "endpointQuoteTest",
+			request:  QuoteRequest{Partner: "testpartner", Lines: []QuoteLineRequest{{"bbb11", 20}}},
+			expected: "service,endpointQuoteTest,endpoint,QuoteEndpoint,msg,Called endpoint",
+		},
+	}
+
+	endpoint := func(_ context.Context, request interface{}) (interface{}, error) {
+		return request, nil
+	}
+
+	logger := &MockLogger{}
+
+	for id, test := range tests {
+		lmw := LogQuoteEndpoint(log.With(logger, "service", test.service))(endpoint)
+
+		lmw(context.Background(), test.request)
+
+		actual := logger.Result()
+
+		assert.True(t, test.expected == actual, "~2|Test #%d logger expected: \"%s\", not: \"%s\"~", id, test.expected, actual)
+	}
+}
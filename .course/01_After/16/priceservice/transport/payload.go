@@ -1,36 +1,34 @@
 package transport
 
-import "fmt"
+import (
+	"encoding/json"
 
-type TotalRetailPriceRequest struct {
-	Code string `json:"code"`
-	Qty  int    `json:"qty"`
-}
-
-type TotalRetailPriceResponse struct {
-	Total float64 `json:"total"`
-	Err   string  `json:"err,omitempty"`
-}
-
-type TotalWholesalePriceRequest struct {
-	Partner string `json:"partner"`
-	Code    string `json:"code"`
-	Qty     int    `json:"qty"`
-}
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
+)
 
-type TotalWholesalePriceResponse struct {
-	Total float64 `json:"total"`
-	Err   string  `json:"err,omitempty"`
-}
+// The requests and responses of the routes are generated from the service
+// interface into payload_gen.go. ErrorResponse is the envelope they share
+// for errors.
 
+// ErrorResponse is the body of every failed request, sent with the HTTP
+// status of its Code. Message is for people; Details, when present, names
+// the request values the error is about.
 type ErrorResponse struct {
-	Err string `json:"err, omitEmpty"`
+	Code    service.Code      `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
 }
 
 func (e *ErrorResponse) Error() string {
-	return e.Err
+	return e.Message
+}
+
+// StatusCode is the HTTP status of the error, for the go-kit error encoder.
+func (e *ErrorResponse) StatusCode() int {
+	return errorStatus(e.Code)
 }
 
 func (e *ErrorResponse) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf(`{"err":"%s"}`, e.Err)), nil
+	type envelope ErrorResponse
+	return json.Marshal((*envelope)(e))
 }
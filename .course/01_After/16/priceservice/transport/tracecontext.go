@@ -0,0 +1,29 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// traceContext carries spans between the services in the W3C traceparent
+// and tracestate headers.
+var traceContext = propagation.TraceContext{}
+
+// extractTrace continues the trace of the caller, when the request carries
+// one, so that the spans of both services belong to a single trace.
+func extractTrace() httptransport.RequestFunc {
+	return func(ctx context.Context, req *http.Request) context.Context {
+		return traceContext.Extract(ctx, propagation.HeaderCarrier(req.Header))
+	}
+}
+
+// injectTrace passes the current span on to the upstream service.
+func injectTrace() httptransport.RequestFunc {
+	return func(ctx context.Context, req *http.Request) context.Context {
+		traceContext.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		return ctx
+	}
+}
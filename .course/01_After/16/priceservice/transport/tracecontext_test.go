@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_ExtractTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	tests := []struct {
+		traceparent string
+		traceID     string
+		remote      bool
+	}{
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceID: "4bf92f3577b34da6a3ce929d0e0e4736", remote: true},
+		{traceparent: "", remote: false},
+		{traceparent: "not-a-traceparent", remote: false},
+	}
+
+	for id, test := range tests {
+		before := len(recorder.Ended())
+		server := httptest.NewServer(MakeTotalRetailPriceHttpHandler(&MockLogger{}, new(MockPricingService)))
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString(`{"code":"aaa111","qty":15}`))
+		if test.traceparent != "" {
+			req.Header.Set("traceparent", test.traceparent)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("An Error Occured %v", err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		spans := recorder.Ended()[before:]
+		assert.True(t, len(spans) > 0, "~2|Test #%d expected spans~", id)
+
+		root := spans[0]
+		for _, span := range spans {
+			if span.SpanKind() == trace.SpanKindServer {
+				root = span
+			}
+		}
+
+		traceID := root.SpanContext().TraceID()
+		if test.traceID != "" {
+			assert.True(t, test.traceID == traceID.String(), "~2|Test #%d expected trace ID: %s, not trace ID %s~", id, test.traceID, traceID)
+		}
+		assert.True(t, test.remote == root.Parent().IsRemote(), "~2|Test #%d expected remote parent: %t, not %t~", id, test.remote, root.Parent().IsRemote())
+
+		for _, span := range spans {
+			assert.True(t, traceID == span.SpanContext().TraceID(), "~2|Test #%d expected span %s in trace %s, not trace %s~", id, span.Name(), traceID, span.SpanContext().TraceID())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	kittransport "github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Pricing attributes of the spans of a request.
+const (
+	pricingCode      = attribute.Key("pricing.code")
+	pricingQty       = attribute.Key("pricing.qty")
+	pricingPartner   = attribute.Key("pricing.partner")
+	pricingLines     = attribute.Key("pricing.lines")
+	pricingErrorCode = attribute.Key("pricing.error_code")
+)
+
+// requestAttributes returns the pricing attributes of request.
+func requestAttributes(request interface{}) (attrs []attribute.KeyValue) {
+	switch req := request.(type) {
+	case TotalRetailPriceRequest:
+		return []attribute.KeyValue{pricingCode.String(req.Code), pricingQty.Int(req.Qty)}
+	case TotalWholesalePriceRequest:
+		return []attribute.KeyValue{pricingPartner.String(req.Partner), pricingCode.String(req.Code), pricingQty.Int(req.Qty)}
+	case QuoteRequest:
+		return []attribute.KeyValue{pricingPartner.String(req.Partner), pricingLines.Int(len(req.Lines))}
+	}
+
+	return nil
+}
+
+// startServerSpan starts the span of a request to route. The span covers
+// decoding, the endpoint and encoding, and is ended by finishServerSpan.
+func startServerSpan(tracer trace.Tracer, route string) httptransport.RequestFunc {
+	return func(ctx context.Context, req *http.Request) context.Context {
+		ctx, _ = tracer.Start(ctx, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, req)...),
+			trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", req)...),
+		)
+
+		return ctx
+	}
+}
+
+// finishServerSpan sets the status of the server span from the response
+// code and ends it.
+func finishServerSpan(ctx context.Context, code int, req *http.Request) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(code)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(code, trace.SpanKindServer))
+	span.End()
+}
+
+// traceErrors records the errors of a request on its server span, with the
+// code they are sent back with.
+func traceErrors() kittransport.ErrorHandler {
+	return kittransport.ErrorHandlerFunc(func(ctx context.Context, err error) {
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetAttributes(pricingErrorCode.String(string(makeErrorResponse(err).Code)))
+	})
+}
+
+// traceRequest adds the pricing attributes of the decoded request to the
+// server span.
+func traceRequest(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		trace.SpanFromContext(ctx).SetAttributes(requestAttributes(request)...)
+		return next(ctx, request)
+	}
+}
+
+// TraceEndpoint gives every call to an endpoint a span, named by operation,
+// with the pricing attributes of the request. Failed calls record their
+// error and code and are marked as errors.
+func TraceEndpoint(tracer trace.Tracer, operation string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, span := tracer.Start(ctx, operation, trace.WithAttributes(requestAttributes(request)...))
+			defer span.End()
+
+			response, err := next(ctx, request)
+			if err != nil {
+				span.RecordError(err)
+				span.SetAttributes(pricingErrorCode.String(string(makeErrorResponse(err).Code)))
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return response, err
+		}
+	}
+}
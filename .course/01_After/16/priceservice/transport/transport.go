@@ -4,74 +4,57 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
 
-	gkendpoint "github.com/go-kit/kit/endpoint"
-	httptransport "github.com/go-kit/kit/transport/http"
-	"github.com/go-kit/log"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/trace"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
 )
 
+// The payloads, endpoints, handlers and request decoders of the routes of
+// the pricing service are generated from its interface. The client file is
+// the proxy's half, and goes to the pricing API, which shares this
+// package's payload, endpoint and transport files.
+//
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind payload -package transport -out payload_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind payload -package transport -out ../../priceapi/transport/payload_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind endpoint -package transport -out endpoint_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind endpoint -package transport -out ../../priceapi/transport/endpoint_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind transport -package transport -out transport_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind transport -package transport -out ../../priceapi/transport/transport_gen.go
+//go:generate go run ../cmd/kitgen -type PricingService -source ../service/service.go -kind client -package transport -out ../../priceapi/transport/client_gen.go
+
 const (
 	INVALID_REQUEST = "Invalid Request"
 )
 
-func decodeTotalRetailPriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request TotalRetailPriceRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, &ErrorResponse{Err: INVALID_REQUEST}
-	}
-
-	return request, nil
-}
-
-func MakeTotalRetailPriceHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
-	tracer := otel.Tracer("Transport.Transport")
+// errInvalidRequest is the error of a request that does not decode.
+var errInvalidRequest = &ErrorResponse{Code: service.INVALID_REQUEST, Message: INVALID_REQUEST}
 
-	var retailEndpoint gkendpoint.Endpoint
-	retailEndpoint = MakeTotalRetailPriceEndpoint(svc)
-	retailEndpoint = LogTotalRetailPriceEndpoint(log.With(logger, "service", "PricingService"))(retailEndpoint)
+func (request *TotalRetailPriceRequest) decodeQuery(query url.Values) (err error) {
+	explain, err := explainQuery(query)
+	request.Explain = request.Explain || explain
 
-	return httptransport.NewServer(
-		retailEndpoint,
-		decodeTotalRetailPriceRequest,
-		encodeResponse,
-		httptransport.ServerBefore(startTrace(tracer, logger)),
-	)
+	return err
 }
 
-func decodeTotalWholesalePriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request TotalWholesalePriceRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, &ErrorResponse{Err: INVALID_REQUEST}
-	}
+func (request *TotalWholesalePriceRequest) decodeQuery(query url.Values) (err error) {
+	explain, err := explainQuery(query)
+	request.Explain = request.Explain || explain
 
-	return request, nil
+	return err
 }
 
-func MakeTotalWholesalePriceHttpHandler(logger log.Logger, svc PricingService) *httptransport.Server {
-	tracer := otel.Tracer("Transport.Transport")
-
-	var wholesaleEndpoint gkendpoint.Endpoint
-	wholesaleEndpoint = MakeTotalWholesalePriceEndpoint(svc)
-	wholesaleEndpoint = LogTotalWholesalePriceEndpoint(log.With(logger, "service", "PricingService"))(wholesaleEndpoint)
+// explainQuery reads the optional explain=true query parameter, which asks
+// for a breakdown just as the explain field of the request body does.
+func explainQuery(query url.Values) (explain bool, err error) {
+	value := query.Get("explain")
+	if value == "" {
+		return false, nil
+	}
 
-	return httptransport.NewServer(
-		wholesaleEndpoint,
-		decodeTotalWholesalePriceRequest,
-		encodeResponse,
-		httptransport.ServerBefore(startTrace(tracer, logger)),
-	)
+	return strconv.ParseBool(value)
 }
 
 func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
 	return json.NewEncoder(w).Encode(response)
 }
-
-func startTrace(tracer trace.Tracer, logger log.Logger) httptransport.RequestFunc {
-	return func(ctx context.Context, req *http.Request) context.Context {
-		ctx, span := otel.Tracer("Transport.PricingProxy").Start(req.Context(), "StartTrace")
-		span.End()
-		return ctx
-	}
-}
@@ -8,6 +8,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/money"
+	"github.com/britzc/go-kit_0dot12_fundamentals/current/service"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +26,18 @@ func Test_TotalRetailPriceRequest(t *testing.T) {
 			input:    TotalRetailPriceRequest{Code: "", Qty: 12},
 			expected: TotalRetailPriceRequest{Code: "", Qty: 12},
 		},
+		{
+			input:    TotalRetailPriceRequest{Code: "test", Qty: 1, AsOf: &launch},
+			expected: TotalRetailPriceRequest{Code: "test", Qty: 1, AsOf: &launch},
+		},
+		{
+			input:    TotalRetailPriceRequest{Code: "test", Qty: 1, Currency: "EUR", Region: "uk", Coupon: "SAVE10"},
+			expected: TotalRetailPriceRequest{Code: "test", Qty: 1, Currency: "EUR", Region: "uk", Coupon: "SAVE10"},
+		},
+		{
+			input:    TotalRetailPriceRequest{Code: "test", Qty: 1, Explain: true},
+			expected: TotalRetailPriceRequest{Code: "test", Qty: 1, Explain: true},
+		},
 	}
 
 	for id, test := range tests {
@@ -34,6 +48,11 @@ func Test_TotalRetailPriceRequest(t *testing.T) {
 
 		assert.True(t, test.expected.Code == actual.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.expected.Code, actual.Code)
 		assert.True(t, test.expected.Qty == actual.Qty, "~2|Test #%d expected qty: %d, not qty %d~", id, test.expected.Qty, actual.Qty)
+		assert.True(t, required(test.expected.AsOf).Equal(required(actual.AsOf)), "~2|Test #%d expected as of: %v, not as of %v~", id, test.expected.AsOf, actual.AsOf)
+		assert.True(t, test.expected.Currency == actual.Currency, "~2|Test #%d expected currency: %s, not currency %s~", id, test.expected.Currency, actual.Currency)
+		assert.True(t, test.expected.Region == actual.Region, "~2|Test #%d expected region: %s, not region %s~", id, test.expected.Region, actual.Region)
+		assert.True(t, test.expected.Coupon == actual.Coupon, "~2|Test #%d expected coupon: %s, not coupon %s~", id, test.expected.Coupon, actual.Coupon)
+		assert.True(t, test.expected.Explain == actual.Explain, "~2|Test #%d expected explain: %t, not explain %t~", id, test.expected.Explain, actual.Explain)
 	}
 }
 
@@ -43,12 +62,8 @@ func Test_TotalRetailPriceResponse(t *testing.T) {
 		expected TotalRetailPriceResponse
 	}{
 		{
-			input:    TotalRetailPriceResponse{Total: 100.99},
-			expected: TotalRetailPriceResponse{Total: 100.99},
-		},
-		{
-			input:    TotalRetailPriceResponse{Total: 0.0, Err: "test"},
-			expected: TotalRetailPriceResponse{Total: 0.0, Err: "test"},
+			input:    TotalRetailPriceResponse{Total: money.MustParse("100.99")},
+			expected: TotalRetailPriceResponse{Total: money.MustParse("100.99")},
 		},
 	}
 
@@ -58,8 +73,7 @@ func Test_TotalRetailPriceResponse(t *testing.T) {
 		var actual TotalRetailPriceResponse
 		json.Unmarshal(data, &actual)
 
-		assert.True(t, test.expected.Total == actual.Total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, test.expected.Total, actual.Total)
-		assert.True(t, test.expected.Err == actual.Err, "~2|Test #%d expected err: %s, not err %s~", id, test.expected.Err, actual.Err)
+		assert.True(t, test.expected.Total == actual.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.expected.Total, actual.Total)
 	}
 }
 
@@ -67,26 +81,32 @@ func Test_MakeTotalRetailPriceHttpHandler(t *testing.T) {
 	tests := []struct {
 		request  interface{}
 		response interface{}
+		status   int
+		err      ErrorResponse
 	}{
 		{
-			request:  TotalRetailPriceRequest{Code: "", Qty: 0},
-			response: TotalRetailPriceResponse{Err: "Invalid Code Requested"},
+			request: TotalRetailPriceRequest{Code: "", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_CODE, Message: "Invalid Code Requested"},
 		},
 		{
-			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 0},
-			response: TotalRetailPriceResponse{Err: "Invalid Quantity Requested"},
+			request: TotalRetailPriceRequest{Code: "aaa111", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_QTY, Message: "Invalid Quantity Requested"},
 		},
 		{
 			request:  TotalRetailPriceRequest{Code: "aaa111", Qty: 15},
-			response: TotalRetailPriceResponse{Total: 194.85},
+			response: TotalRetailPriceResponse{Total: money.MustParse("194.85")},
 		},
 		{
-			request:  TotalRetailPriceRequest{Code: "fff000", Qty: 10},
-			response: TotalRetailPriceResponse{Err: "Code Not Found"},
+			request: TotalRetailPriceRequest{Code: "fff000", Qty: 10},
+			status:  http.StatusNotFound,
+			err:     ErrorResponse{Code: service.CODE_NOT_FOUND, Message: "Code Not Found"},
 		},
 		{
-			request:  "test",
-			response: TotalRetailPriceResponse{Err: "Invalid Request"},
+			request: "test",
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_REQUEST, Message: "Invalid Request"},
 		},
 	}
 
@@ -108,12 +128,14 @@ func Test_MakeTotalRetailPriceHttpHandler(t *testing.T) {
 		}
 
 		var actualResponse TotalRetailPriceResponse
-		json.NewDecoder(resp.Body).Decode(&actualResponse)
+		actualErr := decodeResponse(resp, &actualResponse)
 
-		testResponse := test.response.(TotalRetailPriceResponse)
+		testResponse, _ := test.response.(TotalRetailPriceResponse)
 
-		assert.True(t, testResponse.Err == actualResponse.Err, "~2|Test #%d expected error: %s, not error %s~", id, testResponse.Err, actualResponse.Err)
-		assert.True(t, testResponse.Total == actualResponse.Total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, testResponse.Total, actualResponse.Total)
+		assert.True(t, status(test.status) == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, status(test.status), resp.StatusCode)
+		assert.True(t, test.err.Code == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err.Code, actualErr.Code)
+		assert.True(t, test.err.Message == actualErr.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.err.Message, actualErr.Message)
+		assert.True(t, testResponse.Total == actualResponse.Total, "~2|Test #%d expected total: %s, not total %s~", id, testResponse.Total, actualResponse.Total)
 	}
 }
 
@@ -154,12 +176,8 @@ func Test_TotalWholesalePriceResponse(t *testing.T) {
 		expected TotalWholesalePriceResponse
 	}{
 		{
-			input:    TotalWholesalePriceResponse{Total: 100.99},
-			expected: TotalWholesalePriceResponse{Total: 100.99},
-		},
-		{
-			input:    TotalWholesalePriceResponse{Total: 0.0, Err: "test"},
-			expected: TotalWholesalePriceResponse{Total: 0.0, Err: "test"},
+			input:    TotalWholesalePriceResponse{Total: money.MustParse("100.99")},
+			expected: TotalWholesalePriceResponse{Total: money.MustParse("100.99")},
 		},
 	}
 
@@ -169,8 +187,7 @@ func Test_TotalWholesalePriceResponse(t *testing.T) {
 		var actual TotalWholesalePriceResponse
 		json.Unmarshal(data, &actual)
 
-		assert.True(t, test.expected.Total == actual.Total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, test.expected.Total, actual.Total)
-		assert.True(t, test.expected.Err == actual.Err, "~2|Test #%d expected err: %s, not err %s~", id, test.expected.Err, actual.Err)
+		assert.True(t, test.expected.Total == actual.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.expected.Total, actual.Total)
 	}
 }
 
@@ -178,34 +195,42 @@ func Test_MakeTotalWholesalePriceHttpHandler(t *testing.T) {
 	tests := []struct {
 		request  interface{}
 		response interface{}
+		status   int
+		err      ErrorResponse
 	}{
 		{
-			request:  TotalWholesalePriceRequest{Partner: "", Code: "aaa111", Qty: 0},
-			response: TotalWholesalePriceResponse{Err: "Invalid Partner Requested"},
+			request: TotalWholesalePriceRequest{Partner: "", Code: "aaa111", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_PARTNER, Message: "Invalid Partner Requested"},
 		},
 		{
-			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "", Qty: 0},
-			response: TotalWholesalePriceResponse{Err: "Invalid Code Requested"},
+			request: TotalWholesalePriceRequest{Partner: "superstore", Code: "", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_CODE, Message: "Invalid Code Requested"},
 		},
 		{
-			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 0},
-			response: TotalWholesalePriceResponse{Err: "Invalid Quantity Requested"},
+			request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 0},
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_QTY, Message: "Invalid Quantity Requested"},
 		},
 		{
 			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15},
-			response: TotalWholesalePriceResponse{Total: 165.62},
+			response: TotalWholesalePriceResponse{Total: money.MustParse("165.62")},
 		},
 		{
-			request:  TotalWholesalePriceRequest{Partner: "test", Code: "aaa111", Qty: 10},
-			response: TotalWholesalePriceResponse{Err: "Partner Not Found"},
+			request: TotalWholesalePriceRequest{Partner: "test", Code: "aaa111", Qty: 10},
+			status:  http.StatusNotFound,
+			err:     ErrorResponse{Code: service.PARTNER_NOT_FOUND, Message: "Partner Not Found"},
 		},
 		{
-			request:  TotalWholesalePriceRequest{Partner: "superstore", Code: "fff000", Qty: 10},
-			response: TotalWholesalePriceResponse{Err: "Code Not Found"},
+			request: TotalWholesalePriceRequest{Partner: "superstore", Code: "fff000", Qty: 10},
+			status:  http.StatusNotFound,
+			err:     ErrorResponse{Code: service.CODE_NOT_FOUND, Message: "Code Not Found"},
 		},
 		{
-			request:  "test",
-			response: TotalWholesalePriceResponse{Err: "Invalid Request"},
+			request: "test",
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_REQUEST, Message: "Invalid Request"},
 		},
 	}
 
@@ -227,11 +252,103 @@ func Test_MakeTotalWholesalePriceHttpHandler(t *testing.T) {
 		}
 
 		var actualResponse TotalWholesalePriceResponse
-		json.NewDecoder(resp.Body).Decode(&actualResponse)
+		actualErr := decodeResponse(resp, &actualResponse)
+
+		testResponse, _ := test.response.(TotalWholesalePriceResponse)
+
+		assert.True(t, status(test.status) == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, status(test.status), resp.StatusCode)
+		assert.True(t, test.err.Code == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err.Code, actualErr.Code)
+		assert.True(t, test.err.Message == actualErr.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.err.Message, actualErr.Message)
+		assert.True(t, testResponse.Total == actualResponse.Total, "~2|Test #%d expected total: %s, not total %s~", id, testResponse.Total, actualResponse.Total)
+	}
+}
+
+func Test_MakeTotalWholesalePriceHttpHandler_Explain(t *testing.T) {
+	tests := []struct {
+		query     string
+		request   TotalWholesalePriceRequest
+		err       service.Code
+		explained bool
+	}{
+		{query: "", request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15}},
+		{query: "", request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15, Explain: true}, explained: true},
+		{query: "?explain=true", request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15}, explained: true},
+		{query: "?explain=1", request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15}, explained: true},
+		{query: "?explain=false", request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15}},
+		{query: "?explain=maybe", request: TotalWholesalePriceRequest{Partner: "superstore", Code: "aaa111", Qty: 15}, err: service.INVALID_REQUEST},
+	}
+
+	mockPricingService := new(MockPricingService)
+
+	logger := &MockLogger{}
+	totalWholesalePriceHandler := MakeTotalWholesalePriceHttpHandler(logger, mockPricingService)
+
+	server := httptest.NewServer(totalWholesalePriceHandler)
+	defer server.Close()
+
+	for id, test := range tests {
+		postBody, _ := json.Marshal(test.request)
+
+		resp, err := http.Post(server.URL+test.query, "application/json", bytes.NewBuffer(postBody))
+		if err != nil {
+			log.Fatalf("An Error Occured %v", err)
+		}
+
+		var actualResponse TotalWholesalePriceResponse
+		actualErr := decodeResponse(resp, &actualResponse)
+
+		explained := actualResponse.Breakdown != nil
+		assert.True(t, test.err == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err, actualErr.Code)
+		assert.True(t, test.explained == explained, "~2|Test #%d expected explained: %t, not explained %t~", id, test.explained, explained)
+	}
+}
+
+func Test_MakeQuoteHttpHandler(t *testing.T) {
+	tests := []struct {
+		request  interface{}
+		response QuoteResponse
+		status   int
+		err      ErrorResponse
+	}{
+		{
+			request:  QuoteRequest{Partner: "test", Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 10}}},
+			response: QuoteResponse{Partner: "test", Total: 0},
+		},
+		{
+			request:  QuoteRequest{Partner: "superstore", Lines: []QuoteLineRequest{{Code: "aaa111", Qty: 15}}},
+			response: QuoteResponse{Partner: "superstore", Total: money.MustParse("165.62")},
+		},
+		{
+			request: "test",
+			status:  http.StatusBadRequest,
+			err:     ErrorResponse{Code: service.INVALID_REQUEST, Message: "Invalid Request"},
+		},
+	}
+
+	mockPricingService := new(MockPricingService)
+
+	logger := &MockLogger{}
+	quoteHandler := MakeQuoteHttpHandler(logger, mockPricingService)
+
+	server := httptest.NewServer(quoteHandler)
+	defer server.Close()
+
+	for id, test := range tests {
+		postBody, _ := json.Marshal(test.request)
+
+		responseBody := bytes.NewBuffer(postBody)
+		resp, err := http.Post(server.URL, "application/json", responseBody)
+		if err != nil {
+			log.Fatalf("An Error Occured %v", err)
+		}
 
-		testResponse := test.response.(TotalWholesalePriceResponse)
+		var actualResponse QuoteResponse
+		actualErr := decodeResponse(resp, &actualResponse)
 
-		assert.True(t, testResponse.Err == actualResponse.Err, "~2|Test #%d expected error: %s, not error %s~", id, testResponse.Err, actualResponse.Err)
-		assert.True(t, testResponse.Total == actualResponse.Total, "~2|Test #%d expected total: %.2f, not total %.2f~", id, testResponse.Total, actualResponse.Total)
+		assert.True(t, status(test.status) == resp.StatusCode, "~2|Test #%d expected status: %d, not status %d~", id, status(test.status), resp.StatusCode)
+		assert.True(t, test.err.Code == actualErr.Code, "~2|Test #%d expected code: %s, not code %s~", id, test.err.Code, actualErr.Code)
+		assert.True(t, test.err.Message == actualErr.Message, "~2|Test #%d expected message: %s, not message %s~", id, test.err.Message, actualErr.Message)
+		assert.True(t, test.response.Partner == actualResponse.Partner, "~2|Test #%d expected partner: %s, not partner %s~", id, test.response.Partner, actualResponse.Partner)
+		assert.True(t, test.response.Total == actualResponse.Total, "~2|Test #%d expected total: %s, not total %s~", id, test.response.Total, actualResponse.Total)
 	}
 }